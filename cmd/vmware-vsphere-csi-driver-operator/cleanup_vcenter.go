@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/http/httpproxy"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/storageclasscontroller"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+)
+
+type cleanupVCenterOptions struct {
+	infrastructureID       string
+	decommissionedClusters []string
+	cloudConfigFile        string
+	cloudConfigKey         string
+	credentialsFile        string
+	httpProxy              string
+	httpsProxy             string
+	noProxy                string
+	trustedCABundleFile    string
+	yes                    bool
+}
+
+// NewCleanupVCenterCommand creates a command that removes objects created in vCenter for a cluster that was
+// destroyed without the installer.
+func NewCleanupVCenterCommand() *cobra.Command {
+	opts := cleanupVCenterOptions{}
+	cmd := &cobra.Command{
+		Use:   "cleanup-vcenter",
+		Short: "Remove the tag category, tag, storage policy and CNS volumes of a decommissioned cluster from vCenter",
+		Long: `Remove the tag category, tag, storage policy and CNS volumes of a decommissioned cluster from vCenter.
+
+The command lists the objects created for the given infrastructure ID in all vCenters in the cloud config,
+shows the plan and deletes them after confirmation. A cluster exists when vCenter has virtual machines with
+the tag the installer attaches to them. Clusters without the tag, e.g. UPI clusters, can't be told apart from
+removed ones: CNS volumes that are used also by another cluster are deleted only when that cluster is listed
+in --decommissioned-cluster, and a cluster without the tag that still has CNS volumes is cleaned up only when
+it's listed there too.
+
+The proxy is read from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, the flags override it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return opts.run(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.infrastructureID, "infrastructure-id", "", "Infrastructure ID of the decommissioned cluster")
+	flags.StringSliceVar(&opts.decommissionedClusters, "decommissioned-cluster", nil, "Infrastructure ID of a cluster that is known to be removed, CNS volumes it shares with the cleaned up cluster can be deleted. Can be repeated")
+	flags.StringVar(&opts.cloudConfigFile, "cloud-config", "", "YAML file with the cloud provider ConfigMap")
+	flags.StringVar(&opts.cloudConfigKey, "cloud-config-key", "config", "Key of the cloud config in the cloud provider ConfigMap")
+	flags.StringVar(&opts.credentialsFile, "credentials", "", "YAML file with the vCenter credentials Secret")
+	proxyEnv := httpproxy.FromEnvironment()
+	flags.StringVar(&opts.httpProxy, "http-proxy", proxyEnv.HTTPProxy, "URL of the proxy for HTTP connections to vCenter")
+	flags.StringVar(&opts.httpsProxy, "https-proxy", proxyEnv.HTTPSProxy, "URL of the proxy for HTTPS connections to vCenter")
+	flags.StringVar(&opts.noProxy, "no-proxy", proxyEnv.NoProxy, "Comma-separated list of hosts that are connected without the proxy")
+	flags.StringVar(&opts.trustedCABundleFile, "trusted-ca-bundle", "", "PEM file with additional CAs, needed when the proxy re-encrypts traffic")
+	flags.BoolVar(&opts.yes, "yes", false, "Delete the objects without confirmation")
+	for _, name := range []string{"infrastructure-id", "cloud-config", "credentials"} {
+		cmd.MarkFlagRequired(name)
+	}
+	return cmd
+}
+
+func (o *cleanupVCenterOptions) run(ctx context.Context, in io.Reader, out io.Writer) error {
+	connections, err := o.connect(ctx)
+	defer func() {
+		for _, connection := range connections {
+			if err := connection.Logout(ctx); err != nil {
+				klog.Errorf("error logging out from vCenter %s: %v", connection.Hostname, err)
+			}
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	var cleaners []*storageclasscontroller.VCenterCleaner
+	var allChanges []string
+	for _, connection := range connections {
+		cleaner := storageclasscontroller.NewVCenterCleaner(connection, o.infrastructureID, o.decommissionedClusters)
+		changes, kept, err := cleaner.Plan(ctx)
+		if err != nil {
+			return fmt.Errorf("error planning cleanup of vCenter %s: %v", connection.Hostname, err)
+		}
+		for _, volume := range kept {
+			fmt.Fprintf(out, "Keeping %s\n", volume)
+		}
+		cleaners = append(cleaners, cleaner)
+		allChanges = append(allChanges, changes...)
+	}
+	if len(allChanges) == 0 {
+		fmt.Fprintf(out, "Nothing to clean up for cluster %s\n", o.infrastructureID)
+		return nil
+	}
+	fmt.Fprintln(out, "Planned changes:")
+	for _, change := range allChanges {
+		fmt.Fprintf(out, "  %s\n", change)
+	}
+
+	if !o.yes {
+		fmt.Fprint(out, "Delete these objects? [y/N]: ")
+		answer, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Fprintln(out, "Cleanup cancelled")
+			return nil
+		}
+	}
+	for i, cleaner := range cleaners {
+		if err := cleaner.Cleanup(ctx); err != nil {
+			return fmt.Errorf("error cleaning up vCenter %s: %v", connections[i].Hostname, err)
+		}
+	}
+	fmt.Fprintf(out, "Cleaned up cluster %s\n", o.infrastructureID)
+	return nil
+}
+
+// connect logs in to all vCenters in the cloud config. It returns the connections made so far also on error,
+// so they can be closed.
+func (o *cleanupVCenterOptions) connect(ctx context.Context) ([]*vclib.VSphereConnection, error) {
+	cloudConfigMap := &corev1.ConfigMap{}
+	if err := readYAML(o.cloudConfigFile, cloudConfigMap); err != nil {
+		return nil, err
+	}
+	cfgString, ok := cloudConfigMap.Data[o.cloudConfigKey]
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain key %q", o.cloudConfigFile, o.cloudConfigKey)
+	}
+	cfg := &vclib.VSphereConfig{}
+	if err := cfg.LoadConfig(cfgString); err != nil {
+		return nil, err
+	}
+	credentials := &corev1.Secret{}
+	if err := readYAML(o.credentialsFile, credentials); err != nil {
+		return nil, err
+	}
+	proxyConfig, err := o.getProxyConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []string
+	for server := range cfg.Config.VirtualCenter {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	var connections []*vclib.VSphereConnection
+	for _, server := range servers {
+		username, ok := credentials.Data[server+".username"]
+		if !ok {
+			return connections, fmt.Errorf("%s does not contain key %q", o.credentialsFile, server+".username")
+		}
+		password, ok := credentials.Data[server+".password"]
+		if !ok {
+			return connections, fmt.Errorf("%s does not contain key %q", o.credentialsFile, server+".password")
+		}
+		connection, err := vclib.NewVSphereConnection(string(username), string(password), server, cfg)
+		if err != nil {
+			return connections, err
+		}
+		connection.Proxy = proxyConfig
+		if err := connection.Connect(ctx); err != nil {
+			return connections, fmt.Errorf("error connecting to vCenter %s: %v", server, err)
+		}
+		connections = append(connections, connection)
+	}
+	return connections, nil
+}
+
+// getProxyConfig returns the proxy configuration from the flags, or nil when no proxy is set.
+func (o *cleanupVCenterOptions) getProxyConfig() (*vclib.ProxyConfig, error) {
+	if o.httpProxy == "" && o.httpsProxy == "" {
+		return nil, nil
+	}
+	proxyConfig := &vclib.ProxyConfig{
+		HTTPProxy:  o.httpProxy,
+		HTTPSProxy: o.httpsProxy,
+		NoProxy:    o.noProxy,
+	}
+	if o.trustedCABundleFile != "" {
+		bundle, err := os.ReadFile(o.trustedCABundleFile)
+		if err != nil {
+			return nil, err
+		}
+		proxyConfig.TrustedCABundle = bundle
+	}
+	return proxyConfig, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	ocpv1 "github.com/openshift/api/config/v1"
+	cfgclientset "github.com/openshift/client-go/config/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller"
+)
+
+const infrastructureName = "cluster"
+
+type diagnoseOptions struct {
+	kubeconfig          string
+	nodesFile           string
+	infrastructureFile  string
+	cloudConfigFile     string
+	credentialsFile     string
+	operatorConfigFile  string
+	enabledFeatureGates []string
+	output              string
+}
+
+// NewDiagnoseCommand creates a command that runs the environment checks of the operator against vCenter without
+// installing the operator.
+func NewDiagnoseCommand() *cobra.Command {
+	opts := diagnoseOptions{}
+	cmd := &cobra.Command{
+		Use:   "diagnose",
+		Short: "Check that the vSphere environment meets requirements of the VMware vSphere CSI driver",
+		Long: `Check that the vSphere environment meets requirements of the VMware vSphere CSI driver.
+
+Nodes, CSI drivers and the Infrastructure are read from the cluster when --kubeconfig is set. Without a cluster,
+nodes are read from --nodes and the Infrastructure from --infrastructure. The command exits with a non-zero
+code when a finding blocks installation of the CSI driver or cluster upgrades.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.validate(); err != nil {
+				return err
+			}
+			// Usage does not help with failed checks
+			cmd.SilenceUsage = true
+			return opts.run(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "Kubeconfig of the cluster to read nodes, CSI drivers and the Infrastructure from")
+	flags.StringVar(&opts.nodesFile, "nodes", "", "YAML file with a NodeList, used instead of nodes of a cluster")
+	flags.StringVar(&opts.infrastructureFile, "infrastructure", "", "YAML file with the cluster Infrastructure, required without --kubeconfig")
+	flags.StringVar(&opts.cloudConfigFile, "cloud-config", "", "YAML file with the cloud provider ConfigMap referenced by the Infrastructure")
+	flags.StringVar(&opts.credentialsFile, "credentials", "", "YAML file with the vCenter credentials Secret")
+	flags.StringVar(&opts.operatorConfigFile, "operator-config", "", "Optional YAML file with the operator config ConfigMap")
+	flags.StringSliceVar(&opts.enabledFeatureGates, "enabled-feature-gates", nil, "Feature gates enabled in the cluster")
+	flags.StringVarP(&opts.output, "output", "o", "text", "Output format, text or json")
+	for _, name := range []string{"cloud-config", "credentials"} {
+		cmd.MarkFlagRequired(name)
+	}
+	return cmd
+}
+
+func (o *diagnoseOptions) validate() error {
+	if o.kubeconfig != "" && o.nodesFile != "" {
+		return fmt.Errorf("--kubeconfig and --nodes can't be used together")
+	}
+	if o.kubeconfig == "" && o.infrastructureFile == "" {
+		return fmt.Errorf("--infrastructure is required without --kubeconfig")
+	}
+	if o.output != "text" && o.output != "json" {
+		return fmt.Errorf("unsupported output format %q", o.output)
+	}
+	return nil
+}
+
+func (o *diagnoseOptions) run(ctx context.Context, out io.Writer) error {
+	inputs := vspherecontroller.DiagnoseInputs{
+		CloudConfig: &corev1.ConfigMap{},
+		Credentials: &corev1.Secret{},
+	}
+	if err := readYAML(o.cloudConfigFile, inputs.CloudConfig); err != nil {
+		return err
+	}
+	if err := readYAML(o.credentialsFile, inputs.Credentials); err != nil {
+		return err
+	}
+	if o.infrastructureFile != "" {
+		inputs.Infrastructure = &ocpv1.Infrastructure{}
+		if err := readYAML(o.infrastructureFile, inputs.Infrastructure); err != nil {
+			return err
+		}
+	}
+	if o.nodesFile != "" {
+		nodeList := &corev1.NodeList{}
+		if err := readYAML(o.nodesFile, nodeList); err != nil {
+			return err
+		}
+		for i := range nodeList.Items {
+			inputs.Nodes = append(inputs.Nodes, &nodeList.Items[i])
+		}
+	}
+	if o.kubeconfig != "" {
+		if err := o.readCluster(ctx, &inputs); err != nil {
+			return err
+		}
+	}
+	operatorConfig, err := readOperatorConfig(o.operatorConfigFile)
+	if err != nil {
+		return err
+	}
+	inputs.OperatorConfig = operatorConfig
+	inputs.FeatureGates = newFeatureGate(o.enabledFeatureGates)
+
+	report, err := vspherecontroller.Diagnose(ctx, inputs)
+	if err != nil {
+		return err
+	}
+	if o.output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	} else {
+		printReport(out, report)
+	}
+	if report.Blocking() {
+		return fmt.Errorf("found blocking findings")
+	}
+	return nil
+}
+
+// readCluster reads nodes, CSI drivers and, unless it was given in a file, the Infrastructure from the cluster.
+func (o *diagnoseOptions) readCluster(ctx context.Context, inputs *vspherecontroller.DiagnoseInputs) error {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", o.kubeconfig)
+	if err != nil {
+		return err
+	}
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	if inputs.Infrastructure == nil {
+		configClient, err := cfgclientset.NewForConfig(restConfig)
+		if err != nil {
+			return err
+		}
+		inputs.Infrastructure, err = configClient.ConfigV1().Infrastructures().Get(ctx, infrastructureName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting Infrastructure: %v", err)
+		}
+	}
+
+	nodes, err := kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing nodes: %v", err)
+	}
+	for i := range nodes.Items {
+		inputs.Nodes = append(inputs.Nodes, &nodes.Items[i])
+	}
+	csiDrivers, err := kubeClient.StorageV1().CSIDrivers().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing CSIDrivers: %v", err)
+	}
+	for i := range csiDrivers.Items {
+		inputs.CSIDrivers = append(inputs.CSIDrivers, &csiDrivers.Items[i])
+	}
+	csiNodes, err := kubeClient.StorageV1().CSINodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing CSINodes: %v", err)
+	}
+	for i := range csiNodes.Items {
+		inputs.CSINodes = append(inputs.CSINodes, &csiNodes.Items[i])
+	}
+	return nil
+}
+
+func printReport(out io.Writer, report *vspherecontroller.DiagnosticReport) {
+	if len(report.Findings) == 0 {
+		fmt.Fprintln(out, "All checks passed")
+		return
+	}
+	blocking := 0
+	for _, finding := range report.Findings {
+		if finding.Blocking {
+			blocking++
+		}
+		fmt.Fprintf(out, "[%s] %s", finding.Severity, finding.CheckID)
+		if finding.Kind != "" {
+			fmt.Fprintf(out, " %s %s", finding.Kind, finding.Name)
+		}
+		fmt.Fprintf(out, ": %s\n", finding.Message)
+		if finding.Observed != "" || finding.Required != "" {
+			fmt.Fprintf(out, "    observed: %s, required: %s\n", finding.Observed, finding.Required)
+		}
+		if finding.Remediation != "" {
+			fmt.Fprintf(out, "    remediation: %s\n", finding.Remediation)
+		}
+	}
+	fmt.Fprintf(out, "%d finding(s), %d blocking\n", len(report.Findings), blocking)
+}
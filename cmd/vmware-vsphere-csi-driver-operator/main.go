@@ -40,6 +40,9 @@ func NewOperatorCommand() *cobra.Command {
 	ctrlCmd.Short = "Start the VMware vSphere CSI Driver Operator"
 
 	cmd.AddCommand(ctrlCmd)
+	cmd.AddCommand(NewRenderCommand())
+	cmd.AddCommand(NewDiagnoseCommand())
+	cmd.AddCommand(NewCleanupVCenterCommand())
 
 	return cmd
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	ocpv1 "github.com/openshift/api/config/v1"
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller"
+)
+
+type renderOptions struct {
+	infrastructureFile   string
+	clusterCSIDriverFile string
+	cloudConfigFile      string
+	credentialsFile      string
+	operatorConfigFile   string
+	enabledFeatureGates  []string
+	outputDir            string
+}
+
+// NewRenderCommand creates a command that renders the CSI driver operands from objects in YAML files, without
+// contacting any cluster.
+func NewRenderCommand() *cobra.Command {
+	opts := renderOptions{}
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render the VMware vSphere CSI driver manifests and cloud.conf offline",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.run()
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.infrastructureFile, "infrastructure", "", "YAML file with the cluster Infrastructure")
+	flags.StringVar(&opts.clusterCSIDriverFile, "clustercsidriver", "", "YAML file with the csi.vsphere.vmware.com ClusterCSIDriver")
+	flags.StringVar(&opts.cloudConfigFile, "cloud-config", "", "YAML file with the cloud provider ConfigMap referenced by the Infrastructure")
+	flags.StringVar(&opts.credentialsFile, "credentials", "", "YAML file with the vCenter credentials Secret of the CSI driver")
+	flags.StringVar(&opts.operatorConfigFile, "operator-config", "", "Optional YAML file with the operator config ConfigMap")
+	flags.StringSliceVar(&opts.enabledFeatureGates, "enabled-feature-gates", nil, "Feature gates enabled in the cluster")
+	flags.StringVar(&opts.outputDir, "output-dir", "render", "Directory to write the rendered objects to")
+	for _, name := range []string{"infrastructure", "clustercsidriver", "cloud-config", "credentials"} {
+		cmd.MarkFlagRequired(name)
+	}
+	return cmd
+}
+
+func (o *renderOptions) run() error {
+	inputs := vspherecontroller.RenderInputs{
+		Infrastructure:   &ocpv1.Infrastructure{},
+		ClusterCSIDriver: &operatorapi.ClusterCSIDriver{},
+		CloudConfig:      &corev1.ConfigMap{},
+		Credentials:      &corev1.Secret{},
+	}
+	for _, input := range []struct {
+		file string
+		obj  interface{}
+	}{
+		{o.infrastructureFile, inputs.Infrastructure},
+		{o.clusterCSIDriverFile, inputs.ClusterCSIDriver},
+		{o.cloudConfigFile, inputs.CloudConfig},
+		{o.credentialsFile, inputs.Credentials},
+	} {
+		if err := readYAML(input.file, input.obj); err != nil {
+			return err
+		}
+	}
+	operatorConfig, err := readOperatorConfig(o.operatorConfigFile)
+	if err != nil {
+		return err
+	}
+	inputs.OperatorConfig = operatorConfig
+	inputs.FeatureGates = newFeatureGate(o.enabledFeatureGates)
+
+	objects, err := vspherecontroller.RenderOperands(inputs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.outputDir, 0755); err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := writeYAML(o.outputDir, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readYAML(file string, obj interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("error parsing %s: %v", file, err)
+	}
+	return nil
+}
+
+// readOperatorConfig reads the operator config from a file with the operator config ConfigMap. It returns nil when
+// no file is given.
+func readOperatorConfig(file string) (*utils.OperatorConfig, error) {
+	if file == "" {
+		return nil, nil
+	}
+	operatorConfigMap := &corev1.ConfigMap{}
+	if err := readYAML(file, operatorConfigMap); err != nil {
+		return nil, err
+	}
+	operatorConfig, err := utils.ParseOperatorConfig(operatorConfigMap.Data[utils.OperatorConfigKey])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", file, err)
+	}
+	return operatorConfig, nil
+}
+
+func newFeatureGate(enabledFeatureGates []string) featuregates.FeatureGate {
+	var enabled []ocpv1.FeatureGateName
+	for _, name := range enabledFeatureGates {
+		enabled = append(enabled, ocpv1.FeatureGateName(name))
+	}
+	return featuregates.NewFeatureGate(enabled, nil)
+}
+
+// writeYAML writes the object to <kind>-<name>.yaml in the given directory.
+func writeYAML(dir string, obj runtime.Object) error {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%s-%s.yaml", strings.ToLower(gvks[0].Kind), accessor.GetName()))
+	fmt.Fprintf(os.Stderr, "Writing %s\n", file)
+	// The Secret contains vCenter credentials
+	return os.WriteFile(file, data, 0600)
+}
@@ -5,7 +5,6 @@ import (
 	"strings"
 	"time"
 
-	cfgv1 "github.com/openshift/api/config/v1"
 	opv1 "github.com/openshift/api/operator/v1"
 	configinformers "github.com/openshift/client-go/config/informers/externalversions"
 	infralister "github.com/openshift/client-go/config/listers/config/v1"
@@ -14,13 +13,12 @@ import (
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
-	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
 	"github.com/openshift/library-go/pkg/operator/v1helpers"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 	corelister "k8s.io/client-go/listers/core/v1"
-	"k8s.io/klog/v2"
 )
 
 type DriverFeaturesController struct {
@@ -32,6 +30,8 @@ type DriverFeaturesController struct {
 	configMapLister        corelister.ConfigMapLister
 	infraLister            infralister.InfrastructureLister
 	clusterCSIDriverLister clustercsidriverlister.ClusterCSIDriverLister
+	// results of node checks of the vSphere controller, they decide features that depend on node VMs
+	nodeStatuses *checks.NodeStatuses
 }
 
 func NewDriverFeaturesController(
@@ -43,6 +43,7 @@ func NewDriverFeaturesController(
 	operatorClient v1helpers.OperatorClient,
 	configInformer configinformers.SharedInformerFactory,
 	clusterCSIDriverInformer clustercsidriverinformer.ClusterCSIDriverInformer,
+	nodeStatuses *checks.NodeStatuses,
 	recorder events.Recorder,
 ) factory.Controller {
 	configMapInformer := kubeInformers.InformersFor(namespace).Core().V1().ConfigMaps()
@@ -56,6 +57,7 @@ func NewDriverFeaturesController(
 		operatorClient:         operatorClient,
 		infraLister:            infraInformer.Lister(),
 		clusterCSIDriverLister: clusterCSIDriverInformer.Lister(),
+		nodeStatuses:           nodeStatuses,
 	}
 	return factory.New().WithInformers(
 		configMapInformer.Informer(),
@@ -96,30 +98,8 @@ func (d DriverFeaturesController) Sync(ctx context.Context, controllerContext fa
 		return err
 	}
 
-	defaultFeatureConfigMap := resourceread.ReadConfigMapV1OrDie(d.manifest)
-
-	topologyCategories := utils.GetTopologyCategories(clusterCSIDriver, infra)
-	if len(topologyCategories) > 0 {
-		defaultFeatureConfigMap.Data["improved-volume-topology"] = "true"
-	}
-
-	if !isCSIMigrationSupported(infra) {
-		klog.V(4).Infof("Disabling CSI migration")
-		defaultFeatureConfigMap.Data["csi-migration"] = "false"
-	}
+	defaultFeatureConfigMap := utils.RenderFeatureConfigMap(d.manifest, clusterCSIDriver, infra, d.nodeStatuses.ExtendedVolumeLimit())
 
 	_, _, err = resourceapply.ApplyConfigMap(ctx, d.kubeClient.CoreV1(), controllerContext.Recorder(), defaultFeatureConfigMap)
 	return err
 }
-
-func isCSIMigrationSupported(infra *cfgv1.Infrastructure) bool {
-	// CSI migration is supported on all vSphere clusters unless they have 2 or more vCenters
-	if infra.Spec.PlatformSpec.VSphere == nil {
-		// Assume a default vSphere configuration with 1 vCenter
-		return true
-	}
-	if len(infra.Spec.PlatformSpec.VSphere.VCenters) > 1 {
-		return false
-	}
-	return true
-}
@@ -2,6 +2,7 @@ package operator
 
 import (
 	"context"
+	"fmt"
 	"maps"
 	"reflect"
 	"testing"
@@ -13,6 +14,7 @@ import (
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/assets"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/clock"
@@ -116,6 +118,7 @@ func TestDriverFeaturesController_Sync(t *testing.T) {
 		"improved-csi-idempotency":          "true",
 		"improved-volume-topology":          "false",
 		"list-volumes":                      "true",
+		"max-pvscsi-targets-per-vm":         "false",
 		"multi-vcenter-csi-topology":        "true",
 		"online-volume-extend":              "true",
 		"pv-to-backingdiskobjectid-mapping": "false",
@@ -128,6 +131,7 @@ func TestDriverFeaturesController_Sync(t *testing.T) {
 		name             string
 		infra            *cfgv1.Infrastructure
 		clusterCSIDriver *opv1.ClusterCSIDriver
+		nodeVolumeLimits []int
 		expectedFeatures map[string]string
 	}{
 		{
@@ -150,6 +154,18 @@ func TestDriverFeaturesController_Sync(t *testing.T) {
 			infra:            infraWithVCenters,
 			clusterCSIDriver: csiDriver,
 			expectedFeatures: setFeature(defaultFeatureGates, "csi-migration", "false"),
+		}, {
+			name:             "nodes with default volume limit",
+			infra:            infra,
+			clusterCSIDriver: csiDriver,
+			nodeVolumeLimits: []int{59, 122},
+			expectedFeatures: defaultFeatureGates,
+		}, {
+			name:             "nodes with extended volume limit",
+			infra:            infra,
+			clusterCSIDriver: csiDriver,
+			nodeVolumeLimits: []int{122, 185},
+			expectedFeatures: setFeature(defaultFeatureGates, "max-pvscsi-targets-per-vm", "true"),
 		},
 	}
 
@@ -168,6 +184,15 @@ func TestDriverFeaturesController_Sync(t *testing.T) {
 				t.Fatalf("failed to parse vsphere_features_config.yaml: %v", err)
 			}
 
+			nodeStatuses := checks.NewNodeStatuses()
+			if test.nodeVolumeLimits != nil {
+				saved := map[string]checks.SavedNodeStatus{}
+				for i, limit := range test.nodeVolumeLimits {
+					saved[fmt.Sprintf("node%d", i)] = checks.SavedNodeStatus{VolumeLimit: checks.NodeVolumeLimit{Limit: limit}}
+				}
+				nodeStatuses.Restore(saved)
+			}
+
 			recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
 			d := NewDriverFeaturesController(
 				"test",
@@ -178,6 +203,7 @@ func TestDriverFeaturesController_Sync(t *testing.T) {
 				commonAPIClient.OperatorClient,
 				commonAPIClient.ConfigInformers,
 				commonAPIClient.ClusterCSIDriverInformer,
+				nodeStatuses,
 				recorder,
 			)
 			stopCh := make(chan struct{})
@@ -8,6 +8,7 @@ import (
 
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -135,12 +136,15 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		return err
 	}
 
+	// Node checks of the vSphere controller decide features of the CSI driver
+	nodeStatuses := checks.NewNodeStatuses()
 	vSphereController := vspherecontroller.NewVSphereController(
 		"VMwareVSphereController",
 		utils.DefaultNamespace,
 		commonAPIClient,
 		csiConfigBytes,
 		cloudConfigBytes,
+		nodeStatuses,
 		controllerConfig.EventRecorder,
 		featureGates)
 
@@ -158,6 +162,7 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		operatorClient,
 		configInformers,
 		clusterCSIDriverInformer,
+		nodeStatuses,
 		controllerConfig.EventRecorder,
 	)
 
@@ -0,0 +1,423 @@
+package storageclasscontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/vmware/govmomi/cns"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	detach_tag_api      = "detach_tag"
+	delete_tag_api      = "delete_tag"
+	delete_category_api = "delete_category"
+	delete_profile_api  = "delete_profile"
+	delete_volume_api   = "delete_volume"
+
+	// cnsQueryLimit is the number of volumes read from CNS at once
+	cnsQueryLimit = 100
+)
+
+// cnsVolumeInterface finds and deletes CNS volumes.
+type cnsVolumeInterface interface {
+	// queryVolumes returns all volumes with metadata of the given cluster
+	queryVolumes(ctx context.Context, clusterID string) ([]cnstypes.CnsVolume, error)
+	deleteVolume(ctx context.Context, volumeID string) error
+}
+
+type cnsVolumeAPI struct {
+	connection *vclib.VSphereConnection
+}
+
+var _ cnsVolumeInterface = &cnsVolumeAPI{}
+
+func (c *cnsVolumeAPI) client(ctx context.Context) (*cns.Client, error) {
+	if c.connection.CnsClient() == nil {
+		if err := c.connection.LoginToCNS(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c.connection.CnsClient(), nil
+}
+
+func (c *cnsVolumeAPI) queryVolumes(ctx context.Context, clusterID string) ([]cnstypes.CnsVolume, error) {
+	client, err := c.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter := cnstypes.CnsQueryFilter{
+		ContainerClusterIds: []string{clusterID},
+		Cursor:              &cnstypes.CnsCursor{Limit: cnsQueryLimit},
+	}
+	var volumes []cnstypes.CnsVolume
+	for {
+		result, err := client.QueryVolume(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("error querying CNS volumes of cluster %s: %v", clusterID, err)
+		}
+		volumes = append(volumes, result.Volumes...)
+		if len(result.Volumes) == 0 || result.Cursor.Offset >= result.Cursor.TotalRecords {
+			return volumes, nil
+		}
+		filter.Cursor = &cnstypes.CnsCursor{Offset: result.Cursor.Offset, Limit: cnsQueryLimit}
+	}
+}
+
+func (c *cnsVolumeAPI) deleteVolume(ctx context.Context, volumeID string) error {
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	task, err := client.DeleteVolume(ctx, []cnstypes.CnsVolumeId{{Id: volumeID}}, true)
+	if err != nil {
+		return err
+	}
+	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	if err != nil {
+		return err
+	}
+	result, err := cns.GetTaskResult(ctx, taskInfo)
+	if err != nil {
+		return err
+	}
+	if fault := result.GetCnsVolumeOperationResult().Fault; fault != nil {
+		return fmt.Errorf("%s", fault.LocalizedMessage)
+	}
+	return nil
+}
+
+// VCenterCleaner removes the tag category, the tag, the storage policy and CNS volumes that were created in
+// a vCenter for a cluster that no longer exists.
+//
+// Existence of a cluster is decided from the tag the installer attaches to VMs of the cluster. Clusters without
+// the tag, e.g. UPI clusters, clusters with VMs in another vCenter or clusters that are not OpenShift, can't be
+// told apart from removed ones, so the cleaner fails closed: it keeps volumes of other clusters unless they're
+// listed as decommissioned, and it refuses to clean up an untagged cluster that still has VMs or volumes unless
+// the cluster itself is listed as decommissioned.
+type VCenterCleaner struct {
+	api       *storagePolicyAPI
+	volumeAPI cnsVolumeInterface
+	clusterID string
+	// decommissioned are IDs of clusters that are known to be removed
+	decommissioned sets.Set[string]
+	tagCache       map[string]clusterTagState
+}
+
+// clusterTagState is what vCenter knows about a cluster from the tag the installer attaches to its VMs.
+type clusterTagState struct {
+	// tagFound is true when vCenter has the tag of the cluster
+	tagFound bool
+	// hasVMs is true when the tag is attached to a VM
+	hasVMs bool
+}
+
+// NewVCenterCleaner returns a cleaner of objects of the cluster with the given infrastructure ID. It uses the
+// default names of the tag category, the tag and the storage policy. decommissionedClusters are infrastructure IDs
+// of clusters that are known to be removed, their CNS volumes can be deleted.
+func NewVCenterCleaner(connection *vclib.VSphereConnection, infrastructureID string, decommissionedClusters []string) *VCenterCleaner {
+	infra := &v1.Infrastructure{Status: v1.InfrastructureStatus{InfrastructureName: infrastructureID}}
+	return &VCenterCleaner{
+		api: &storagePolicyAPI{
+			vcenterApiConnection: connection,
+			infra:                infra,
+			categoryName:         fmt.Sprintf(categoryNameTemplate, infrastructureID),
+			policyName:           fmt.Sprintf(policyNameTemplate, infrastructureID),
+			tagName:              infrastructureID,
+			apiTestInfo:          map[string]int{},
+		},
+		volumeAPI:      &cnsVolumeAPI{connection: connection},
+		clusterID:      infrastructureID,
+		decommissioned: sets.New[string](decommissionedClusters...),
+	}
+}
+
+// Plan returns the changes Cleanup would make without making them, and CNS volumes that won't be deleted
+// because another cluster that is not known to be removed uses them.
+func (c *VCenterCleaner) Plan(ctx context.Context) ([]string, []string, error) {
+	c.api.setDryRun(true)
+	defer c.api.setDryRun(false)
+	kept, err := c.run(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var changes []string
+	for _, change := range c.api.getPlannedChanges() {
+		changes = append(changes, change.String())
+	}
+	return changes, kept, nil
+}
+
+// Cleanup deletes objects of the cluster from vCenter.
+func (c *VCenterCleaner) Cleanup(ctx context.Context) error {
+	c.api.setDryRun(false)
+	_, err := c.run(ctx)
+	return err
+}
+
+func (c *VCenterCleaner) run(ctx context.Context) ([]string, error) {
+	c.tagCache = map[string]clusterTagState{}
+	volumes, err := c.volumeAPI.queryVolumes(ctx, c.clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkClusterRemoved(ctx, len(volumes)); err != nil {
+		return nil, err
+	}
+
+	// Delete volumes first, they may use the storage policy
+	kept, err := c.cleanupVolumes(ctx, volumes)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cleanupTags(ctx); err != nil {
+		return nil, err
+	}
+	found, err := c.api.checkForExistingPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if found && c.api.mutate(delete_profile_api, "delete storage policy %s", c.api.policyName) {
+		if err := c.api.deleteStoragePolicy(ctx); err != nil {
+			return nil, fmt.Errorf("error deleting storage policy %s: %v", c.api.policyName, err)
+		}
+		klog.V(2).Infof("Deleted storage policy %s", c.api.policyName)
+	}
+	return kept, nil
+}
+
+// checkClusterRemoved returns an error when the cleaned up cluster may still exist: when its tag is attached to
+// VMs, or when vCenter has no tag of the cluster and VMs named after the cluster or volumes of the cluster still
+// exist.
+func (c *VCenterCleaner) checkClusterRemoved(ctx context.Context, volumes int) error {
+	hostname := c.api.vcenterApiConnection.Hostname
+	state, err := c.getClusterTagState(ctx, c.clusterID)
+	if err != nil {
+		return err
+	}
+	if state.hasVMs {
+		return fmt.Errorf("cluster %s still has virtual machines in vCenter %s, refusing to clean it up", c.clusterID, hostname)
+	}
+	if state.tagFound {
+		return nil
+	}
+
+	// Without the tag, VMs of the cluster can be found only by the names the installer gives them
+	vms, err := c.findClusterVMs(ctx)
+	if err != nil {
+		return err
+	}
+	if len(vms) > 0 {
+		return fmt.Errorf("vCenter %s has no tag of cluster %s, but it has virtual machines %s named after the cluster, refusing to clean it up",
+			hostname, c.clusterID, strings.Join(vms, ", "))
+	}
+	if volumes > 0 && !c.decommissioned.Has(c.clusterID) {
+		return fmt.Errorf("vCenter %s has no tag of cluster %s, it can't decide whether the cluster with %d CNS volume(s) still exists; list the cluster as decommissioned to clean it up",
+			hostname, c.clusterID, volumes)
+	}
+	return nil
+}
+
+// findClusterVMs returns names of VMs that start with the cluster ID, like the installer names them.
+func (c *VCenterCleaner) findClusterVMs(ctx context.Context) ([]string, error) {
+	vimClient := c.api.vcenterApiConnection.VimClient()
+	viewManager := view.NewManager(vimClient)
+	containerView, err := viewManager.CreateContainerView(ctx, vimClient.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, fmt.Errorf("error listing virtual machines: %v", err)
+	}
+	defer func() {
+		if err := containerView.Destroy(ctx); err != nil {
+			klog.Errorf("error destroying view of virtual machines: %v", err)
+		}
+	}()
+	var vms []mo.VirtualMachine
+	if err := containerView.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name"}, &vms); err != nil {
+		return nil, fmt.Errorf("error listing virtual machines: %v", err)
+	}
+	var names []string
+	for _, vm := range vms {
+		if strings.HasPrefix(vm.Name, c.clusterID+"-") {
+			names = append(names, vm.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// cleanupVolumes deletes CNS volumes of the cluster. Volumes shared with a cluster that is not known to be removed
+// are kept, they're returned with the reason.
+func (c *VCenterCleaner) cleanupVolumes(ctx context.Context, volumes []cnstypes.CnsVolume) ([]string, error) {
+	var kept []string
+	for _, volume := range volumes {
+		volumeID := volume.VolumeId.Id
+		liveClusters, err := c.liveClusters(ctx, volume.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		if len(liveClusters) > 0 {
+			kept = append(kept, fmt.Sprintf("%s: CNS volume %s (%s) is used by cluster(s) %s that are not known to be decommissioned",
+				c.api.vcenterApiConnection.Hostname, volumeID, volume.Name, strings.Join(liveClusters, ", ")))
+			continue
+		}
+		if !c.api.mutate(delete_volume_api, "delete CNS volume %s (%s)", volumeID, volume.Name) {
+			continue
+		}
+		if err := c.volumeAPI.deleteVolume(ctx, volumeID); err != nil {
+			return nil, fmt.Errorf("error deleting CNS volume %s: %v", volumeID, err)
+		}
+		klog.V(2).Infof("Deleted CNS volume %s", volumeID)
+	}
+	return kept, nil
+}
+
+// liveClusters returns clusters other than the cleaned up one in the volume metadata that may still exist. Only
+// decommissioned clusters without tagged VMs are known to be removed.
+func (c *VCenterCleaner) liveClusters(ctx context.Context, metadata cnstypes.CnsVolumeMetadata) ([]string, error) {
+	clusterIDs := sets.New[string](metadata.ContainerCluster.ClusterId)
+	for _, cluster := range metadata.ContainerClusterArray {
+		clusterIDs.Insert(cluster.ClusterId)
+	}
+	var live []string
+	for clusterID := range clusterIDs {
+		if clusterID == "" || clusterID == c.clusterID {
+			continue
+		}
+		if !c.decommissioned.Has(clusterID) {
+			live = append(live, clusterID)
+			continue
+		}
+		state, err := c.getClusterTagState(ctx, clusterID)
+		if err != nil {
+			return nil, err
+		}
+		if state.hasVMs {
+			klog.Warningf("Cluster %s is listed as decommissioned, but it still has virtual machines in vCenter %s", clusterID, c.api.vcenterApiConnection.Hostname)
+			live = append(live, clusterID)
+		}
+	}
+	sort.Strings(live)
+	return live, nil
+}
+
+// getClusterTagState finds the tag the installer attaches to VMs of the cluster and VMs with the tag.
+func (c *VCenterCleaner) getClusterTagState(ctx context.Context, clusterID string) (clusterTagState, error) {
+	if state, found := c.tagCache[clusterID]; found {
+		return state, nil
+	}
+	tagManager := tags.NewManager(c.api.vcenterApiConnection.RestClient)
+	_, tag, err := findTag(ctx, tagManager, fmt.Sprintf(categoryNameTemplate, clusterID), clusterID)
+	if err != nil {
+		return clusterTagState{}, err
+	}
+	state := clusterTagState{tagFound: tag != nil}
+	if tag != nil {
+		objects, err := tagManager.ListAttachedObjects(ctx, tag.ID)
+		if err != nil {
+			return clusterTagState{}, fmt.Errorf("error listing objects with tag %s: %v", clusterID, err)
+		}
+		for _, object := range objects {
+			if object.Reference().Type == "VirtualMachine" {
+				state.hasVMs = true
+				break
+			}
+		}
+	}
+	c.tagCache[clusterID] = state
+	return state, nil
+}
+
+// cleanupTags detaches the tag from datastores and deletes the tag and its category. The category is deleted only
+// when it was created by OpenShift and it has no other tags.
+func (c *VCenterCleaner) cleanupTags(ctx context.Context) error {
+	tagManager := tags.NewManager(c.api.vcenterApiConnection.RestClient)
+	category, tag, err := findTag(ctx, tagManager, c.api.categoryName, c.api.tagName)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return nil
+	}
+
+	if tag != nil {
+		objects, err := tagManager.ListAttachedObjects(ctx, tag.ID)
+		if err != nil {
+			return fmt.Errorf("error listing objects with tag %s: %v", tag.Name, err)
+		}
+		for _, object := range objects {
+			ref := object.Reference()
+			if ref.Type != "Datastore" {
+				continue
+			}
+			var ds mo.Datastore
+			if err := property.DefaultCollector(c.api.vcenterApiConnection.VimClient()).RetrieveOne(ctx, ref, []string{"name"}, &ds); err != nil {
+				return fmt.Errorf("error getting name of datastore %s: %v", ref.Value, err)
+			}
+			if !c.api.mutate(detach_tag_api, "detach tag %s from datastore %s", tag.Name, ds.Name) {
+				continue
+			}
+			if err := tagManager.DetachTag(ctx, tag.ID, ref); err != nil {
+				return fmt.Errorf("error detaching tag %s from datastore %s: %v", tag.Name, ds.Name, err)
+			}
+		}
+		if c.api.mutate(delete_tag_api, "delete tag %s", tag.Name) {
+			if err := tagManager.DeleteTag(ctx, tag); err != nil {
+				return fmt.Errorf("error deleting tag %s: %v", tag.Name, err)
+			}
+			klog.V(2).Infof("Deleted tag %s", tag.Name)
+		}
+	}
+
+	if !isOwnedCategory(category, c.clusterID) {
+		klog.Infof("Keeping tag category %s, it was not created by OpenShift", category.Name)
+		return nil
+	}
+	categoryTags, err := tagManager.GetTagsForCategory(ctx, category.ID)
+	if err != nil {
+		return fmt.Errorf("error listing tags in category %s: %v", category.Name, err)
+	}
+	for _, categoryTag := range categoryTags {
+		if tag == nil || categoryTag.ID != tag.ID {
+			klog.Infof("Keeping tag category %s, it contains tag %s", category.Name, categoryTag.Name)
+			return nil
+		}
+	}
+	if c.api.mutate(delete_category_api, "delete tag category %s", category.Name) {
+		if err := tagManager.DeleteCategory(ctx, category); err != nil {
+			return fmt.Errorf("error deleting tag category %s: %v", category.Name, err)
+		}
+		klog.V(2).Infof("Deleted tag category %s", category.Name)
+	}
+	return nil
+}
+
+// findTag returns the tag category and the tag in it, or nil when they don't exist.
+func findTag(ctx context.Context, tagManager *tags.Manager, categoryName, tagName string) (*tags.Category, *tags.Tag, error) {
+	category, err := tagManager.GetCategory(ctx, categoryName)
+	if err != nil {
+		if notFoundError(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("error finding category %s: %v", categoryName, err)
+	}
+	categoryTags, err := tagManager.GetTagsForCategory(ctx, category.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing tags in category %s: %v", categoryName, err)
+	}
+	for i := range categoryTags {
+		if categoryTags[i].Name == tagName {
+			return category, &categoryTags[i], nil
+		}
+	}
+	return category, nil, nil
+}
@@ -0,0 +1,227 @@
+package storageclasscontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vapi/tags"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type fakeVolumeAPI struct {
+	volumes []cnstypes.CnsVolume
+	deleted []string
+}
+
+func (f *fakeVolumeAPI) queryVolumes(ctx context.Context, clusterID string) ([]cnstypes.CnsVolume, error) {
+	return f.volumes, nil
+}
+
+func (f *fakeVolumeAPI) deleteVolume(ctx context.Context, volumeID string) error {
+	f.deleted = append(f.deleted, volumeID)
+	return nil
+}
+
+func makeVolume(id string, clusterIDs ...string) cnstypes.CnsVolume {
+	volume := cnstypes.CnsVolume{VolumeId: cnstypes.CnsVolumeId{Id: id}, Name: "pvc-" + id}
+	volume.Metadata.ContainerCluster.ClusterId = clusterIDs[0]
+	for _, clusterID := range clusterIDs {
+		volume.Metadata.ContainerClusterArray = append(volume.Metadata.ContainerClusterArray, cnstypes.CnsContainerCluster{ClusterId: clusterID})
+	}
+	return volume
+}
+
+// tagVM creates the tag the installer attaches to VMs of a cluster and attaches it to a VM.
+func tagVM(conn *vclib.VSphereConnection, clusterID, vmName string) error {
+	ctx := context.TODO()
+	tagManager := tags.NewManager(conn.RestClient)
+	categoryID, err := tagManager.CreateCategory(ctx, &tags.Category{
+		Name:            fmt.Sprintf(categoryNameTemplate, clusterID),
+		Description:     ownedCategoryDescription,
+		AssociableTypes: appendPrefix(associatedTypesRaw),
+		Cardinality:     "SINGLE",
+	})
+	if err != nil {
+		return err
+	}
+	tagID, err := tagManager.CreateTag(ctx, &tags.Tag{Name: clusterID, CategoryID: categoryID})
+	if err != nil {
+		return err
+	}
+	vm, err := find.NewFinder(conn.Client.Client, true).VirtualMachine(ctx, testlib.DefaultVMPath+vmName)
+	if err != nil {
+		return err
+	}
+	return tagManager.AttachTag(ctx, tagID, vm)
+}
+
+func TestVCenterCleanup(t *testing.T) {
+	infra := testlib.GetInfraObject()
+	clusterID := infra.Status.InfrastructureName
+	connections, cleanUpFunc, err := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	if err != nil {
+		t.Fatalf("error connecting to vcenter: %v", err)
+	}
+	defer cleanUpFunc()
+	conn := connections[0]
+	ctx := context.TODO()
+
+	// Create the objects the operator creates for the cluster
+	storagePolicyAPIClient := NewStoragePolicyAPI(ctx, conn, infra, utils.VCenterConfig{}).(*storagePolicyAPI)
+	if _, err := storagePolicyAPIClient.createStoragePolicy(ctx); err != nil {
+		t.Fatalf("error creating storage policy: %v", err)
+	}
+	// Another cluster that still exists shares a volume with the cluster
+	if err := tagVM(conn, "other-cluster", "DC0_H0_VM0"); err != nil {
+		t.Fatalf("error tagging VM: %v", err)
+	}
+	volumeAPI := &fakeVolumeAPI{volumes: []cnstypes.CnsVolume{
+		makeVolume("orphaned", clusterID),
+		makeVolume("shared", clusterID, "other-cluster"),
+		// Clusters without the tag are not known to be removed, e.g. UPI clusters
+		makeVolume("shared-with-untagged", clusterID, "untagged-cluster"),
+		makeVolume("shared-with-removed", clusterID, "removed-cluster"),
+	}}
+
+	cleaner := NewVCenterCleaner(conn, clusterID, []string{"removed-cluster"})
+	cleaner.volumeAPI = volumeAPI
+	changes, kept, err := cleaner.Plan(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error planning cleanup: %v", err)
+	}
+	expectedChanges := []string{
+		"delete CNS volume orphaned",
+		"delete CNS volume shared-with-removed",
+		"detach tag vsphere from datastore LocalDS_0",
+		"delete tag vsphere",
+		"delete tag category openshift-vsphere",
+		"delete storage policy openshift-storage-policy-vsphere",
+	}
+	if len(changes) != len(expectedChanges) {
+		t.Fatalf("expected %d changes, got %d: %v", len(expectedChanges), len(changes), changes)
+	}
+	for i, expected := range expectedChanges {
+		if !strings.Contains(changes[i], expected) {
+			t.Errorf("expected change %d to %s, got %s", i, expected, changes[i])
+		}
+	}
+	expectedKept := []string{
+		"shared (pvc-shared) is used by cluster(s) other-cluster that are not known to be decommissioned",
+		"shared-with-untagged (pvc-shared-with-untagged) is used by cluster(s) untagged-cluster that are not known to be decommissioned",
+	}
+	if len(kept) != len(expectedKept) {
+		t.Fatalf("expected %d kept volumes, got %d: %v", len(expectedKept), len(kept), kept)
+	}
+	for i, expected := range expectedKept {
+		if !strings.Contains(kept[i], expected) {
+			t.Errorf("expected kept volume %d: %s, got %s", i, expected, kept[i])
+		}
+	}
+	if len(volumeAPI.deleted) != 0 {
+		t.Errorf("expected no volumes to be deleted by the plan, got %v", volumeAPI.deleted)
+	}
+	if found, err := storagePolicyAPIClient.checkForExistingPolicy(ctx); err != nil || !found {
+		t.Errorf("expected storage policy to be kept by the plan, found %v (%v)", found, err)
+	}
+
+	if err := cleaner.Cleanup(ctx); err != nil {
+		t.Fatalf("unexpected error cleaning up: %v", err)
+	}
+	if deleted := sets.New(volumeAPI.deleted...); !deleted.Equal(sets.New("orphaned", "shared-with-removed")) {
+		t.Errorf("unexpected deleted volumes %v", volumeAPI.deleted)
+	}
+	if found, err := storagePolicyAPIClient.checkForExistingPolicy(ctx); err != nil || found {
+		t.Errorf("expected storage policy to be deleted, found %v (%v)", found, err)
+	}
+	category, _, err := findTag(ctx, tags.NewManager(conn.RestClient), fmt.Sprintf(categoryNameTemplate, clusterID), clusterID)
+	if err != nil || category != nil {
+		t.Errorf("expected tag category to be deleted, got %+v (%v)", category, err)
+	}
+
+	// The other cluster still runs, also when it's listed as decommissioned by mistake
+	otherCleaner := NewVCenterCleaner(conn, "other-cluster", []string{"other-cluster"})
+	otherCleaner.volumeAPI = &fakeVolumeAPI{}
+	if _, _, err := otherCleaner.Plan(ctx); err == nil {
+		t.Errorf("expected cleanup of an existing cluster to be refused")
+	}
+}
+
+func TestVCenterCleanupUntaggedCluster(t *testing.T) {
+	connections, cleanUpFunc, err := testlib.SetupSimulator(testlib.DefaultModel, testlib.GetInfraObject())
+	if err != nil {
+		t.Fatalf("error connecting to vcenter: %v", err)
+	}
+	defer cleanUpFunc()
+	conn := connections[0]
+	ctx := context.TODO()
+	vm, err := find.NewFinder(conn.Client.Client, true).VirtualMachine(ctx, testlib.DefaultVMPath+"DC0_H0_VM1")
+	if err != nil {
+		t.Fatalf("error finding VM: %v", err)
+	}
+	task, err := vm.Rename(ctx, "named-cluster-worker-0")
+	if err == nil {
+		err = task.Wait(ctx)
+	}
+	if err != nil {
+		t.Fatalf("error renaming VM: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		clusterID       string
+		decommissioned  []string
+		volumes         []cnstypes.CnsVolume
+		expectedError   bool
+		expectedDeleted []string
+	}{
+		{
+			name:          "volumes of an untagged cluster",
+			clusterID:     "untagged-cluster",
+			volumes:       []cnstypes.CnsVolume{makeVolume("volume", "untagged-cluster")},
+			expectedError: true,
+		},
+		{
+			name:            "volumes of an untagged decommissioned cluster",
+			clusterID:       "untagged-cluster",
+			decommissioned:  []string{"untagged-cluster"},
+			volumes:         []cnstypes.CnsVolume{makeVolume("volume", "untagged-cluster")},
+			expectedDeleted: []string{"volume"},
+		},
+		{
+			name:      "untagged cluster without volumes",
+			clusterID: "untagged-cluster",
+		},
+		{
+			name:           "untagged cluster with VMs named after it",
+			clusterID:      "named-cluster",
+			decommissioned: []string{"named-cluster"},
+			volumes:        []cnstypes.CnsVolume{makeVolume("volume", "named-cluster")},
+			expectedError:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volumeAPI := &fakeVolumeAPI{volumes: test.volumes}
+			cleaner := NewVCenterCleaner(conn, test.clusterID, test.decommissioned)
+			cleaner.volumeAPI = volumeAPI
+			err := cleaner.Cleanup(ctx)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected cleanup to be refused")
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if deleted := sets.New(volumeAPI.deleted...); !deleted.Equal(sets.New(test.expectedDeleted...)) {
+				t.Errorf("expected deleted volumes %v, got %v", test.expectedDeleted, volumeAPI.deleted)
+			}
+		})
+	}
+}
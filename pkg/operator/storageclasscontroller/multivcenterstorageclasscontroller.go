@@ -2,6 +2,9 @@ package storageclasscontroller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	operatorapi "github.com/openshift/api/operator/v1"
@@ -29,30 +32,69 @@ func makeMultiVCenterStorageClassController(abstract AbstractStorageClass) *Mult
 }
 
 func (c *MultiVCenterStorageClassController) Sync(ctx context.Context, connections []*vclib.VSphereConnection, apiDeps checks.KubeAPIInterface) error {
+	c.setBackoff(apiDeps.GetOperatorConfig().GetStoragePolicyBackoff(defaultBackoff))
 	checkResultFunc := func() (checks.ClusterCheckResult, checks.ClusterCheckStatus) {
 		sc := resourceread.ReadStorageClassV1OrDie(c.manifest)
 		scState := c.scStateEvaluator.GetStorageClassState(sc.Provisioner)
 
-		// Iterate through each vcenter connection for storage policy.
-		for _, connection := range connections {
-			klog.V(4).Infof("Syncing %v", connection.Hostname)
-			policyName, syncResult := c.syncStoragePolicy(ctx, connection, apiDeps, scState)
+		c.approvedChanges = nil
+		if c.scStateEvaluator.IsManaged(scState) && c.isVCenterDryRun() && c.storagePolicySyncDue() {
+			pending, reviewResult := c.reviewVCenterChanges(ctx, connections, apiDeps)
+			if reviewResult.CheckError != nil {
+				klog.Errorf("error reviewing vCenter changes: %v", reviewResult.Reason)
+				return reviewResult, checks.ClusterCheckAllGood
+			}
+			if pending {
+				return reviewResult, checks.ClusterCheckAllGood
+			}
+		}
+
+		// Iterate through each vcenter connection for storage policy. A failure in one vCenter does not stop sync
+		// of the others, the storage class is still created for them.
+		syncedPolicyName := ""
+		var failedResults []checks.ClusterCheckResult
+		var failedVCenters []string
+		policyNames, syncResults := c.syncStoragePolicies(ctx, connections, apiDeps, scState)
+		for i, connection := range connections {
+			policyName, syncResult := policyNames[i], syncResults[i]
 			if syncResult.CheckError != nil {
-				klog.Errorf("error syncing storage policy: %v", syncResult.Reason)
-				clusterCondition := "storage_class_sync_failed"
-				utils.InstallErrorMetric.WithLabelValues(string(syncResult.CheckStatus), clusterCondition).Set(1)
-				return syncResult, checks.ClusterCheckAllGood
+				klog.Errorf("error syncing storage policy in vCenter %s: %v", connection.Hostname, syncResult.Reason)
+				failedResults = append(failedResults, syncResult)
+				failedVCenters = append(failedVCenters, connection.Hostname)
+				continue
 			}
 			klog.V(4).Infof("Synced policy %v", policyName)
+			// The storage class references a single policy name, which must be the same in all vCenters.
+			if policyName != "" && syncedPolicyName != "" && policyName != syncedPolicyName {
+				syncResult = makeStoragePolicyErrorResult(newStoragePolicyConfigError(
+					"storage policy name %s in vCenter %s differs from %s used in other vCenters, configure the same policyName for all vCenters in ConfigMap %s/%s",
+					policyName, connection.Hostname, syncedPolicyName, utils.DefaultNamespace, utils.OperatorConfigMapName))
+				c.reportStoragePolicySyncFailure(syncResult)
+				return syncResult, checks.ClusterCheckAllGood
+			}
 			c.connPolicyNames[connection.Hostname] = policyName
+			if policyName != "" {
+				syncedPolicyName = policyName
+			}
 			c.policyName = policyName // This is the global name of policy.  may need to make it more logical to not set in loop.
 		}
 
+		if len(failedResults) == len(connections) && len(failedResults) > 0 {
+			c.reportStoragePolicySyncFailure(failedResults[0])
+			return failedResults[0], checks.ClusterCheckAllGood
+		}
+
 		err := c.syncStorageClass(ctx, scState)
 		if err != nil {
 			klog.Errorf("error syncing storage class: %v", err)
 			return checks.MakeClusterDegradedError(checks.CheckStatusOpenshiftAPIError, err), checks.ClusterCheckDegrade
 		}
+		if len(failedResults) > 0 {
+			syncResult := makePartialSyncResult(failedResults, failedVCenters)
+			c.reportStoragePolicySyncFailure(syncResult)
+			return syncResult, checks.ClusterCheckAllGood
+		}
+		c.reportStoragePolicySyncSuccess()
 		return checks.MakeClusterCheckResultPass(), checks.ClusterCheckAllGood
 	}
 
@@ -60,35 +102,94 @@ func (c *MultiVCenterStorageClassController) Sync(ctx context.Context, connectio
 	return c.updateConditions(ctx, checkResult, overallClusterStatus)
 }
 
-func (c *MultiVCenterStorageClassController) syncStoragePolicy(ctx context.Context, connection *vclib.VSphereConnection, apiDeps checks.KubeAPIInterface, scState operatorapi.StorageClassStateName) (string, checks.ClusterCheckResult) {
+// makePartialSyncResult merges results of vCenters whose storage policy failed to sync, with the most severe result
+// first, so the message lists all affected vCenters.
+func makePartialSyncResult(results []checks.ClusterCheckResult, vCenters []string) checks.ClusterCheckResult {
+	merged := results[0]
+	var reasons []string
+	for i, result := range results {
+		if result.Action > merged.Action {
+			merged = result
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", vCenters[i], result.Reason))
+	}
+	merged.Reason = fmt.Sprintf("storage policy sync failed in %d vCenter(s): %s", len(results), strings.Join(reasons, "; "))
+	merged.CheckError = errors.New(merged.Reason)
+	if len(vCenters) == 1 {
+		merged = merged.WithObject(checks.ObjectKindVCenter, vCenters[0])
+	}
+	return merged
+}
+
+// syncStoragePolicies syncs storage policies in all vCenters in parallel, each vCenter with its own apiTimeout.
+// It returns policy names and results in the order of connections.
+func (c *MultiVCenterStorageClassController) syncStoragePolicies(ctx context.Context, connections []*vclib.VSphereConnection, apiDeps checks.KubeAPIInterface, scState operatorapi.StorageClassStateName) ([]string, []checks.ClusterCheckResult) {
+	policyNames := make([]string, len(connections))
+	results := make([]checks.ClusterCheckResult, len(connections))
+	for i := range results {
+		results[i] = checks.MakeClusterCheckResultPass()
+	}
 	// if the SC is not managed, there is no need to sync the storage policy
 	if !c.scStateEvaluator.IsManaged(scState) {
 		klog.V(2).Info("sc is not managed")
-		return "", checks.MakeClusterCheckResultPass()
+		return policyNames, results
 	}
 
-	// if we are running the checks after creating the policy successfully
-	// then lets run checks less frequently.
-	if !time.Now().After(c.nextCheck) && len(c.connPolicyNames[connection.Hostname]) > 0 {
+	var due []*vclib.VSphereConnection
+	var dueIndexes []int
+	for i, connection := range connections {
+		// if we are running the checks after creating the policy successfully
+		// then lets run checks less frequently.
+		if !time.Now().After(c.nextCheck) && len(c.connPolicyNames[connection.Hostname]) > 0 {
+			policyNames[i] = c.connPolicyNames[connection.Hostname]
+			continue
+		}
+		due = append(due, connection)
+		dueIndexes = append(dueIndexes, i)
+	}
+	if len(due) == 0 {
 		klog.V(4).Infof("Returning without running any checks")
-		return c.connPolicyNames[connection.Hostname], checks.MakeClusterCheckResultPass()
+		return policyNames, results
 	}
 
 	infra := apiDeps.GetInfrastructure()
-	apiClient := c.makeStoragePolicyAPI(ctx, connection, infra)
-
 	// we expect all API calls to finish within apiTimeout or else operator might be stuck
-	tctx, cancel := context.WithTimeout(ctx, apiTimeout)
-	defer cancel()
+	vclib.ForEachConnection(ctx, due, apiTimeout, func(ctx context.Context, i int, connection *vclib.VSphereConnection) error {
+		klog.V(4).Infof("Syncing %v", connection.Hostname)
+		apiClient := c.makeStoragePolicyAPI(ctx, connection, infra, apiDeps.GetOperatorConfig().GetVCenterConfig(connection.Hostname))
+		c.restrictToApprovedChanges(apiClient)
+		policyName, err := apiClient.createStoragePolicy(ctx)
+		if unapprovedErr := c.checkUnapprovedChanges(apiClient); unapprovedErr != nil {
+			err = unapprovedErr
+		}
+		if err != nil {
+			results[dueIndexes[i]] = makeStoragePolicyErrorResult(err)
+			return err
+		}
+		policyNames[dueIndexes[i]] = policyName
+		return nil
+	})
 
-	policyName, err := apiClient.createStoragePolicy(tctx)
+	c.scheduleNextSync()
+	return policyNames, results
+}
 
-	nextRunDelay := c.backoff.Step()
-	c.lastCheck = time.Now()
-	c.nextCheck = c.lastCheck.Add(nextRunDelay)
+func (c *MultiVCenterStorageClassController) GetState() (StoragePolicySyncState, bool) {
+	state, found := c.AbstractStorageClass.GetState()
+	if !found {
+		return state, false
+	}
+	state.VCenterPolicyNames = make(map[string]string, len(c.connPolicyNames))
+	for vCenter, policyName := range c.connPolicyNames {
+		state.VCenterPolicyNames[vCenter] = policyName
+	}
+	return state, true
+}
 
-	if err != nil {
-		return "", checks.MakeGenericVCenterAPIError(err)
+func (c *MultiVCenterStorageClassController) RestoreState(state StoragePolicySyncState) {
+	c.AbstractStorageClass.RestoreState(state)
+	c.connPolicyNames = make(map[string]string, len(state.VCenterPolicyNames))
+	for vCenter, policyName := range state.VCenterPolicyNames {
+		c.connPolicyNames[vCenter] = policyName
 	}
-	return policyName, checks.MakeClusterCheckResultPass()
 }
@@ -2,6 +2,7 @@ package storageclasscontroller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"strings"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/openshift/api/features"
 	clustercsidriverinformer "github.com/openshift/client-go/operator/informers/externalversions/operator/v1"
+	clustercsidriverlister "github.com/openshift/client-go/operator/listers/operator/v1"
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
 	csiscc "github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
 	"github.com/openshift/library-go/pkg/operator/events"
@@ -23,6 +25,9 @@ import (
 	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
 	"github.com/openshift/library-go/pkg/operator/v1helpers"
 
+	storageapi "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	storagev1 "k8s.io/client-go/listers/storage/v1"
@@ -32,6 +37,9 @@ import (
 const (
 	DatastoreInfoProperty = "info"
 	SummaryProperty       = "summary"
+
+	// storagePolicySyncFailedCondition is the condition label of InstallErrorMetric for failed storage policy syncs.
+	storagePolicySyncFailedCondition = "storage_class_sync_failed"
 )
 
 var (
@@ -49,24 +57,57 @@ var (
 type StorageClassSyncInterface interface {
 	Sync(ctx context.Context, connection []*vclib.VSphereConnection, apiDeps checks.KubeAPIInterface) error
 	SyncRemove(ctx context.Context) error
+	// GetState returns the schedule of the storage policy sync. It returns false when no sync ran yet.
+	GetState() (StoragePolicySyncState, bool)
+	// RestoreState resumes the schedule saved by a previous instance of the operator.
+	RestoreState(state StoragePolicySyncState)
+	// ResetBackoff makes the next Sync sync storage policies in all vCenters.
+	ResetBackoff()
+	// LastResult returns the result of the last Sync.
+	LastResult() checks.ClusterCheckResult
+}
+
+// StoragePolicySyncState is the schedule of the storage policy sync. It's saved with the cluster check state, so
+// a restarted operator does not sync storage policies in all vCenters at once.
+type StoragePolicySyncState struct {
+	LastSync metav1.Time `json:"lastSync"`
+	NextSync metav1.Time `json:"nextSync"`
+	// BackoffSteps is the number of syncs since the backoff was reset
+	BackoffSteps int `json:"backoffSteps,omitempty"`
+	// PolicyName is the storage policy of the storage class
+	PolicyName string `json:"policyName,omitempty"`
+	// VCenterPolicyNames are the storage policies synced in each vCenter
+	VCenterPolicyNames map[string]string `json:"vCenterPolicyNames,omitempty"`
 }
 
 type AbstractStorageClass struct {
 	StorageClassSyncInterface
-	name                 string
-	targetNamespace      string
-	manifest             []byte
-	kubeClient           kubernetes.Interface
-	operatorClient       v1helpers.OperatorClient
-	storageClassLister   storagev1.StorageClassLister
-	recorder             events.Recorder
-	makeStoragePolicyAPI func(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure) vCenterInterface
-	scStateEvaluator     *csiscc.StorageClassStateEvaluator
+	name                   string
+	targetNamespace        string
+	manifest               []byte
+	kubeClient             kubernetes.Interface
+	operatorClient         v1helpers.OperatorClient
+	storageClassLister     storagev1.StorageClassLister
+	recorder               events.Recorder
+	makeStoragePolicyAPI   func(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure, vCenterConfig utils.VCenterConfig) vCenterInterface
+	scStateEvaluator       *csiscc.StorageClassStateEvaluator
+	clusterCSIDriverLister clustercsidriverlister.ClusterCSIDriverLister
 
 	policyName string
-	backoff    wait.Backoff
-	nextCheck  time.Time
-	lastCheck  time.Time
+	// baseBackoff is the configured backoff, backoff is its state after backoffSteps syncs
+	baseBackoff  wait.Backoff
+	backoff      wait.Backoff
+	backoffSteps int
+	nextCheck    time.Time
+	lastCheck    time.Time
+
+	// hashes of the last vCenter change plans reported for approval and approved, used to emit events only once
+	lastPlanHash         string
+	lastApprovedPlanHash string
+	// approvedChanges are the changes reviewed in the current sync in dry-run mode, nil without dry-run
+	approvedChanges []vCenterChange
+	// lastResult is the result of the last Sync
+	lastResult checks.ClusterCheckResult
 }
 
 type StorageClassController struct{ AbstractStorageClass }
@@ -96,17 +137,19 @@ func NewStorageClassController(
 
 	var c StorageClassSyncInterface
 	scc := AbstractStorageClass{
-		name:                 name,
-		targetNamespace:      targetNamespace,
-		manifest:             manifest,
-		kubeClient:           kubeClient,
-		operatorClient:       operatorClient,
-		storageClassLister:   storageClassLister,
-		recorder:             recorder,
-		makeStoragePolicyAPI: NewStoragePolicyAPI,
-		scStateEvaluator:     evaluator,
-		backoff:              defaultBackoff,
-		nextCheck:            time.Now(),
+		name:                   name,
+		targetNamespace:        targetNamespace,
+		manifest:               manifest,
+		kubeClient:             kubeClient,
+		operatorClient:         operatorClient,
+		storageClassLister:     storageClassLister,
+		recorder:               recorder,
+		makeStoragePolicyAPI:   NewStoragePolicyAPI,
+		scStateEvaluator:       evaluator,
+		clusterCSIDriverLister: clusterCSIDriverInformer.Lister(),
+		baseBackoff:            defaultBackoff,
+		backoff:                defaultBackoff,
+		nextCheck:              time.Now(),
 	}
 	if featureGates.Enabled(features.FeatureGateVSphereMultiVCenters) {
 		klog.V(2).Infof("Creating multi vcenter storage class controller")
@@ -120,20 +163,32 @@ func NewStorageClassController(
 }
 
 func (c *StorageClassController) Sync(ctx context.Context, connections []*vclib.VSphereConnection, apiDeps checks.KubeAPIInterface) error {
+	c.setBackoff(apiDeps.GetOperatorConfig().GetStoragePolicyBackoff(defaultBackoff))
 	checkResultFunc := func() (checks.ClusterCheckResult, checks.ClusterCheckStatus) {
 		sc := resourceread.ReadStorageClassV1OrDie(c.manifest)
 		scState := c.scStateEvaluator.GetStorageClassState(sc.Provisioner)
 
+		c.approvedChanges = nil
+		if c.scStateEvaluator.IsManaged(scState) && c.isVCenterDryRun() && c.storagePolicySyncDue() {
+			pending, reviewResult := c.reviewVCenterChanges(ctx, connections[:1], apiDeps)
+			if reviewResult.CheckError != nil {
+				klog.Errorf("error reviewing vCenter changes: %v", reviewResult.Reason)
+				return reviewResult, checks.ClusterCheckAllGood
+			}
+			if pending {
+				return reviewResult, checks.ClusterCheckAllGood
+			}
+		}
+
 		// This storage class controller only handles single vCenter.  It will only use the first connection (there should never be
 		// more than 1)
 		policyName, syncResult := c.syncStoragePolicy(ctx, connections[0], apiDeps, scState)
 		if syncResult.CheckError != nil {
-			klog.Errorf("error syncing storage policy: %v", syncResult.Reason)
-			clusterCondition := "storage_class_sync_failed"
-			utils.InstallErrorMetric.WithLabelValues(string(syncResult.CheckStatus), clusterCondition).Set(1)
+			c.reportStoragePolicySyncFailure(syncResult)
 			return syncResult, checks.ClusterCheckAllGood
 		}
 		c.policyName = policyName
+		c.reportStoragePolicySyncSuccess()
 
 		err := c.syncStorageClass(ctx, scState)
 		if err != nil {
@@ -162,31 +217,141 @@ func (c *AbstractStorageClass) syncStoragePolicy(ctx context.Context, connection
 
 	// if we are running the checks after creating the policy successfully
 	// then lets run checks less frequently.
-	if !time.Now().After(c.nextCheck) && len(c.policyName) > 0 {
+	if !c.storagePolicySyncDue() {
 		klog.V(4).Infof("Returning without running any checks")
 		return c.policyName, checks.MakeClusterCheckResultPass()
 	}
 
 	infra := apiDeps.GetInfrastructure()
-	apiClient := c.makeStoragePolicyAPI(ctx, connection, infra)
+	apiClient := c.makeStoragePolicyAPI(ctx, connection, infra, apiDeps.GetOperatorConfig().GetVCenterConfig(connection.Hostname))
 
 	// we expect all API calls to finish within apiTimeout or else operator might be stuck
 	tctx, cancel := context.WithTimeout(ctx, apiTimeout)
 	defer cancel()
 
+	c.restrictToApprovedChanges(apiClient)
 	policyName, err := apiClient.createStoragePolicy(tctx)
+	if unapprovedErr := c.checkUnapprovedChanges(apiClient); unapprovedErr != nil {
+		err = unapprovedErr
+	}
+
+	c.scheduleNextSync()
+
+	if err != nil {
+		return "", makeStoragePolicyErrorResult(err)
+	}
+	return policyName, checks.MakeClusterCheckResultPass()
+}
+
+// reportStoragePolicySyncFailure logs the failed storage policy sync and exposes it in metrics, replacing failures
+// of previous syncs. Configuration errors are reported also as events, because only the user can fix them.
+func (c *AbstractStorageClass) reportStoragePolicySyncFailure(syncResult checks.ClusterCheckResult) {
+	klog.Errorf("error syncing storage policy: %v", syncResult.Reason)
+	utils.ClearInstallErrors(storagePolicySyncFailedCondition)
+	utils.SetInstallError(string(syncResult.CheckStatus), storagePolicySyncFailedCondition)
+	if syncResult.CheckStatus == checks.CheckStatusStoragePolicyConfig {
+		c.recorder.Warningf(string(syncResult.CheckStatus), "Unable to sync storage policy: %s", syncResult.Reason)
+	}
+}
 
+// reportStoragePolicySyncSuccess removes failures of previous syncs from metrics, so their alert stops firing.
+func (c *AbstractStorageClass) reportStoragePolicySyncSuccess() {
+	utils.ClearInstallErrors(storagePolicySyncFailedCondition)
+}
+
+// makeStoragePolicyErrorResult converts an error from storage policy sync to a check result.
+func makeStoragePolicyErrorResult(err error) checks.ClusterCheckResult {
+	if isStoragePolicyConfigError(err) {
+		return checks.MakeClusterUnupgradeableError(checks.CheckStatusStoragePolicyConfig, err)
+	}
+	return checks.MakeGenericVCenterAPIError(err)
+}
+
+// isStoragePolicyConfigError returns true if the error or any of aggregated errors is StoragePolicyConfigError.
+func isStoragePolicyConfigError(err error) bool {
+	var configErr *StoragePolicyConfigError
+	if errors.As(err, &configErr) {
+		return true
+	}
+	var aggregate utilerrors.Aggregate
+	if errors.As(err, &aggregate) {
+		for _, e := range aggregate.Errors() {
+			if isStoragePolicyConfigError(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setBackoff changes timing of the storage policy sync. The number of syncs since the backoff was reset is kept,
+// the next sync is moved earlier when the new backoff is shorter.
+func (c *AbstractStorageClass) setBackoff(backoff wait.Backoff) {
+	if backoff == c.baseBackoff {
+		return
+	}
+	klog.V(2).Infof("Storage policy sync backoff changed to %s doubling by %v up to %s", backoff.Duration, backoff.Factor, backoff.Cap)
+	c.baseBackoff = backoff
+	c.rebuildBackoff()
+	if nextCheck := c.lastCheck.Add(c.backoff.Duration); nextCheck.Before(c.nextCheck) {
+		c.nextCheck = nextCheck
+	}
+}
+
+// scheduleNextSync schedules the next storage policy sync after a sync.
+func (c *AbstractStorageClass) scheduleNextSync() {
 	nextRunDelay := c.backoff.Step()
+	c.backoffSteps++
 	c.lastCheck = time.Now()
 	c.nextCheck = c.lastCheck.Add(nextRunDelay)
+}
 
-	if err != nil {
-		return "", checks.MakeGenericVCenterAPIError(err)
+// rebuildBackoff steps baseBackoff as many times as there were syncs since the backoff was reset.
+func (c *AbstractStorageClass) rebuildBackoff() {
+	c.backoff = c.baseBackoff
+	for i := 0; i < c.backoffSteps && c.backoff.Duration < c.backoff.Cap; i++ {
+		c.backoff.Step()
 	}
-	return policyName, checks.MakeClusterCheckResultPass()
+}
+
+func (c *AbstractStorageClass) ResetBackoff() {
+	c.backoff = c.baseBackoff
+	c.backoffSteps = 0
+	c.nextCheck = time.Now()
+}
+
+func (c *AbstractStorageClass) GetState() (StoragePolicySyncState, bool) {
+	if c.lastCheck.IsZero() {
+		return StoragePolicySyncState{}, false
+	}
+	return StoragePolicySyncState{
+		LastSync:     metav1.NewTime(c.lastCheck),
+		NextSync:     metav1.NewTime(c.nextCheck),
+		BackoffSteps: c.backoffSteps,
+		PolicyName:   c.policyName,
+	}, true
+}
+
+func (c *AbstractStorageClass) RestoreState(state StoragePolicySyncState) {
+	c.lastCheck = state.LastSync.Time
+	c.nextCheck = state.NextSync.Time
+	c.backoffSteps = state.BackoffSteps
+	c.policyName = state.PolicyName
+	c.rebuildBackoff()
+}
+
+// storagePolicySyncDue returns true when the storage policy has not been synced yet or when it is time
+// to re-check it.
+func (c *AbstractStorageClass) storagePolicySyncDue() bool {
+	return time.Now().After(c.nextCheck) || len(c.policyName) == 0
+}
+
+func (c *AbstractStorageClass) LastResult() checks.ClusterCheckResult {
+	return c.lastResult
 }
 
 func (c *AbstractStorageClass) updateConditions(ctx context.Context, lastCheckResult checks.ClusterCheckResult, clusterStatus checks.ClusterCheckStatus) error {
+	c.lastResult = lastCheckResult
 	availableCnd := operatorapi.OperatorCondition{
 		Type:   c.name + operatorapi.OperatorStatusTypeAvailable,
 		Status: operatorapi.ConditionTrue,
@@ -224,15 +389,7 @@ func (c *AbstractStorageClass) updateConditions(ctx context.Context, lastCheckRe
 }
 
 func (c *AbstractStorageClass) syncStorageClass(ctx context.Context, scState operatorapi.StorageClassStateName) error {
-	scString := string(c.manifest)
-	pairs := []string{
-		"${STORAGE_POLICY_NAME}", c.policyName,
-	}
-
-	policyReplacer := strings.NewReplacer(pairs...)
-	scString = policyReplacer.Replace(scString)
-
-	sc := resourceread.ReadStorageClassV1OrDie([]byte(scString))
+	sc := renderStorageClass(c.manifest, c.policyName)
 	err := csiscc.SetDefaultStorageClass(c.storageClassLister, sc)
 	if err != nil {
 		return err
@@ -240,3 +397,26 @@ func (c *AbstractStorageClass) syncStorageClass(ctx context.Context, scState ope
 
 	return c.scStateEvaluator.ApplyStorageClass(ctx, sc, scState)
 }
+
+func renderStorageClass(manifest []byte, policyName string) *storageapi.StorageClass {
+	pairs := []string{
+		"${STORAGE_POLICY_NAME}", policyName,
+	}
+
+	policyReplacer := strings.NewReplacer(pairs...)
+	scString := policyReplacer.Replace(string(manifest))
+	return resourceread.ReadStorageClassV1OrDie([]byte(scString))
+}
+
+// RenderStorageClass returns the StorageClass with the name of the storage policy that the operator creates in
+// vCenter, without connecting to vCenter.
+func RenderStorageClass(manifest []byte, infra *v1.Infrastructure, operatorConfig *utils.OperatorConfig) *storageapi.StorageClass {
+	policyName := fmt.Sprintf(policyNameTemplate, infra.Status.InfrastructureName)
+	if vSphere := infra.Spec.PlatformSpec.VSphere; vSphere != nil && len(vSphere.VCenters) > 0 {
+		// All vCenters must use the same policy name
+		if name := operatorConfig.GetVCenterConfig(vSphere.VCenters[0].Server).PolicyName; name != "" {
+			policyName = name
+		}
+	}
+	return renderStorageClass(manifest, policyName)
+}
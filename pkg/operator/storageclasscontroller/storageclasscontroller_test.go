@@ -3,7 +3,9 @@ package storageclasscontroller
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	v1 "github.com/openshift/api/config/v1"
 	opv1 "github.com/openshift/api/operator/v1"
@@ -14,6 +16,7 @@ import (
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/component-base/metrics/testutil"
 	"k8s.io/utils/clock"
 )
 
@@ -33,12 +36,12 @@ func (v *fakeStoragePolicyAPI) createStoragePolicy(ctx context.Context) (string,
 	return v.ret, v.err
 }
 
-func newFakeStoragePolicyAPISuccess(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure) vCenterInterface {
+func newFakeStoragePolicyAPISuccess(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure, vCenterConfig utils.VCenterConfig) vCenterInterface {
 	fakeStoragePolicyAPI := &fakeStoragePolicyAPI{ret: "fake-return-value"}
 	return fakeStoragePolicyAPI
 }
 
-func newFakeStoragePolicyAPIFailure(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure) vCenterInterface {
+func newFakeStoragePolicyAPIFailure(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure, vCenterConfig utils.VCenterConfig) vCenterInterface {
 	fakeStoragePolicyAPI := &fakeStoragePolicyAPI{ret: "fake-return-value", err: fmt.Errorf("fake-error")}
 	return fakeStoragePolicyAPI
 }
@@ -262,3 +265,78 @@ func TestSyncMultiple(t *testing.T) {
 		})
 	}
 }
+
+func TestStoragePolicySyncState(t *testing.T) {
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+	scController := newStorageClassController(commonApiClient, "storageclass1.yaml", false)
+	if _, found := scController.GetState(); found {
+		t.Fatalf("expected no state before the first sync")
+	}
+	scController.setBackoff(defaultBackoff)
+	scController.scheduleNextSync()
+	scController.scheduleNextSync()
+	scController.policyName = "openshift-storage-policy-test"
+	state, found := scController.GetState()
+	if !found || state.BackoffSteps != 2 {
+		t.Fatalf("expected state after 2 syncs, got %+v", state)
+	}
+
+	// A restarted operator continues with the same backoff
+	restarted := newStorageClassController(commonApiClient, "storageclass1.yaml", false)
+	restarted.setBackoff(defaultBackoff)
+	restarted.RestoreState(state)
+	if restarted.backoff != scController.backoff || restarted.policyName != scController.policyName {
+		t.Errorf("expected restored backoff %+v and policy %s, got %+v and %s", scController.backoff, scController.policyName, restarted.backoff, restarted.policyName)
+	}
+	if restarted.storagePolicySyncDue() {
+		t.Errorf("expected storage policy sync not to be due before the restored next sync")
+	}
+
+	// A shorter backoff keeps the number of syncs and moves the next sync earlier
+	shorter := defaultBackoff
+	shorter.Duration = time.Millisecond
+	shorter.Cap = time.Millisecond
+	restarted.setBackoff(shorter)
+	if restarted.backoffSteps != 2 {
+		t.Errorf("expected 2 syncs to be kept after the backoff changed, got %d", restarted.backoffSteps)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if !restarted.storagePolicySyncDue() {
+		t.Errorf("expected storage policy sync to be due after the backoff got shorter")
+	}
+
+	restarted.ResetBackoff()
+	if restarted.backoffSteps != 0 || !restarted.storagePolicySyncDue() {
+		t.Errorf("expected storage policy sync to be due after the backoff was reset")
+	}
+}
+
+func TestStoragePolicySyncFailureMetric(t *testing.T) {
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+	apiDeps := getCheckAPIDependency(commonApiClient)
+	conns := []*vclib.VSphereConnection{{Hostname: "test"}}
+	scController := newStorageClassController(commonApiClient, "storageclass1.yaml", true)
+	utils.InstallErrorMetric.Reset()
+
+	if err := scController.Sync(context.TODO(), conns, apiDeps); err != nil {
+		t.Fatalf("failed to sync controller: %v", err)
+	}
+	failure := `
+        # HELP vsphere_csi_driver_error [ALPHA] vSphere driver installation error
+        # TYPE vsphere_csi_driver_error gauge
+        vsphere_csi_driver_error{condition="storage_class_sync_failed",failure_reason="vcenter_api_error"} 1
+        `
+	if err := testutil.CollectAndCompare(utils.InstallErrorMetric, strings.NewReader(failure), utils.InstallErrorMetric.Name); err != nil {
+		t.Errorf("expected the failed storage policy sync in metrics: %v", err)
+	}
+
+	// The policy is synced again
+	scController.makeStoragePolicyAPI = newFakeStoragePolicyAPISuccess
+	scController.ResetBackoff()
+	if err := scController.Sync(context.TODO(), conns, apiDeps); err != nil {
+		t.Fatalf("failed to sync controller: %v", err)
+	}
+	if err := testutil.CollectAndCompare(utils.InstallErrorMetric, strings.NewReader(""), utils.InstallErrorMetric.Name); err != nil {
+		t.Errorf("expected the failure to be removed from metrics after a successful sync: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package storageclasscontroller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// VCenterDryRunAnnotation on ClusterCSIDriver enables the dry-run mode. In dry-run mode the operator
+	// does not create or update any tag categories, tags or storage policies in vCenter. Instead, it
+	// publishes the changes it would make in the VCenterChangesConfigMapName ConfigMap.
+	VCenterDryRunAnnotation = "vsphere.csi.openshift.io/vcenter-dry-run"
+	// VCenterChangesApprovedAnnotation on ClusterCSIDriver contains the plan hash of reviewed vCenter
+	// changes. The operator makes the changes only when the hash matches the current plan.
+	VCenterChangesApprovedAnnotation = "vsphere.csi.openshift.io/vcenter-changes-approved"
+	// VCenterChangesConfigMapName is the name of the ConfigMap with the pending vCenter changes.
+	VCenterChangesConfigMapName = "vmware-vsphere-csi-driver-vcenter-changes"
+
+	vCenterChangesKey      = "changes"
+	vCenterPlanHashKey     = "plan-hash"
+	vCenterPlanTimeKey     = "generated"
+	noChangesPlanHash      = "none"
+	pendingChangesEvent    = "VCenterChangesPendingApproval"
+	approvedChangesEvent   = "VCenterChangesApproved"
+	unapprovedChangesEvent = "VCenterChangesNotApproved"
+)
+
+// vCenterChange describes a single change the operator intends to make in vCenter.
+type vCenterChange struct {
+	// Server is the vCenter hostname
+	Server string
+	// API is the kind of change, for example create_category
+	API         string
+	Description string
+}
+
+func (c vCenterChange) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Server, c.API, c.Description)
+}
+
+// vCenterChangesHash returns a stable hash of planned changes, which is used to approve them.
+func vCenterChangesHash(changes []vCenterChange) string {
+	if len(changes) == 0 {
+		return noChangesPlanHash
+	}
+	h := sha256.New()
+	for _, change := range changes {
+		fmt.Fprintln(h, change.String())
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
+
+// isVCenterDryRun returns true when the vCenter dry-run mode is enabled on ClusterCSIDriver.
+func (c *AbstractStorageClass) isVCenterDryRun() bool {
+	if c.clusterCSIDriverLister == nil {
+		return false
+	}
+	clusterCSIDriver, err := c.clusterCSIDriverLister.Get(utils.VSphereDriverName)
+	if err != nil {
+		klog.Errorf("error getting ClusterCSIDriver %s: %v", utils.VSphereDriverName, err)
+		return false
+	}
+	return clusterCSIDriver.Annotations[VCenterDryRunAnnotation] == "true"
+}
+
+func (c *AbstractStorageClass) approvedVCenterPlanHash() string {
+	clusterCSIDriver, err := c.clusterCSIDriverLister.Get(utils.VSphereDriverName)
+	if err != nil {
+		return ""
+	}
+	return clusterCSIDriver.Annotations[VCenterChangesApprovedAnnotation]
+}
+
+// reviewVCenterChanges computes changes the storage policy sync would make in all vCenters without
+// making them and publishes them for review. It returns true when there are changes that were not
+// approved yet and the storage policy sync must wait.
+func (c *AbstractStorageClass) reviewVCenterChanges(ctx context.Context, connections []*vclib.VSphereConnection, apiDeps checks.KubeAPIInterface) (bool, checks.ClusterCheckResult) {
+	infra := apiDeps.GetInfrastructure()
+
+	// vCenters are reviewed in parallel, changes are collected in the order of connections so the plan hash
+	// does not depend on which vCenter answered first
+	plannedChanges := make([][]vCenterChange, len(connections))
+	errs := vclib.ForEachConnection(ctx, connections, apiTimeout, func(ctx context.Context, i int, connection *vclib.VSphereConnection) error {
+		apiClient := c.makeStoragePolicyAPI(ctx, connection, infra, apiDeps.GetOperatorConfig().GetVCenterConfig(connection.Hostname))
+		apiClient.setDryRun(true)
+
+		if _, err := apiClient.createStoragePolicy(ctx); err != nil {
+			return fmt.Errorf("error computing vCenter changes for %s: %w", connection.Hostname, err)
+		}
+		plannedChanges[i] = apiClient.getPlannedChanges()
+		return nil
+	})
+	var changes []vCenterChange
+	for i := range connections {
+		if errs[i] != nil {
+			return true, makeStoragePolicyErrorResult(errs[i])
+		}
+		changes = append(changes, plannedChanges[i]...)
+	}
+
+	planHash := vCenterChangesHash(changes)
+	if err := c.publishVCenterChanges(ctx, changes, planHash); err != nil {
+		return true, checks.MakeClusterDegradedError(checks.CheckStatusOpenshiftAPIError, err)
+	}
+
+	// The storage policy sync computes the changes again, it may make only the reviewed ones
+	c.approvedChanges = []vCenterChange{}
+	if len(changes) == 0 {
+		c.lastPlanHash = planHash
+		return false, checks.MakeClusterCheckResultPass()
+	}
+
+	if c.approvedVCenterPlanHash() == planHash {
+		if c.lastApprovedPlanHash != planHash {
+			c.recorder.Eventf(approvedChangesEvent, "Making %d approved changes in vCenter, plan %s", len(changes), planHash)
+			c.lastApprovedPlanHash = planHash
+		}
+		c.approvedChanges = changes
+		return false, checks.MakeClusterCheckResultPass()
+	}
+
+	if c.lastPlanHash != planHash {
+		c.recorder.Warningf(pendingChangesEvent,
+			"%d vCenter changes are waiting for approval, review ConfigMap %s/%s and set annotation %s=%s on ClusterCSIDriver %s to approve them",
+			len(changes), c.targetNamespace, VCenterChangesConfigMapName, VCenterChangesApprovedAnnotation, planHash, utils.VSphereDriverName)
+		c.lastPlanHash = planHash
+	}
+	klog.V(2).Infof("vCenter changes with plan hash %s are waiting for approval", planHash)
+	return true, checks.MakeClusterCheckResultPass()
+}
+
+// restrictToApprovedChanges makes the storage policy API refuse changes that were not reviewed, when the
+// dry-run mode is enabled.
+func (c *AbstractStorageClass) restrictToApprovedChanges(apiClient vCenterInterface) {
+	if c.approvedChanges != nil {
+		apiClient.setApprovedChanges(c.approvedChanges)
+	}
+}
+
+// checkUnapprovedChanges returns an error when the storage policy API refused changes that differ from the approved
+// plan, for example because somebody changed vCenter after the approval. The changes are reviewed again in the next
+// sync. It is called in parallel for all vCenters.
+func (c *AbstractStorageClass) checkUnapprovedChanges(apiClient vCenterInterface) error {
+	if c.approvedChanges == nil {
+		return nil
+	}
+	unapproved := apiClient.getUnapprovedChanges()
+	if len(unapproved) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(unapproved))
+	for _, change := range unapproved {
+		lines = append(lines, change.String())
+	}
+	c.recorder.Warningf(unapprovedChangesEvent, "Refused %d vCenter changes that differ from the approved plan %s, the changes must be approved again", len(unapproved), c.approvedVCenterPlanHash())
+	return fmt.Errorf("vCenter changes differ from the approved plan, refused: %s", strings.Join(lines, "; "))
+}
+
+func (c *AbstractStorageClass) publishVCenterChanges(ctx context.Context, changes []vCenterChange, planHash string) error {
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      VCenterChangesConfigMapName,
+			Namespace: c.targetNamespace,
+		},
+		Data: map[string]string{
+			vCenterChangesKey:  strings.Join(lines, "\n"),
+			vCenterPlanHashKey: planHash,
+		},
+	}
+	// Keep the time stamp of the first time this plan was seen, so the ConfigMap is not updated on every sync.
+	existing, err := c.kubeClient.CoreV1().ConfigMaps(c.targetNamespace).Get(ctx, VCenterChangesConfigMapName, metav1.GetOptions{})
+	if err == nil && existing.Data[vCenterPlanHashKey] == planHash && existing.Data[vCenterPlanTimeKey] != "" {
+		cm.Data[vCenterPlanTimeKey] = existing.Data[vCenterPlanTimeKey]
+	} else {
+		cm.Data[vCenterPlanTimeKey] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	_, _, err = resourceapply.ApplyConfigMap(ctx, c.kubeClient.CoreV1(), c.recorder, cm)
+	if err != nil {
+		return fmt.Errorf("error publishing vCenter changes: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package storageclasscontroller
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fakePlanningAPI makes changes through storagePolicyAPI.mutate, so dry-run and approval work as with vCenter.
+type fakePlanningAPI struct {
+	*storagePolicyAPI
+	// changes the storage policy sync makes
+	changes []vCenterChange
+	// changes made only outside of dry-run, like when vCenter changed after the review
+	executionChanges []vCenterChange
+	made             *[]vCenterChange
+}
+
+func (f *fakePlanningAPI) createStoragePolicy(ctx context.Context) (string, error) {
+	changes := f.changes
+	if !f.dryRun {
+		changes = append(append([]vCenterChange{}, f.executionChanges...), f.changes...)
+	}
+	for _, change := range changes {
+		if f.mutate(change.API, "%s", change.Description) {
+			*f.made = append(*f.made, change)
+		}
+	}
+	return "fake-policy", nil
+}
+
+func hasRecordedEvent(recorder events.Recorder, reason string) bool {
+	for _, event := range recorder.(events.InMemoryRecorder).Events() {
+		if event.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReviewVCenterChanges(t *testing.T) {
+	const server = "vcenter.lan"
+	plannedChanges := []vCenterChange{
+		{Server: server, API: create_category_api, Description: "create tag category openshift-test"},
+		{Server: server, API: create_tag_api, Description: "create tag test"},
+	}
+	lateChange := vCenterChange{Server: server, API: update_category_api, Description: "update associable types of tag category openshift-test"}
+
+	tests := []struct {
+		name             string
+		approvedHash     string
+		executionChanges []vCenterChange
+		expectedMade     []vCenterChange
+		expectedEvent    string
+	}{
+		{
+			name:          "changes wait for approval",
+			expectedEvent: pendingChangesEvent,
+		},
+		{
+			name:          "approval of another plan does not approve changes",
+			approvedHash:  "0123456789abcdef",
+			expectedEvent: pendingChangesEvent,
+		},
+		{
+			name:          "approved changes are made",
+			approvedHash:  vCenterChangesHash(plannedChanges),
+			expectedMade:  plannedChanges,
+			expectedEvent: approvedChangesEvent,
+		},
+		{
+			name:             "changes that differ from the approved plan are refused",
+			approvedHash:     vCenterChangesHash(plannedChanges),
+			executionChanges: []vCenterChange{lateChange},
+			expectedEvent:    unapprovedChangesEvent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commonApiClient := testlib.NewFakeClients([]runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+			clusterCSIDriver := testlib.GetClusterCSIDriver(false)
+			clusterCSIDriver.Annotations = map[string]string{VCenterDryRunAnnotation: "true"}
+			if test.approvedHash != "" {
+				clusterCSIDriver.Annotations[VCenterChangesApprovedAnnotation] = test.approvedHash
+			}
+			if err := commonApiClient.ClusterCSIDriverInformer.Informer().GetIndexer().Add(clusterCSIDriver); err != nil {
+				t.Fatalf("error adding ClusterCSIDriver: %v", err)
+			}
+
+			var made []vCenterChange
+			scController := newStorageClassController(commonApiClient, "storageclass1.yaml", false)
+			scController.clusterCSIDriverLister = commonApiClient.ClusterCSIDriverInformer.Lister()
+			scController.makeStoragePolicyAPI = func(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure, vCenterConfig utils.VCenterConfig) vCenterInterface {
+				return &fakePlanningAPI{
+					storagePolicyAPI: &storagePolicyAPI{vcenterApiConnection: connection, apiTestInfo: map[string]int{}},
+					changes:          plannedChanges,
+					executionChanges: test.executionChanges,
+					made:             &made,
+				}
+			}
+
+			conns := []*vclib.VSphereConnection{{Hostname: server}}
+			if err := scController.Sync(context.TODO(), conns, getCheckAPIDependency(commonApiClient)); err != nil {
+				t.Fatalf("failed to sync controller: %v", err)
+			}
+
+			if len(made) != len(test.expectedMade) {
+				t.Fatalf("expected changes %v, got %v", test.expectedMade, made)
+			}
+			for i := range made {
+				if made[i] != test.expectedMade[i] {
+					t.Errorf("expected change %s, got %s", test.expectedMade[i], made[i])
+				}
+			}
+			if !hasRecordedEvent(scController.recorder, test.expectedEvent) {
+				t.Errorf("expected event %s", test.expectedEvent)
+			}
+
+			cm, err := commonApiClient.KubeClient.CoreV1().ConfigMaps(testScControllerNamespace).Get(context.TODO(), VCenterChangesConfigMapName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting published changes: %v", err)
+			}
+			if hash := cm.Data[vCenterPlanHashKey]; hash != vCenterChangesHash(plannedChanges) {
+				t.Errorf("expected published plan hash %s, got %s", vCenterChangesHash(plannedChanges), hash)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
 
 	v1 "github.com/openshift/api/config/v1"
@@ -30,6 +31,8 @@ const (
 	categoryNameTemplate = "openshift-%s"
 	policyNameTemplate   = "openshift-storage-policy-%s"
 	vim25Prefix          = "urn:vim25:"
+	// ownedCategoryDescription marks tag categories and tags created by OpenShift, see isOwnedCategory
+	ownedCategoryDescription = "Added by openshift-install do not remove"
 
 	create_tag_api      = "create_tag"
 	update_tag_api      = "update_tag"
@@ -41,12 +44,23 @@ const (
 
 var associatedTypesRaw = []string{"StoragePod", "Datastore", "ResourcePool", "VirtualMachine", "Folder"}
 
+// requiredAssociatedTypes are types the operator attaches the tag to. An existing category is updated
+// only when it misses some of them.
+var requiredAssociatedTypes = []string{"Datastore"}
+
 type vCenterInterface interface {
 	GetDefaultDatastore(ctx context.Context, infra *v1.Infrastructure) (*mo.Datastore, error)
 	createStoragePolicy(ctx context.Context) (string, error)
 	checkForExistingPolicy(ctx context.Context) (bool, error)
 	createOrUpdateTag(ctx context.Context, ds *mo.Datastore) error
 	createStorageProfile(ctx context.Context) error
+	// setDryRun makes all following calls record the changes they would make in vCenter
+	// instead of making them.
+	setDryRun(dryRun bool)
+	getPlannedChanges() []vCenterChange
+	// setApprovedChanges makes all following calls refuse changes that are not in the approved plan.
+	setApprovedChanges(changes []vCenterChange)
+	getUnapprovedChanges() []vCenterChange
 }
 
 type storagePolicyAPI struct {
@@ -57,15 +71,38 @@ type storagePolicyAPI struct {
 	policyName           string
 	tagName              string
 	categoryName         string
-	policyCreated        bool
-	// mainly used for verifying test status
-	// Keep track of mutable API calls being made
+	// useExistingTags forbids creating and updating the tag category and the tag
+	useExistingTags bool
+	// preserveForeignCategories forbids updating tag categories not created by OpenShift
+	preserveForeignCategories bool
+	policyCreated             bool
+	dryRun                    bool
+	plannedChanges            []vCenterChange
+	// approvedChanges are the only changes allowed outside of dry-run mode, when set
+	approvedChanges   sets.Set[string]
+	unapprovedChanges []vCenterChange
+	// Keep track of mutable API calls made by this client, mainly used for verifying test status.
+	// utils.VCenterMutatingCallsMetric exposes the same for all clients.
 	apiTestInfo map[string]int
 }
 
 var _ vCenterInterface = &storagePolicyAPI{}
 
-func NewStoragePolicyAPI(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure) vCenterInterface {
+// StoragePolicyConfigError is returned when the tag category or the tag configured by the user
+// can't be used for the storage policy.
+type StoragePolicyConfigError struct {
+	msg string
+}
+
+func (e *StoragePolicyConfigError) Error() string {
+	return e.msg
+}
+
+func newStoragePolicyConfigError(format string, args ...interface{}) error {
+	return &StoragePolicyConfigError{msg: fmt.Sprintf(format, args...)}
+}
+
+func NewStoragePolicyAPI(ctx context.Context, connection *vclib.VSphereConnection, infra *v1.Infrastructure, vCenterConfig utils.VCenterConfig) vCenterInterface {
 	var fds []*v1.VSpherePlatformFailureDomainSpec
 
 	// Get Failure domains to use for this storage policy based on the connection hostname (vCenter)
@@ -78,17 +115,73 @@ func NewStoragePolicyAPI(ctx context.Context, connection *vclib.VSphereConnectio
 	}
 
 	storagePolicyAPIClient := &storagePolicyAPI{
-		vcenterApiConnection: connection,
-		infra:                infra,
-		failureDomains:       fds,
-		categoryName:         fmt.Sprintf(categoryNameTemplate, infra.Status.InfrastructureName),
-		policyName:           fmt.Sprintf(policyNameTemplate, infra.Status.InfrastructureName),
-		tagName:              infra.Status.InfrastructureName,
-		apiTestInfo:          map[string]int{},
+		vcenterApiConnection:      connection,
+		infra:                     infra,
+		failureDomains:            fds,
+		categoryName:              fmt.Sprintf(categoryNameTemplate, infra.Status.InfrastructureName),
+		policyName:                fmt.Sprintf(policyNameTemplate, infra.Status.InfrastructureName),
+		tagName:                   infra.Status.InfrastructureName,
+		useExistingTags:           vCenterConfig.UseExistingTags,
+		preserveForeignCategories: vCenterConfig.PreserveForeignCategories,
+		apiTestInfo:               map[string]int{},
+	}
+	if vCenterConfig.CategoryName != "" {
+		storagePolicyAPIClient.categoryName = vCenterConfig.CategoryName
+	}
+	if vCenterConfig.TagName != "" {
+		storagePolicyAPIClient.tagName = vCenterConfig.TagName
+	}
+	if vCenterConfig.PolicyName != "" {
+		storagePolicyAPIClient.policyName = vCenterConfig.PolicyName
 	}
 	return storagePolicyAPIClient
 }
 
+func (v *storagePolicyAPI) setDryRun(dryRun bool) {
+	v.dryRun = dryRun
+	v.plannedChanges = nil
+}
+
+func (v *storagePolicyAPI) getPlannedChanges() []vCenterChange {
+	return v.plannedChanges
+}
+
+func (v *storagePolicyAPI) setApprovedChanges(changes []vCenterChange) {
+	v.approvedChanges = sets.New[string]()
+	for _, change := range changes {
+		v.approvedChanges.Insert(change.String())
+	}
+	v.unapprovedChanges = nil
+}
+
+func (v *storagePolicyAPI) getUnapprovedChanges() []vCenterChange {
+	return v.unapprovedChanges
+}
+
+// mutate must be called before every call that changes an object in vCenter. In dry-run mode it
+// records the change and returns false, in which case the caller must skip the actual API call.
+// With approved changes it returns false also for changes that are not approved and for all changes
+// after them, which depend on the refused one.
+func (v *storagePolicyAPI) mutate(api string, format string, args ...interface{}) bool {
+	change := vCenterChange{
+		Server:      v.vcenterApiConnection.Hostname,
+		API:         api,
+		Description: fmt.Sprintf(format, args...),
+	}
+	if v.dryRun {
+		v.plannedChanges = append(v.plannedChanges, change)
+		return false
+	}
+	if v.approvedChanges != nil && (len(v.unapprovedChanges) > 0 || !v.approvedChanges.Has(change.String())) {
+		klog.Warningf("Refusing vCenter change that was not approved: %s", change)
+		v.unapprovedChanges = append(v.unapprovedChanges, change)
+		return false
+	}
+	v.apiTestInfo[api]++
+	utils.VCenterMutatingCallsMetric.WithLabelValues(v.vcenterApiConnection.Hostname, api).Inc()
+	return true
+}
+
 func (v *storagePolicyAPI) GetDefaultDatastore(ctx context.Context, infra *v1.Infrastructure) (*mo.Datastore, error) {
 	vmClient := v.vcenterApiConnection.Client
 	config := v.vcenterApiConnection.Config
@@ -200,7 +293,7 @@ func (v *storagePolicyAPI) attachTags(ctx context.Context, dcName, dsName string
 
 	err = v.createOrUpdateTag(ctx, ds)
 	if err != nil {
-		return fmt.Errorf("error tagging datastore %s with %s: %v", dsName, v.tagName, err)
+		return fmt.Errorf("error tagging datastore %s with %s: %w", dsName, v.tagName, err)
 	}
 	return nil
 }
@@ -217,6 +310,12 @@ func (v *storagePolicyAPI) createStoragePolicy(ctx context.Context) (string, err
 		v.policyCreated = true
 	}
 
+	if v.useExistingTags {
+		if err := v.checkExistingTags(ctx); err != nil {
+			return v.policyName, err
+		}
+	}
+
 	vSphereInfraConfig := v.infra.Spec.PlatformSpec.VSphere
 	if vSphereInfraConfig != nil && len(vSphereInfraConfig.FailureDomains) > 0 {
 		klog.V(4).Info("Creating zonal policy")
@@ -243,6 +342,38 @@ func (v *storagePolicyAPI) createStoragePolicy(ctx context.Context) (string, err
 	return v.policyName, nil
 }
 
+// checkExistingTags checks that the tag category and the tag exist and that the tag can be attached to datastores.
+func (v *storagePolicyAPI) checkExistingTags(ctx context.Context) error {
+	tagManager := tags.NewManager(v.vcenterApiConnection.RestClient)
+	server := v.vcenterApiConnection.Hostname
+
+	category, err := tagManager.GetCategory(ctx, v.categoryName)
+	if err != nil && !notFoundError(err) {
+		return fmt.Errorf("error finding category %s: %v", v.categoryName, err)
+	}
+	if category == nil || category.ID == "" {
+		return newStoragePolicyConfigError("tag category %s does not exist in vCenter %s and the operator is configured to use existing tags only, create the category or fix categoryName in ConfigMap %s/%s",
+			v.categoryName, server, utils.DefaultNamespace, utils.OperatorConfigMapName)
+	}
+	if missingTypes := missingAssociatedTypes(category.AssociableTypes); len(missingTypes) > 0 {
+		return newStoragePolicyConfigError("tag category %s in vCenter %s can't be associated with %s, its associable types are %s",
+			v.categoryName, server, strings.Join(missingTypes, ","), strings.Join(category.AssociableTypes, ","))
+	}
+
+	tag, err := tagManager.GetTag(ctx, v.tagName)
+	if err != nil && !notFoundError(err) {
+		return fmt.Errorf("error finding tag %s: %v", v.tagName, err)
+	}
+	if tag == nil || tag.ID == "" {
+		return newStoragePolicyConfigError("tag %s does not exist in vCenter %s and the operator is configured to use existing tags only, create the tag or fix tagName in ConfigMap %s/%s",
+			v.tagName, server, utils.DefaultNamespace, utils.OperatorConfigMapName)
+	}
+	if tag.CategoryID != category.ID {
+		return newStoragePolicyConfigError("tag %s in vCenter %s does not belong to tag category %s", v.tagName, server, v.categoryName)
+	}
+	return nil
+}
+
 func (v *storagePolicyAPI) checkForTagOnDatastore(ctx context.Context, dsMo *mo.Datastore) bool {
 	tagManager := tags.NewManager(v.vcenterApiConnection.RestClient)
 	attachedTags, err := tagManager.GetAttachedTagsOnObjects(ctx, []mo.Reference{dsMo.Reference()})
@@ -273,70 +404,94 @@ func (v *storagePolicyAPI) createOrUpdateTag(ctx context.Context, ds *mo.Datasto
 	}
 
 	associatedTypes := appendPrefix(associatedTypesRaw)
-	if category == nil || category.ID == "" {
+	if v.useExistingTags {
+		// checkExistingTags has verified the category and the tag exist
+		if category == nil || category.ID == "" {
+			return newStoragePolicyConfigError("tag category %s does not exist", v.categoryName)
+		}
+	} else if category == nil || category.ID == "" {
 		klog.Warningf("Unexpected missing category %s - creating it", v.categoryName)
 		category = &tags.Category{
 			Name:            v.categoryName,
-			Description:     "Added by openshift-install do not remove",
+			Description:     ownedCategoryDescription,
 			AssociableTypes: associatedTypes,
 			Cardinality:     "SINGLE",
 		}
-		v.apiTestInfo[create_category_api]++
-		catId, err := tagManager.CreateCategory(ctx, category)
+		if v.mutate(create_category_api, "create tag category %s with associable types %s", v.categoryName, strings.Join(associatedTypes, ",")) {
+			catId, err := tagManager.CreateCategory(ctx, category)
 
-		if err != nil {
-			return fmt.Errorf("error creating category %s: %v", v.categoryName, err)
+			if err != nil {
+				return fmt.Errorf("error creating category %s: %v", v.categoryName, err)
+			}
+			klog.V(2).Infof("Created category %s", v.categoryName)
+			category.ID = catId
 		}
-		klog.V(2).Infof("Created category %s", v.categoryName)
-		category.ID = catId
-	} else {
-		existingAssociatedTypes := category.AssociableTypes
-		associatedTypes = updateAssociatedTypes(existingAssociatedTypes)
+	} else if missingTypes := missingAssociatedTypes(category.AssociableTypes); len(missingTypes) > 0 {
+		if v.preserveForeignCategories && !isOwnedCategory(category, v.infra.Status.InfrastructureName) {
+			return newStoragePolicyConfigError("tag category %s in vCenter %s can't be associated with %s and the operator is configured not to change categories it did not create",
+				v.categoryName, v.vcenterApiConnection.Hostname, strings.Join(missingTypes, ","))
+		}
+		associatedTypes = updateAssociatedTypes(category.AssociableTypes, missingTypes)
 		category.AssociableTypes = associatedTypes
 		klog.V(4).Infof("Final categories are: %+v", associatedTypes)
-		v.apiTestInfo[update_category_api]++
-		err := tagManager.UpdateCategory(ctx, category)
-		if err != nil {
-			return fmt.Errorf("error updating category %s: %v", v.categoryName, err)
+		if v.mutate(update_category_api, "update associable types of tag category %s to %s", v.categoryName, strings.Join(associatedTypes, ",")) {
+			err := tagManager.UpdateCategory(ctx, category)
+			if err != nil {
+				return fmt.Errorf("error updating category %s: %v", v.categoryName, err)
+			}
+			klog.V(2).Infof("Updated category %s with associated types", v.categoryName)
 		}
-		klog.V(2).Infof("Updated category %s with associated types", v.categoryName)
+	} else {
+		klog.V(4).Infof("Category %s already has all required associable types", v.categoryName)
 	}
 
 	tag, err := tagManager.GetTag(ctx, v.tagName)
 	if err != nil && !notFoundError(err) {
 		return fmt.Errorf("error finding tag %s: %v", v.tagName, err)
 	}
-	if tag == nil || tag.ID == "" {
+	if v.useExistingTags {
+		if tag == nil || tag.ID == "" {
+			return newStoragePolicyConfigError("tag %s does not exist", v.tagName)
+		}
+	} else if tag == nil || tag.ID == "" {
 		klog.Warningf("Unexpected missing tag %s - creating it", v.tagName)
 		tag = &tags.Tag{
 			Name:        v.tagName,
-			Description: "Added by openshift-install do not remove",
+			Description: ownedCategoryDescription,
 			CategoryID:  category.ID,
 		}
-		v.apiTestInfo[create_tag_api]++
-		tagID, err := tagManager.CreateTag(ctx, tag)
-		if err != nil {
-			return fmt.Errorf("error creating tag %s: %v", v.tagName, err)
+		if v.mutate(create_tag_api, "create tag %s in tag category %s", v.tagName, v.categoryName) {
+			tagID, err := tagManager.CreateTag(ctx, tag)
+			if err != nil {
+				return fmt.Errorf("error creating tag %s: %v", v.tagName, err)
+			}
+			klog.V(2).Infof("Created tag %s", v.tagName)
+			tag.ID = tagID
 		}
-		klog.V(2).Infof("Created tag %s", v.tagName)
-		tag.ID = tagID
 	} else if tag.CategoryID != category.ID {
 		tag = &tags.Tag{
 			Name:        v.tagName,
-			Description: "Added by openshift-install do not remove",
+			Description: ownedCategoryDescription,
 			CategoryID:  category.ID,
 			ID:          tag.ID,
 		}
-		v.apiTestInfo[update_tag_api]++
-		err := tagManager.UpdateTag(ctx, tag)
-		if err != nil {
-			return fmt.Errorf("error updating tag %s: %v", v.tagName, err)
+		if v.mutate(update_tag_api, "move tag %s to tag category %s", v.tagName, v.categoryName) {
+			err := tagManager.UpdateTag(ctx, tag)
+			if err != nil {
+				return fmt.Errorf("error updating tag %s: %v", v.tagName, err)
+			}
+			klog.V(2).Infof("Updated tag %s", v.tagName)
 		}
-		klog.V(2).Infof("Updated tag %s", v.tagName)
 	}
 
 	dsName := ds.Summary.Name
-	v.apiTestInfo[attach_tag_api]++
+	// Attaching an already attached tag is a no-op in vCenter, do not report it as a planned change.
+	if v.dryRun && v.checkForTagOnDatastore(ctx, ds) {
+		return nil
+	}
+	if !v.mutate(attach_tag_api, "attach tag %s to datastore %s", v.tagName, dsName) {
+		return nil
+	}
 	err = tagManager.AttachTag(ctx, tag.ID, ds)
 	if err != nil {
 		klog.Errorf("error attaching tag %s to datastore %s: %v", v.tagName, dsName, err)
@@ -347,6 +502,10 @@ func (v *storagePolicyAPI) createOrUpdateTag(ctx context.Context, ds *mo.Datasto
 }
 
 func (v *storagePolicyAPI) createStorageProfile(ctx context.Context) error {
+	if !v.mutate(create_profile_api, "create storage policy %s requiring tag %s from tag category %s", v.policyName, v.tagName, v.categoryName) {
+		return nil
+	}
+
 	pbmClient, err := pbm.NewClient(ctx, v.vcenterApiConnection.Client.Client)
 	if err != nil {
 		msg := fmt.Sprintf("error creating pbm client: %v", err)
@@ -382,7 +541,6 @@ func (v *storagePolicyAPI) createStorageProfile(ctx context.Context) error {
 		}},
 	}
 
-	v.apiTestInfo[create_profile_api]++
 	pid, err := pbmClient.CreateProfile(ctx, policySpec)
 	if err != nil {
 		msg := fmt.Sprintf("error creating profile: %v", err)
@@ -467,19 +625,52 @@ func (v *storagePolicyAPI) deleteStoragePolicy(ctx context.Context) error {
 
 }
 
+// isOwnedCategory returns true when the tag category was created by OpenShift for the cluster with the given
+// infrastructure name. openshift-install and the operator name the category of a cluster after categoryNameTemplate,
+// such a category is owned even when its description was edited afterwards. A category with a name configured in
+// the operator config is owned only when it still has ownedCategoryDescription, which the operator sets when it
+// creates the category. This assumes that nobody else names a category after the infrastructure name of the cluster
+// or copies the description, a category with an edited description is treated as foreign.
+func isOwnedCategory(category *tags.Category, infrastructureName string) bool {
+	if category.Name == fmt.Sprintf(categoryNameTemplate, infrastructureName) {
+		return true
+	}
+	return category.Description == ownedCategoryDescription
+}
+
 func notFoundError(err error) bool {
 	errorString := err.Error()
 	r := regexp.MustCompile("404")
 	return r.MatchString(errorString)
 }
 
-func updateAssociatedTypes(associatedTypes []string) []string {
-	incomingTypesSet := sets.NewString(appendPrefix(associatedTypes)...)
-	additionTypes := appendPrefix(associatedTypesRaw)
-	finalAssociatedTypes := incomingTypesSet.Insert(additionTypes...)
-	associatedTypeFinalList := finalAssociatedTypes.List()
-	sort.Strings(associatedTypeFinalList)
-	return associatedTypeFinalList
+// missingAssociatedTypes returns requiredAssociatedTypes that are not associable with a category with the given
+// associable types. Category without any associable types can be associated with all types.
+func missingAssociatedTypes(associatedTypes []string) []string {
+	if len(associatedTypes) == 0 {
+		return nil
+	}
+	existingTypesSet := sets.NewString(appendPrefix(associatedTypes)...)
+	var missing []string
+	for _, requiredType := range appendPrefix(requiredAssociatedTypes) {
+		if !existingTypesSet.Has(requiredType) {
+			missing = append(missing, requiredType)
+		}
+	}
+	return missing
+}
+
+// updateAssociatedTypes adds additionalTypes to associatedTypes. Existing types are kept as they are,
+// vCenter does not allow removing associable types from a category.
+func updateAssociatedTypes(associatedTypes []string, additionalTypes []string) []string {
+	existingTypesSet := sets.NewString(appendPrefix(associatedTypes)...)
+	finalAssociatedTypes := append([]string{}, associatedTypes...)
+	for _, additionalType := range appendPrefix(additionalTypes) {
+		if !existingTypesSet.Has(additionalType) {
+			finalAssociatedTypes = append(finalAssociatedTypes, additionalType)
+		}
+	}
+	return finalAssociatedTypes
 }
 
 func appendPrefix(associableTypes []string) []string {
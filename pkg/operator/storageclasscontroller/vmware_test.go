@@ -2,12 +2,17 @@ package storageclasscontroller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	v1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/api/features"
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/vmware/govmomi/vapi/tags"
+	"k8s.io/component-base/metrics/testutil"
+	"strings"
 	"testing"
 )
 
@@ -66,6 +71,14 @@ func TestCreateStoragePolicyOnce(t *testing.T) {
 			attach_tag_api:      1,
 			create_profile_api:  1,
 		})
+
+		profiles, err := testutil.GetCounterMetricValue(utils.VCenterMutatingCallsMetric.WithLabelValues(conn.Hostname, create_profile_api))
+		if err != nil {
+			t.Fatalf("error getting metric value: %v", err)
+		}
+		if profiles < 1 {
+			t.Errorf("expected %s to be counted in mutating calls metric", create_profile_api)
+		}
 	}
 
 }
@@ -148,7 +161,8 @@ func TestZonalPolicyCreation(t *testing.T) {
 			expectedApiCallCount: map[string]int{
 				create_tag_api:      1,
 				create_category_api: 1,
-				update_category_api: 1,
+				// the category created for the first failure domain already has all required types
+				update_category_api: 0,
 				attach_tag_api:      2,
 				create_profile_api:  1,
 			},
@@ -246,6 +260,262 @@ func TestZonalPolicyCreation(t *testing.T) {
 
 }
 
+func TestDryRunPolicyCreation(t *testing.T) {
+	infra := testlib.GetInfraObject()
+
+	connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	defer func() {
+		if cleanUpFunc != nil {
+			cleanUpFunc()
+		}
+	}()
+
+	if connError != nil {
+		t.Fatalf("error connecting to vcenter: %v", connError)
+	}
+
+	for _, conn := range connections {
+		storagePolicyAPIClient := &storagePolicyAPI{
+			vcenterApiConnection: conn,
+			infra:                infra,
+			categoryName:         fmt.Sprintf(categoryNameTemplate, infra.Status.InfrastructureName),
+			policyName:           fmt.Sprintf(policyNameTemplate, infra.Status.InfrastructureName),
+			tagName:              infra.Status.InfrastructureName,
+			apiTestInfo:          map[string]int{},
+		}
+		storagePolicyAPIClient.setDryRun(true)
+
+		_, err := storagePolicyAPIClient.createStoragePolicy(context.TODO())
+		if err != nil {
+			t.Fatalf("Error planning storage policy: %v", err)
+		}
+
+		// No mutating call must be made in dry-run mode
+		validateAPICallCount(t, storagePolicyAPIClient, map[string]int{
+			create_tag_api:      0,
+			create_category_api: 0,
+			attach_tag_api:      0,
+			create_profile_api:  0,
+		})
+
+		var plannedAPIs []string
+		for _, change := range storagePolicyAPIClient.getPlannedChanges() {
+			plannedAPIs = append(plannedAPIs, change.API)
+		}
+		expectedAPIs := []string{create_category_api, create_tag_api, attach_tag_api, create_profile_api}
+		if strings.Join(plannedAPIs, ",") != strings.Join(expectedAPIs, ",") {
+			t.Errorf("expected planned changes %v, got %v", expectedAPIs, plannedAPIs)
+		}
+
+		found, err := storagePolicyAPIClient.checkForExistingPolicy(context.TODO())
+		if err != nil {
+			t.Fatalf("error while trying to find storage policy: %v", err)
+		}
+		if found {
+			t.Errorf("expected no policy to be created in dry-run mode")
+		}
+	}
+}
+
+func TestExistingTagsPolicyCreation(t *testing.T) {
+	infra := testlib.GetInfraObject()
+
+	connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	defer func() {
+		if cleanUpFunc != nil {
+			cleanUpFunc()
+		}
+	}()
+
+	if connError != nil {
+		t.Fatalf("error connecting to vcenter: %v", connError)
+	}
+
+	vCenterConfig := utils.VCenterConfig{
+		CategoryName:    "governance-k8s-storage",
+		TagName:         "governance-k8s-datastore",
+		PolicyName:      "governance-k8s-policy",
+		UseExistingTags: true,
+	}
+
+	for _, conn := range connections {
+		storagePolicyAPIClient := NewStoragePolicyAPI(context.TODO(), conn, infra, vCenterConfig).(*storagePolicyAPI)
+
+		// The category does not exist yet and the operator must not create it
+		_, err := storagePolicyAPIClient.createStoragePolicy(context.TODO())
+		var configErr *StoragePolicyConfigError
+		if !errors.As(err, &configErr) {
+			t.Fatalf("expected storage policy config error, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), vCenterConfig.CategoryName) {
+			t.Errorf("expected error to mention category %s, got: %v", vCenterConfig.CategoryName, err)
+		}
+
+		tagManager := tags.NewManager(conn.RestClient)
+		categoryID, err := tagManager.CreateCategory(context.TODO(), &tags.Category{
+			Name:            vCenterConfig.CategoryName,
+			AssociableTypes: []string{"Datastore"},
+			Cardinality:     "SINGLE",
+		})
+		if err != nil {
+			t.Fatalf("error creating category: %v", err)
+		}
+		_, err = storagePolicyAPIClient.createStoragePolicy(context.TODO())
+		if !errors.As(err, &configErr) || !strings.Contains(err.Error(), vCenterConfig.TagName) {
+			t.Fatalf("expected storage policy config error about missing tag, got: %v", err)
+		}
+
+		_, err = tagManager.CreateTag(context.TODO(), &tags.Tag{
+			Name:       vCenterConfig.TagName,
+			CategoryID: categoryID,
+		})
+		if err != nil {
+			t.Fatalf("error creating tag: %v", err)
+		}
+
+		policyName, err := storagePolicyAPIClient.createStoragePolicy(context.TODO())
+		if err != nil {
+			t.Fatalf("Error creating storage policy: %v", err)
+		}
+		if policyName != vCenterConfig.PolicyName {
+			t.Errorf("expected policy %s, got %s", vCenterConfig.PolicyName, policyName)
+		}
+		defer func() {
+			err := storagePolicyAPIClient.deleteStoragePolicy(context.TODO())
+			if err != nil {
+				t.Errorf("error deleting storage policy: %v", err)
+			}
+		}()
+
+		validateAPICallCount(t, storagePolicyAPIClient, map[string]int{
+			create_tag_api:      0,
+			update_tag_api:      0,
+			create_category_api: 0,
+			update_category_api: 0,
+			attach_tag_api:      1,
+			create_profile_api:  1,
+		})
+	}
+}
+
+func TestExistingCategoryUpdate(t *testing.T) {
+	tests := []struct {
+		name                      string
+		categoryName              string
+		description               string
+		associableTypes           []string
+		preserveForeignCategories bool
+		expectConfigError         bool
+		expectedAssociableTypes   []string
+		expectedUpdates           int
+	}{
+		{
+			name:                    "category with all required types is not updated",
+			associableTypes:         []string{"Datastore", "VirtualMachine"},
+			expectedAssociableTypes: []string{"urn:vim25:Datastore", "urn:vim25:VirtualMachine"},
+			expectedUpdates:         0,
+		},
+		{
+			name:                    "category associable with any type is not updated",
+			associableTypes:         nil,
+			expectedAssociableTypes: nil,
+			expectedUpdates:         0,
+		},
+		{
+			name:                    "category without Datastore gets only Datastore",
+			associableTypes:         []string{"VirtualMachine"},
+			expectedAssociableTypes: []string{"urn:vim25:Datastore", "urn:vim25:VirtualMachine"},
+			expectedUpdates:         1,
+		},
+		{
+			name:                      "foreign category is preserved",
+			categoryName:              "vmware-team-storage",
+			description:               "Owned by the VMware team",
+			associableTypes:           []string{"VirtualMachine"},
+			preserveForeignCategories: true,
+			expectConfigError:         true,
+			expectedAssociableTypes:   []string{"VirtualMachine"},
+			expectedUpdates:           0,
+		},
+		{
+			name:                      "category created by OpenShift is updated even when foreign categories are preserved",
+			categoryName:              "vmware-team-storage",
+			description:               ownedCategoryDescription,
+			associableTypes:           []string{"VirtualMachine"},
+			preserveForeignCategories: true,
+			expectedAssociableTypes:   []string{"urn:vim25:Datastore", "urn:vim25:VirtualMachine"},
+			expectedUpdates:           1,
+		},
+		{
+			name:                      "category of the cluster with edited description is updated when foreign categories are preserved",
+			description:               "Storage of the OpenShift cluster",
+			associableTypes:           []string{"VirtualMachine"},
+			preserveForeignCategories: true,
+			expectedAssociableTypes:   []string{"urn:vim25:Datastore", "urn:vim25:VirtualMachine"},
+			expectedUpdates:           1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := testlib.GetInfraObject()
+			connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+			defer func() {
+				if cleanUpFunc != nil {
+					cleanUpFunc()
+				}
+			}()
+			if connError != nil {
+				t.Fatalf("error connecting to vcenter: %v", connError)
+			}
+
+			conn := connections[0]
+			vCenterConfig := utils.VCenterConfig{CategoryName: test.categoryName, PreserveForeignCategories: test.preserveForeignCategories}
+			storagePolicyAPIClient := NewStoragePolicyAPI(context.TODO(), conn, infra, vCenterConfig).(*storagePolicyAPI)
+
+			tagManager := tags.NewManager(conn.RestClient)
+			_, err := tagManager.CreateCategory(context.TODO(), &tags.Category{
+				Name:            storagePolicyAPIClient.categoryName,
+				Description:     test.description,
+				AssociableTypes: test.associableTypes,
+				Cardinality:     "SINGLE",
+			})
+			if err != nil {
+				t.Fatalf("error creating category: %v", err)
+			}
+
+			_, err = storagePolicyAPIClient.createStoragePolicy(context.TODO())
+			var configErr *StoragePolicyConfigError
+			if test.expectConfigError != errors.As(err, &configErr) {
+				t.Fatalf("expected config error: %v, got: %v", test.expectConfigError, err)
+			}
+			if !test.expectConfigError {
+				if err != nil {
+					t.Fatalf("Error creating storage policy: %v", err)
+				}
+				defer func() {
+					if err := storagePolicyAPIClient.deleteStoragePolicy(context.TODO()); err != nil {
+						t.Errorf("error deleting storage policy: %v", err)
+					}
+				}()
+			}
+
+			validateAPICallCount(t, storagePolicyAPIClient, map[string]int{
+				create_category_api: 0,
+				update_category_api: test.expectedUpdates,
+			})
+
+			category, err := tagManager.GetCategory(context.TODO(), storagePolicyAPIClient.categoryName)
+			if err != nil {
+				t.Fatalf("error getting category: %v", err)
+			}
+			if strings.Join(appendPrefix(category.AssociableTypes), ",") != strings.Join(appendPrefix(test.expectedAssociableTypes), ",") {
+				t.Errorf("expected associable types %v, got %v", test.expectedAssociableTypes, category.AssociableTypes)
+			}
+		})
+	}
+}
+
 func validateAPICallCount(t *testing.T, vmwareAPI *storagePolicyAPI, expectedMap map[string]int) {
 	for k, v := range expectedMap {
 		actualCount := vmwareAPI.apiTestInfo[k]
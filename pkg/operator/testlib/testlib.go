@@ -14,6 +14,7 @@ import (
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -146,6 +147,12 @@ func AddInitialObjects(objects []runtime.Object, clients *utils.APIClient) error
 		case *opv1.ClusterCSIDriver:
 			clusterCSIDriverInformer := clients.ClusterCSIDriverInformer.Informer()
 			clusterCSIDriverInformer.GetStore().Add(obj)
+		case *appsv1.Deployment:
+			deploymentInformer := clients.KubeInformers.InformersFor(defaultNamespace).Apps().V1().Deployments().Informer()
+			deploymentInformer.GetStore().Add(obj)
+		case *appsv1.DaemonSet:
+			daemonSetInformer := clients.KubeInformers.InformersFor(defaultNamespace).Apps().V1().DaemonSets().Informer()
+			daemonSetInformer.GetStore().Add(obj)
 		default:
 			return fmt.Errorf("Unknown initalObject type: %+v", obj)
 		}
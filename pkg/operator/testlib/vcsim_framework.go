@@ -20,6 +20,7 @@ import (
 	pdbsimulator "github.com/vmware/govmomi/pbm/simulator"
 	"github.com/vmware/govmomi/simulator"
 	vapisimulator "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/types"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -214,6 +215,13 @@ func CustomizeVCenterVersion(version string, apiVersion string, conn *vclib.VSph
 	fmt.Printf("customize vcenter version")
 }
 
+// CustomizeSimulatorVCenterVersion sets the version the simulator reports to clients that connect after the call.
+func CustomizeSimulatorVCenterVersion(version string, apiVersion string) {
+	si := simulator.Map.Get(vim25.ServiceInstance).(*simulator.ServiceInstance)
+	si.Content.About.Version = version
+	si.Content.About.ApiVersion = apiVersion
+}
+
 func SetHWVersion(conn *vclib.VSphereConnection, node *v1.Node, hardwareVersion string) error {
 	err := CustomizeVM(conn, node, &types.VirtualMachineConfigSpec{
 		ExtraConfig: []types.BaseOptionValue{
@@ -3,8 +3,10 @@ package utils
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	opv1 "github.com/openshift/api/operator/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestSnapshotConfiguration(t *testing.T) {
@@ -73,3 +75,168 @@ func TestSnapshotConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestParseOperatorConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expected    *OperatorConfig
+		expectError bool
+	}{
+		{
+			name:     "empty config",
+			data:     "",
+			expected: &OperatorConfig{},
+		},
+		{
+			name: "per vCenter storage policy config",
+			data: `
+vcenters:
+  vcenter.example.com:
+    categoryName: k8s-category
+    tagName: k8s-tag
+    policyName: k8s-policy
+    useExistingTags: true
+`,
+			expected: &OperatorConfig{
+				VCenters: map[string]VCenterConfig{
+					"vcenter.example.com": {
+						CategoryName:    "k8s-category",
+						TagName:         "k8s-tag",
+						PolicyName:      "k8s-policy",
+						UseExistingTags: true,
+					},
+				},
+			},
+		},
+		{
+			name: "maintenance window",
+			data: `
+maintenance:
+  start: 2024-01-31T22:00:00Z
+  end: 2024-02-01T02:00:00Z
+`,
+			expected: &OperatorConfig{
+				Maintenance: &MaintenanceWindow{
+					Start: metav1.NewTime(time.Date(2024, 1, 31, 22, 0, 0, 0, time.UTC).Local()),
+					End:   metav1.NewTime(time.Date(2024, 2, 1, 2, 0, 0, 0, time.UTC).Local()),
+				},
+			},
+		},
+		{
+			name:        "maintenance window ends before its start",
+			data:        "maintenance:\n  start: 2024-02-01T02:00:00Z\n  end: 2024-01-31T22:00:00Z\n",
+			expectError: true,
+		},
+		{
+			name:        "maintenance window without end",
+			data:        "maintenance:\n  start: 2024-02-01T02:00:00Z\n",
+			expectError: true,
+		},
+		{
+			name: "check backoff",
+			data: `
+clusterCheckBackoff:
+  initial: 5m
+  max: 4h
+  factor: 1.5
+storagePolicyBackoff:
+  max: 2h
+`,
+			expected: &OperatorConfig{
+				ClusterCheckBackoff: &BackoffConfig{
+					Initial: metav1.Duration{Duration: 5 * time.Minute},
+					Max:     metav1.Duration{Duration: 4 * time.Hour},
+					Factor:  1.5,
+				},
+				StoragePolicyBackoff: &BackoffConfig{
+					Max: metav1.Duration{Duration: 2 * time.Hour},
+				},
+			},
+		},
+		{
+			name:        "check backoff max shorter than initial",
+			data:        "clusterCheckBackoff:\n  initial: 2h\n  max: 1h\n",
+			expectError: true,
+		},
+		{
+			name: "vCenter outage grace period",
+			data: "vCenterOutageGracePeriod: 30m\n",
+			expected: &OperatorConfig{
+				VCenterOutageGracePeriod: &metav1.Duration{Duration: 30 * time.Minute},
+			},
+		},
+		{
+			name:        "negative vCenter outage grace period",
+			data:        "vCenterOutageGracePeriod: -1h\n",
+			expectError: true,
+		},
+		{
+			name: "clock skew threshold and certificate expiry horizon",
+			data: "clockSkewThreshold: 2m\ncertificateExpiryHorizon: 360h\n",
+			expected: &OperatorConfig{
+				ClockSkewThreshold:       &metav1.Duration{Duration: 2 * time.Minute},
+				CertificateExpiryHorizon: &metav1.Duration{Duration: 360 * time.Hour},
+			},
+		},
+		{
+			name:        "zero clock skew threshold",
+			data:        "clockSkewThreshold: 0s\n",
+			expectError: true,
+		},
+		{
+			name:        "negative certificate expiry horizon",
+			data:        "certificateExpiryHorizon: -24h\n",
+			expectError: true,
+		},
+		{
+			name:        "unknown field",
+			data:        "vcenters:\n  vcenter.example.com:\n    category: foo\n",
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := ParseOperatorConfig(test.data)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(config, test.expected) {
+				t.Errorf("expected config %+v, got %+v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowIsActive(t *testing.T) {
+	start := time.Date(2024, 1, 31, 22, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+	window := &MaintenanceWindow{Start: metav1.NewTime(start), End: metav1.NewTime(end)}
+
+	tests := []struct {
+		name     string
+		window   *MaintenanceWindow
+		now      time.Time
+		expected bool
+	}{
+		{name: "no window", now: start},
+		{name: "before start", window: window, now: start.Add(-time.Second)},
+		{name: "at start", window: window, now: start, expected: true},
+		{name: "within window", window: window, now: start.Add(time.Hour), expected: true},
+		{name: "at end", window: window, now: end},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if active := test.window.IsActive(test.now); active != test.expected {
+				t.Errorf("expected active %v, got %v", test.expected, active)
+			}
+		})
+	}
+}
@@ -5,4 +5,10 @@ const (
 	VSphereDriverName               = "csi.vsphere.vmware.com"
 	DefaultNamespace                = "openshift-cluster-csi-drivers"
 	InfraGlobalName                 = "cluster"
+	// NodeIneligibleLabel marks nodes that do not meet requirements of the CSI driver, the driver DaemonSet
+	// does not run on them.
+	NodeIneligibleLabel = "vsphere.csi.openshift.io/driver-ineligible"
+	// RunChecksAnnotation of ClusterCSIDriver triggers vCenter and node checks when its value changes, without
+	// waiting for the next scheduled check.
+	RunChecksAnnotation = "vsphere.csi.openshift.io/run-checks"
 )
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	cfgv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// MaxPVSCSITargetsPerVMFeature lets the CSI driver attach more volumes to a node than its default limit.
+const MaxPVSCSITargetsPerVMFeature = "max-pvscsi-targets-per-vm"
+
+// RenderFeatureConfigMap returns the ConfigMap with feature states of the CSI driver from the given manifest,
+// with features enabled or disabled according to the cluster configuration. extendedVolumeLimit is true when
+// node VMs can attach more volumes than the default limit of the driver.
+func RenderFeatureConfigMap(manifest []byte, clusterCSIDriver *opv1.ClusterCSIDriver, infra *cfgv1.Infrastructure, extendedVolumeLimit bool) *v1.ConfigMap {
+	featureConfigMap := resourceread.ReadConfigMapV1OrDie(manifest)
+
+	topologyCategories := GetTopologyCategories(clusterCSIDriver, infra)
+	if len(topologyCategories) > 0 {
+		featureConfigMap.Data["improved-volume-topology"] = "true"
+	}
+
+	if extendedVolumeLimit {
+		featureConfigMap.Data[MaxPVSCSITargetsPerVMFeature] = "true"
+	}
+
+	if !isCSIMigrationSupported(infra) {
+		klog.V(4).Infof("Disabling CSI migration")
+		featureConfigMap.Data["csi-migration"] = "false"
+	}
+	return featureConfigMap
+}
+
+func isCSIMigrationSupported(infra *cfgv1.Infrastructure) bool {
+	// CSI migration is supported on all vSphere clusters unless they have 2 or more vCenters
+	if infra.Spec.PlatformSpec.VSphere == nil {
+		// Assume a default vSphere configuration with 1 vCenter
+		return true
+	}
+	if len(infra.Spec.PlatformSpec.VSphere.VCenters) > 1 {
+		return false
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"sync"
+
 	"k8s.io/component-base/metrics"
 	"k8s.io/component-base/metrics/legacyregistry"
 )
@@ -20,6 +22,16 @@ const (
 	scopeDatacenters    = "datacenters"
 	scopeDatastores     = "datastores"
 	scopeFailureDomains = "failure_domains"
+
+	vCenterLabel = "vcenter"
+	apiLabel     = "api"
+
+	checkLabel      = "check"
+	objectKindLabel = "kind"
+	hostLabel       = "host"
+	versionLabel    = "version"
+	buildLabel      = "build"
+	nameLabel       = "name"
 )
 
 var (
@@ -48,10 +60,122 @@ var (
 		},
 		[]string{domainScope},
 	)
+	VCenterMutatingCallsMetric = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name:           "vsphere_vcenter_mutating_calls_total",
+			Help:           "Number of vCenter API calls made by the operator that created or changed an object in vCenter",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{vCenterLabel, apiLabel},
+	)
+
+	CheckDurationMetric = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:           "vsphere_csi_driver_check_duration_seconds",
+			Help:           "Duration of vSphere environment checks",
+			Buckets:        metrics.ExponentialBuckets(0.1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{checkLabel},
+	)
+	CheckResultMetric = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "vsphere_csi_driver_check_result",
+			Help:           "Result of the last vSphere environment check: 0 - pass, 1 - block upgrade, 2 - block upgrade and driver install, 3 - degrade if the driver is installed, 4 - degrade",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{checkLabel},
+	)
+	CheckLastSuccessMetric = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "vsphere_csi_driver_check_last_success_timestamp_seconds",
+			Help:           "Unix time of the last vSphere environment check that passed",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{checkLabel},
+	)
+	CheckEvaluatedObjectsMetric = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "vsphere_csi_driver_check_evaluated_objects",
+			Help:           "Number of objects evaluated by the last vSphere environment check",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{checkLabel, objectKindLabel},
+	)
+	VCenterVersionInfoMetric = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "vsphere_vcenter_version_info",
+			Help:           "Version and build of vCenter",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{vCenterLabel, versionLabel, buildLabel},
+	)
+	ESXiVersionInfoMetric = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "vsphere_esxi_version_info",
+			Help:           "Version and build of ESXi hosts that run cluster nodes",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{vCenterLabel, hostLabel, versionLabel, buildLabel},
+	)
+	VCenterCertificateExpiryMetric = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "vsphere_vcenter_certificate_expiry_days",
+			Help:           "Days until the TLS certificate of vCenter expires",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{vCenterLabel},
+	)
+	OperandDriftMetric = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "vsphere_csi_driver_operand_drift",
+			Help:           "CSI driver operands that differ from what the operator would render while it is Unmanaged",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{objectKindLabel, nameLabel},
+	)
 )
 
 func init() {
 	legacyregistry.MustRegister(InstallErrorMetric)
 	legacyregistry.MustRegister(TopologyTagsMetric)
 	legacyregistry.MustRegister(InfrastructureFailureDomains)
+	legacyregistry.MustRegister(VCenterMutatingCallsMetric)
+	legacyregistry.MustRegister(CheckDurationMetric)
+	legacyregistry.MustRegister(CheckResultMetric)
+	legacyregistry.MustRegister(CheckLastSuccessMetric)
+	legacyregistry.MustRegister(CheckEvaluatedObjectsMetric)
+	legacyregistry.MustRegister(VCenterVersionInfoMetric)
+	legacyregistry.MustRegister(ESXiVersionInfoMetric)
+	legacyregistry.MustRegister(VCenterCertificateExpiryMetric)
+	legacyregistry.MustRegister(OperandDriftMetric)
+}
+
+var (
+	installErrorsLock sync.Mutex
+	// failure reasons of InstallErrorMetric series set by SetInstallError, indexed by condition
+	installErrors = map[string]map[string]bool{}
+)
+
+// SetInstallError sets InstallErrorMetric of the given failure reason and condition, so ClearInstallErrors can
+// remove it when the condition is over.
+func SetInstallError(reason, cond string) {
+	installErrorsLock.Lock()
+	defer installErrorsLock.Unlock()
+	if installErrors[cond] == nil {
+		installErrors[cond] = map[string]bool{}
+	}
+	installErrors[cond][reason] = true
+	InstallErrorMetric.WithLabelValues(reason, cond).Set(1)
+}
+
+// ClearInstallErrors removes all InstallErrorMetric series of the given condition set by SetInstallError and keeps
+// series of all other conditions.
+func ClearInstallErrors(cond string) {
+	installErrorsLock.Lock()
+	defer installErrorsLock.Unlock()
+	for reason := range installErrors[cond] {
+		InstallErrorMetric.Delete(map[string]string{failureReason: reason, condition: cond})
+	}
+	delete(installErrors, cond)
 }
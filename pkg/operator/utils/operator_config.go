@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelister "k8s.io/client-go/listers/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// OperatorConfigMapName is an optional ConfigMap in DefaultNamespace with operator settings
+	// that are not available in ClusterCSIDriver.
+	OperatorConfigMapName = "vmware-vsphere-csi-driver-operator-config"
+	OperatorConfigKey     = "config.yaml"
+)
+
+// OperatorConfig holds settings of the operator, as read from OperatorConfigMapName.
+type OperatorConfig struct {
+	// VCenters holds per vCenter settings. Key is vCenter hostname as used in Infrastructure.
+	VCenters map[string]VCenterConfig `json:"vcenters,omitempty"`
+	// Maintenance is a planned vCenter maintenance window. While it is in effect, failures of vCenter
+	// connection and cluster checks do not degrade the operator nor block upgrades.
+	Maintenance *MaintenanceWindow `json:"maintenance,omitempty"`
+	// ClusterCheckBackoff overrides timing of the vCenter and node checks.
+	ClusterCheckBackoff *BackoffConfig `json:"clusterCheckBackoff,omitempty"`
+	// StoragePolicyBackoff overrides timing of the storage policy sync.
+	StoragePolicyBackoff *BackoffConfig `json:"storagePolicyBackoff,omitempty"`
+	// VCenterOutageGracePeriod is how long one of several vCenters may be unreachable before the operator
+	// degrades, if the vCenter hosts volumes of the CSI driver. Until then only upgrades are blocked.
+	VCenterOutageGracePeriod *metav1.Duration `json:"vCenterOutageGracePeriod,omitempty"`
+	// ClockSkewThreshold is the maximum difference between vCenter and operator clocks. Larger skew blocks upgrades,
+	// because it breaks vCenter sessions and tokens.
+	ClockSkewThreshold *metav1.Duration `json:"clockSkewThreshold,omitempty"`
+	// CertificateExpiryHorizon is how long before expiry of the vCenter TLS certificate upgrades are blocked.
+	CertificateExpiryHorizon *metav1.Duration `json:"certificateExpiryHorizon,omitempty"`
+}
+
+// BackoffConfig is exponential backoff of a periodic check. Checks that fail are re-run after Initial, the interval
+// is multiplied by Factor after each failure up to Max. Checks that pass are re-run after Max. Unset fields keep
+// their defaults.
+type BackoffConfig struct {
+	Initial metav1.Duration `json:"initial,omitempty"`
+	Max     metav1.Duration `json:"max,omitempty"`
+	Factor  float64         `json:"factor,omitempty"`
+}
+
+// MaintenanceWindow is a time interval in RFC 3339 format, e.g. 2024-01-31T22:00:00Z.
+type MaintenanceWindow struct {
+	Start metav1.Time `json:"start"`
+	End   metav1.Time `json:"end"`
+}
+
+// VCenterConfig holds settings of the storage policy the operator creates in a single vCenter.
+type VCenterConfig struct {
+	// CategoryName is the name of the tag category used for the storage policy. Defaults to openshift-<infrastructure name>.
+	CategoryName string `json:"categoryName,omitempty"`
+	// TagName is the name of the tag attached to the datastores. Defaults to <infrastructure name>.
+	TagName string `json:"tagName,omitempty"`
+	// PolicyName is the name of the storage policy. Defaults to openshift-storage-policy-<infrastructure name>.
+	PolicyName string `json:"policyName,omitempty"`
+	// UseExistingTags makes the operator use the tag category and the tag as they are. The operator never
+	// creates or updates them and reports an error when they do not exist or can't be attached to datastores.
+	UseExistingTags bool `json:"useExistingTags,omitempty"`
+	// PreserveForeignCategories makes the operator report an error instead of adding associable types to an
+	// existing tag category that was not created by OpenShift. Categories named openshift-<infrastructure name>
+	// are created by OpenShift, categories with other names only when their description is the one OpenShift sets.
+	PreserveForeignCategories bool `json:"preserveForeignCategories,omitempty"`
+}
+
+// GetVCenterConfig returns settings for the given vCenter. It's safe to call on nil config.
+func (c *OperatorConfig) GetVCenterConfig(server string) VCenterConfig {
+	if c == nil {
+		return VCenterConfig{}
+	}
+	return c.VCenters[server]
+}
+
+// GetClusterCheckBackoff returns backoff of the cluster checks, with unset fields taken from defaults. It's safe
+// to call on nil config.
+func (c *OperatorConfig) GetClusterCheckBackoff(defaults wait.Backoff) wait.Backoff {
+	if c == nil {
+		return defaults
+	}
+	return c.ClusterCheckBackoff.apply(defaults)
+}
+
+// GetStoragePolicyBackoff returns backoff of the storage policy sync, with unset fields taken from defaults. It's
+// safe to call on nil config.
+func (c *OperatorConfig) GetStoragePolicyBackoff(defaults wait.Backoff) wait.Backoff {
+	if c == nil {
+		return defaults
+	}
+	return c.StoragePolicyBackoff.apply(defaults)
+}
+
+func (b *BackoffConfig) apply(backoff wait.Backoff) wait.Backoff {
+	if b == nil {
+		return backoff
+	}
+	if b.Initial.Duration > 0 {
+		backoff.Duration = b.Initial.Duration
+	}
+	if b.Max.Duration > 0 {
+		backoff.Cap = b.Max.Duration
+	}
+	if b.Factor > 0 {
+		backoff.Factor = b.Factor
+	}
+	return backoff
+}
+
+func (b *BackoffConfig) validate(name string) error {
+	if b == nil {
+		return nil
+	}
+	if b.Initial.Duration < 0 || b.Max.Duration < 0 {
+		return fmt.Errorf("%s durations must not be negative", name)
+	}
+	if b.Initial.Duration > 0 && b.Max.Duration > 0 && b.Max.Duration < b.Initial.Duration {
+		return fmt.Errorf("%s max must not be shorter than initial", name)
+	}
+	if b.Factor != 0 && b.Factor < 1 {
+		return fmt.Errorf("%s factor must be at least 1", name)
+	}
+	return nil
+}
+
+// GetVCenterOutageGracePeriod returns the configured grace period of a partial vCenter outage or the given default.
+// It's safe to call on nil config.
+func (c *OperatorConfig) GetVCenterOutageGracePeriod(defaultPeriod time.Duration) time.Duration {
+	if c == nil || c.VCenterOutageGracePeriod == nil {
+		return defaultPeriod
+	}
+	return c.VCenterOutageGracePeriod.Duration
+}
+
+// GetClockSkewThreshold returns the configured maximum clock skew of vCenter or the given default. It's safe to
+// call on nil config.
+func (c *OperatorConfig) GetClockSkewThreshold(defaultThreshold time.Duration) time.Duration {
+	if c == nil || c.ClockSkewThreshold == nil {
+		return defaultThreshold
+	}
+	return c.ClockSkewThreshold.Duration
+}
+
+// GetCertificateExpiryHorizon returns the configured horizon of vCenter certificate expiry or the given default.
+// It's safe to call on nil config.
+func (c *OperatorConfig) GetCertificateExpiryHorizon(defaultHorizon time.Duration) time.Duration {
+	if c == nil || c.CertificateExpiryHorizon == nil {
+		return defaultHorizon
+	}
+	return c.CertificateExpiryHorizon.Duration
+}
+
+// GetMaintenanceWindow returns the maintenance window, if it's configured. It's safe to call on nil config.
+func (c *OperatorConfig) GetMaintenanceWindow() *MaintenanceWindow {
+	if c == nil {
+		return nil
+	}
+	return c.Maintenance
+}
+
+// IsActive returns true when the given time is within the window. It's safe to call on nil window.
+func (w *MaintenanceWindow) IsActive(now time.Time) bool {
+	if w == nil {
+		return false
+	}
+	return !now.Before(w.Start.Time) && now.Before(w.End.Time)
+}
+
+// ParseOperatorConfig parses operator configuration from its YAML representation.
+func ParseOperatorConfig(data string) (*OperatorConfig, error) {
+	config := &OperatorConfig{}
+	if err := yaml.UnmarshalStrict([]byte(data), config); err != nil {
+		return nil, err
+	}
+	if w := config.Maintenance; w != nil {
+		if w.Start.IsZero() || w.End.IsZero() {
+			return nil, fmt.Errorf("maintenance window must have both start and end")
+		}
+		if !w.End.After(w.Start.Time) {
+			return nil, fmt.Errorf("maintenance window must end after its start")
+		}
+	}
+	if err := config.ClusterCheckBackoff.validate("clusterCheckBackoff"); err != nil {
+		return nil, err
+	}
+	if err := config.StoragePolicyBackoff.validate("storagePolicyBackoff"); err != nil {
+		return nil, err
+	}
+	if config.VCenterOutageGracePeriod != nil && config.VCenterOutageGracePeriod.Duration < 0 {
+		return nil, fmt.Errorf("vCenterOutageGracePeriod must not be negative")
+	}
+	if config.ClockSkewThreshold != nil && config.ClockSkewThreshold.Duration <= 0 {
+		return nil, fmt.Errorf("clockSkewThreshold must be positive")
+	}
+	if config.CertificateExpiryHorizon != nil && config.CertificateExpiryHorizon.Duration < 0 {
+		return nil, fmt.Errorf("certificateExpiryHorizon must not be negative")
+	}
+	return config, nil
+}
+
+// GetOperatorConfig returns the operator configuration. Missing ConfigMap is not an error, it results in
+// an empty configuration.
+func GetOperatorConfig(configMapLister corelister.ConfigMapLister) (*OperatorConfig, error) {
+	cm, err := configMapLister.ConfigMaps(DefaultNamespace).Get(OperatorConfigMapName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return &OperatorConfig{}, nil
+		}
+		return nil, err
+	}
+	config, err := ParseOperatorConfig(cm.Data[OperatorConfigKey])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s in ConfigMap %s/%s: %v", OperatorConfigKey, DefaultNamespace, OperatorConfigMapName, err)
+	}
+	return config, nil
+}
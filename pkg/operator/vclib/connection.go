@@ -2,8 +2,11 @@ package vclib
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 	"sync"
@@ -13,6 +16,7 @@ import (
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/version"
 	"github.com/vmware/govmomi/cns"
+	"github.com/vmware/govmomi/session"
 	"github.com/vmware/govmomi/vapi/rest"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/soap"
@@ -26,10 +30,6 @@ import (
 
 const apiTimeout = 10 * time.Minute
 
-var (
-	clientLock sync.Mutex
-)
-
 // VSphereConnection contains information for connecting to vCenter
 type VSphereConnection struct {
 	Client     *govmomi.Client
@@ -41,6 +41,13 @@ type VSphereConnection struct {
 	Port       string
 	Insecure   bool
 	Config     *VSphereConfig
+	// Proxy holds the cluster-wide proxy configuration. When nil, the default transport is used.
+	Proxy *ProxyConfig
+	// clientLock protects Client, connections to different vCenters can be made in parallel
+	clientLock sync.Mutex
+	// serverCertificate is the leaf TLS certificate vCenter presented in the last TLS handshake
+	serverCertificate *x509.Certificate
+	certificateLock   sync.Mutex
 }
 
 // VSphereConfig contains configuration for cloud provider.  It wraps the legacy version and the newer upstream version
@@ -144,8 +151,8 @@ func NewVSphereConnection(username, password, vcenter string, cfg *VSphereConfig
 // If connection.Client is already set, it obtains the existing user session.
 // if user session is not valid, connection.Client will be set to the new client.
 func (connection *VSphereConnection) Connect(ctx context.Context) error {
-	clientLock.Lock()
-	defer clientLock.Unlock()
+	connection.clientLock.Lock()
+	defer connection.clientLock.Unlock()
 	var err error
 
 	if connection.Client == nil {
@@ -181,10 +188,24 @@ func (connection *VSphereConnection) NewClient(ctx context.Context) error {
 	// Set user to nil to prevent login during client creation.
 	// See https://github.com/vmware/govmomi/blob/main/client.go#L91
 	serverURL.User = nil
-	client, err := govmomi.NewClient(tctx, serverURL, connection.Insecure)
+	soapClient := soap.NewClient(serverURL, connection.Insecure)
+	// The REST client is created from the SOAP client and shares its transport, so the
+	// proxy configuration below applies to both of them.
+	if connection.Proxy != nil {
+		err = connection.Proxy.configureTransport(soapClient.DefaultTransport())
+		if err != nil {
+			return fmt.Errorf("failed to configure proxy for vcenter %s: %v", serverAddress, err)
+		}
+	}
+	connection.recordServerCertificate(soapClient.DefaultTransport())
+	vimClient, err := vim25.NewClient(tctx, soapClient)
 	if err != nil {
 		return err
 	}
+	client := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
 
 	// Set up user agent before login
 	operatorVersion := version.Get()
@@ -210,11 +231,34 @@ func (connection *VSphereConnection) NewClient(ctx context.Context) error {
 	return nil
 }
 
+// recordServerCertificate makes the transport store the leaf certificate of each TLS handshake with vCenter. It's
+// called also for insecure connections, where the certificate is not verified.
+func (connection *VSphereConnection) recordServerCertificate(t *http.Transport) {
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = new(tls.Config)
+	}
+	t.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) > 0 {
+			connection.certificateLock.Lock()
+			connection.serverCertificate = state.PeerCertificates[0]
+			connection.certificateLock.Unlock()
+		}
+		return nil
+	}
+}
+
+// ServerCertificate returns the leaf TLS certificate of vCenter. It returns nil when no TLS connection was made yet.
+func (connection *VSphereConnection) ServerCertificate() *x509.Certificate {
+	connection.certificateLock.Lock()
+	defer connection.certificateLock.Unlock()
+	return connection.serverCertificate
+}
+
 // Logout calls SessionManager.Logout for the given connection.
 func (connection *VSphereConnection) Logout(ctx context.Context) error {
-	clientLock.Lock()
+	connection.clientLock.Lock()
 	c := connection.Client
-	clientLock.Unlock()
+	connection.clientLock.Unlock()
 
 	klog.V(4).Infof("vcenter-csi logging out from vcenter")
 	if c == nil {
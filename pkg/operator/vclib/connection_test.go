@@ -0,0 +1,48 @@
+package vclib
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	vapisimulator "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func TestServerCertificate(t *testing.T) {
+	model := simulator.VPX()
+	if err := model.Create(); err != nil {
+		t.Fatalf("error creating simulator model: %v", err)
+	}
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	defer s.Close()
+	patterns, handlers := vapisimulator.New(s.URL, simulator.Map)
+	for _, p := range patterns {
+		model.Service.Handle(p, handlers)
+	}
+
+	password, _ := s.URL.User.Password()
+	connection := &VSphereConnection{
+		Username: s.URL.User.Username(),
+		Password: password,
+		Hostname: s.URL.Host,
+		Insecure: true,
+	}
+	if connection.ServerCertificate() != nil {
+		t.Fatalf("expected no certificate before connecting")
+	}
+	if err := connection.Connect(context.TODO()); err != nil {
+		t.Fatalf("error connecting to simulator: %v", err)
+	}
+	defer connection.Logout(context.TODO())
+
+	certificate := connection.ServerCertificate()
+	if certificate == nil {
+		t.Fatalf("expected certificate of the simulator")
+	}
+	if !certificate.Equal(s.Server.Certificate()) {
+		t.Errorf("expected certificate %s, got %s", s.Server.Certificate().Subject, certificate.Subject)
+	}
+}
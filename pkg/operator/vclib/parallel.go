@@ -0,0 +1,26 @@
+package vclib
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ForEachConnection calls fn for all connections in parallel, so a slow vCenter does not delay the others. Each
+// call gets its own timeout. fn receives index of the connection, so it can store its results in the order of
+// connections. ForEachConnection returns errors of the calls in the order of connections.
+func ForEachConnection(ctx context.Context, connections []*VSphereConnection, timeout time.Duration, fn func(ctx context.Context, i int, conn *VSphereConnection) error) []error {
+	errs := make([]error, len(connections))
+	var wg sync.WaitGroup
+	for i, conn := range connections {
+		wg.Add(1)
+		go func(i int, conn *VSphereConnection) {
+			defer wg.Done()
+			tctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			errs[i] = fn(tctx, i, conn)
+		}(i, conn)
+	}
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,57 @@
+package vclib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEachConnection(t *testing.T) {
+	connections := []*VSphereConnection{
+		{Hostname: "vcenter1"},
+		{Hostname: "vcenter2"},
+		{Hostname: "vcenter3"},
+	}
+
+	// All calls must be running at the same time to pass the barrier
+	var started sync.WaitGroup
+	started.Add(len(connections))
+	allStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(allStarted)
+	}()
+
+	errs := ForEachConnection(context.TODO(), connections, time.Minute, func(ctx context.Context, i int, conn *VSphereConnection) error {
+		started.Done()
+		select {
+		case <-allStarted:
+		case <-time.After(10 * time.Second):
+			return fmt.Errorf("%s: other vCenters were not processed in parallel", conn.Hostname)
+		}
+		if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+			return fmt.Errorf("%s: expected a timeout", conn.Hostname)
+		}
+		if conn.Hostname == "vcenter2" {
+			return fmt.Errorf("%s: failed", conn.Hostname)
+		}
+		return nil
+	})
+
+	if len(errs) != len(connections) {
+		t.Fatalf("expected %d errors, got %d", len(connections), len(errs))
+	}
+	for i, err := range errs {
+		if i == 1 {
+			if err == nil || err.Error() != "vcenter2: failed" {
+				t.Errorf("expected error of vcenter2 at index 1, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error at index %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package vclib
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyConfig contains the cluster-wide proxy settings used when connecting to vCenter.
+type ProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// TrustedCABundle is a PEM encoded bundle of additional CAs, typically needed when
+	// the proxy re-encrypts traffic with its own certificate.
+	TrustedCABundle []byte
+}
+
+// ProxyFunc returns a function usable as http.Transport.Proxy. The function evaluates
+// httpProxy, httpsProxy and noProxy for every request, so each vCenter hostname is
+// matched against noProxy independently.
+func (p *ProxyConfig) ProxyFunc() func(*url.URL) (*url.URL, error) {
+	cfg := &httpproxy.Config{
+		HTTPProxy:  p.HTTPProxy,
+		HTTPSProxy: p.HTTPSProxy,
+		NoProxy:    p.NoProxy,
+	}
+	return cfg.ProxyFunc()
+}
+
+// configureTransport sets the proxy and the trusted CA bundle on the given transport.
+func (p *ProxyConfig) configureTransport(t *http.Transport) error {
+	proxyFunc := p.ProxyFunc()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+
+	if len(p.TrustedCABundle) == 0 {
+		return nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if ok := pool.AppendCertsFromPEM(p.TrustedCABundle); !ok {
+		return fmt.Errorf("failed to parse trusted CA bundle")
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = new(tls.Config)
+	}
+	t.TLSClientConfig.RootCAs = pool
+	return nil
+}
@@ -0,0 +1,71 @@
+package vclib
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestProxyFunc(t *testing.T) {
+	proxyConfig := &ProxyConfig{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "http://secure-proxy.example.com:3128",
+		NoProxy:    "vcenter-internal.example.com,.lan",
+	}
+
+	tests := []struct {
+		name          string
+		vcenterURL    string
+		expectedProxy string
+	}{
+		{
+			name:          "https vCenter uses httpsProxy",
+			vcenterURL:    "https://vcenter.example.com/sdk",
+			expectedProxy: "http://secure-proxy.example.com:3128",
+		},
+		{
+			name:          "http vCenter uses httpProxy",
+			vcenterURL:    "http://vcenter.example.com/sdk",
+			expectedProxy: "http://proxy.example.com:3128",
+		},
+		{
+			name:          "vCenter listed in noProxy is not proxied",
+			vcenterURL:    "https://vcenter-internal.example.com/sdk",
+			expectedProxy: "",
+		},
+		{
+			name:          "vCenter matching noProxy domain suffix is not proxied",
+			vcenterURL:    "https://vcenter.lan/sdk",
+			expectedProxy: "",
+		},
+	}
+
+	proxyFunc := proxyConfig.ProxyFunc()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, _ := url.Parse(test.vcenterURL)
+			proxyURL, err := proxyFunc(u)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			proxy := ""
+			if proxyURL != nil {
+				proxy = proxyURL.String()
+			}
+			if proxy != test.expectedProxy {
+				t.Errorf("expected proxy %q, got %q", test.expectedProxy, proxy)
+			}
+		})
+	}
+}
+
+func TestConfigureTransportWithInvalidCABundle(t *testing.T) {
+	proxyConfig := &ProxyConfig{
+		HTTPSProxy:      "http://proxy.example.com:3128",
+		TrustedCABundle: []byte("not a certificate"),
+	}
+	err := proxyConfig.configureTransport(&http.Transport{})
+	if err == nil {
+		t.Fatalf("expected error for invalid CA bundle")
+	}
+}
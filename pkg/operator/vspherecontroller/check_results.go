@@ -0,0 +1,80 @@
+package vspherecontroller
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// checkResultsConfigMapName holds results of the last cluster check in a machine readable form
+	checkResultsConfigMapName = "vmware-vsphere-csi-driver-operator-check-results"
+	checkResultsKey           = "results.json"
+)
+
+// checkResults is the content of checkResultsConfigMapName.
+type checkResults struct {
+	LastCheck metav1.Time `json:"lastCheck"`
+	NextCheck metav1.Time `json:"nextCheck"`
+	// Severity is the most severe action of all failed checks, Pass when all checks passed
+	Severity string `json:"severity"`
+	// Results lists all failed checks, including the vCenter connection and the storage policy sync
+	Results []checks.CheckReport `json:"results"`
+}
+
+// publishCheckResults saves results of the last check, of the vCenter connection and of the storage policy sync to
+// checkResultsConfigMapName, so tools don't need to parse messages of the operator conditions. The ConfigMap is
+// updated only when the results changed.
+func (c *VSphereController) publishCheckResults(ctx context.Context, connectionResult checks.ClusterCheckResult) error {
+	results := checkResults{
+		Results: []checks.CheckReport{},
+	}
+	var severity checks.CheckAction = checks.CheckActionPass
+	addResult := func(result checks.ClusterCheckResult) {
+		if result.CheckError == nil {
+			return
+		}
+		results.Results = append(results.Results, checks.MakeCheckReport(result))
+		if result.Action > severity {
+			severity = result.Action
+		}
+	}
+
+	addResult(connectionResult)
+	state, found := c.vSphereChecker.GetState()
+	if found {
+		results.LastCheck = state.LastCheck
+		results.NextCheck = state.NextCheck
+		if state.Result.Action > severity {
+			severity = state.Result.Action
+		}
+		for _, saved := range state.Results {
+			addResult(saved.Restore())
+		}
+	}
+	if c.nodeStatuses != nil {
+		results.Results = append(results.Results, c.nodeStatuses.Reports()...)
+	}
+	if c.operandControllerStarted {
+		addResult(c.storageClassController.LastResult())
+	}
+	if !found && len(results.Results) == 0 {
+		return nil
+	}
+	results.Severity = checks.ActionToString(severity)
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	if string(data) == c.publishedCheckResults {
+		return nil
+	}
+	if err := c.applyConfigMapData(ctx, checkResultsConfigMapName, map[string]string{checkResultsKey: string(data)}); err != nil {
+		return err
+	}
+	c.publishedCheckResults = string(data)
+	return nil
+}
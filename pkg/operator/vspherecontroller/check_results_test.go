@@ -0,0 +1,170 @@
+package vspherecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPublishCheckResults(t *testing.T) {
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+	ctrl := newVsphereController(commonApiClient)
+	ctx := context.TODO()
+
+	vCenterResult := checks.MakeClusterUnupgradeableError(checks.CheckStatusDeprecatedVCenter, fmt.Errorf("old vCenter")).
+		WithObject(checks.ObjectKindVCenter, "vcenter.example.com").
+		WithValues("7.0.1", "7.0.2")
+	hostResult := checks.MakeClusterUnupgradeableError(checks.CheckStatusDeprecatedESXIVersion, fmt.Errorf("old host")).
+		WithObject(checks.ObjectKindHost, "host1").
+		WithValues("7.0.1", "7.0.2")
+	checker := newVSphereEnvironmentChecker()
+	checker.RestoreState(checkerState{
+		LastCheck: metav1.NewTime(time.Now()),
+		NextCheck: metav1.NewTime(time.Now().Add(time.Minute)),
+		Result:    checks.SaveCheckResult(vCenterResult),
+		Results:   []checks.SavedCheckResult{checks.SaveCheckResult(vCenterResult)},
+	})
+	ctrl.vSphereChecker = checker
+	ctrl.nodeStatuses.Restore(map[string]checks.SavedNodeStatus{
+		"node1": {VolumeLimit: checks.NodeVolumeLimit{Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15}, Result: checks.SaveCheckResult(hostResult), VMLookup: checks.VMLookupProviderID},
+		"node2": {VolumeLimit: checks.NodeVolumeLimit{HardwareVersion: 13}, IneligibleReason: "old hardware version", VMLookup: checks.VMLookupSystemUUID},
+		"node3": {VolumeLimit: checks.NodeVolumeLimit{Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15}, VMLookup: checks.VMLookupProviderID},
+		"node4": {NotInitialized: true},
+		"node5": {VolumeLimit: checks.NodeVolumeLimit{Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15}, VMLookup: checks.VMLookupName},
+	})
+
+	if err := ctrl.publishCheckResults(ctx, checks.MakeClusterCheckResultPass()); err != nil {
+		t.Fatalf("error publishing check results: %v", err)
+	}
+
+	cm, err := ctrl.kubeClient.CoreV1().ConfigMaps(defaultNamespace).Get(ctx, checkResultsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting ConfigMap with check results: %v", err)
+	}
+	results := checkResults{}
+	if err := json.Unmarshal([]byte(cm.Data[checkResultsKey]), &results); err != nil {
+		t.Fatalf("error parsing check results: %v", err)
+	}
+
+	expectedResults := []checks.CheckReport{
+		{
+			CheckID:  checks.CheckStatusDeprecatedVCenter,
+			Severity: "BlockUpgrade",
+			Kind:     checks.ObjectKindVCenter,
+			Name:     "vcenter.example.com",
+			Observed: "7.0.1",
+			Required: "7.0.2",
+			Message:  "old vCenter",
+		},
+		{
+			CheckID:  checks.CheckStatusDeprecatedESXIVersion,
+			Severity: "BlockUpgrade",
+			Kind:     checks.ObjectKindHost,
+			Name:     "host1",
+			Observed: "7.0.1",
+			Required: "7.0.2",
+			Message:  "old host",
+			VMLookup: checks.VMLookupProviderID,
+		},
+		{
+			CheckID:  checks.CheckStatusDeprecatedHWVersion,
+			Severity: "BlockUpgrade",
+			Kind:     checks.ObjectKindNode,
+			Name:     "node2",
+			Observed: "vmx-13",
+			Required: "vmx-15",
+			Message:  "old hardware version",
+			VMLookup: checks.VMLookupSystemUUID,
+		},
+		{
+			CheckID:        checks.CheckStatusNodeNotInitialized,
+			Severity:       "Pass",
+			Kind:           checks.ObjectKindNode,
+			Name:           "node4",
+			Message:        "the node is not initialized yet, it has no providerID and its VM was not found by system UUID or name",
+			NotInitialized: true,
+		},
+		{
+			CheckID:  checks.CheckStatusNodeVMLookup,
+			Severity: "Pass",
+			Kind:     checks.ObjectKindNode,
+			Name:     "node5",
+			Message:  "the node VM was found by name because the node has no valid providerID",
+			VMLookup: checks.VMLookupName,
+		},
+	}
+	if results.Severity != "BlockUpgrade" {
+		t.Errorf("expected overall severity BlockUpgrade, got %s", results.Severity)
+	}
+	if !reflect.DeepEqual(results.Results, expectedResults) {
+		t.Errorf("expected results %+v, got %+v", expectedResults, results.Results)
+	}
+
+	// The node that is not initialized is reported in an event once
+	ctrl.reportNotInitializedNodes()
+	ctrl.reportNotInitializedNodes()
+	if count := countEvents(ctrl.eventRecorder, nodeNotInitializedEvent); count != 1 {
+		t.Errorf("expected 1 %s event, got %d", nodeNotInitializedEvent, count)
+	}
+}
+
+func TestPublishConnectionAndStoragePolicyResults(t *testing.T) {
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+	ctrl := newVsphereController(commonApiClient)
+	ctx := context.TODO()
+	getResults := func() *checkResults {
+		cm, err := ctrl.kubeClient.CoreV1().ConfigMaps(defaultNamespace).Get(ctx, checkResultsConfigMapName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("error getting ConfigMap with check results: %v", err)
+		}
+		results := &checkResults{}
+		if err := json.Unmarshal([]byte(cm.Data[checkResultsKey]), results); err != nil {
+			t.Fatalf("error parsing check results: %v", err)
+		}
+		return results
+	}
+
+	// vCenter can't be reached before the first check
+	connectionResult := checks.MakeClusterDegradedError(checks.CheckStatusVSphereConnectionFailed, fmt.Errorf("connection refused"))
+	if err := ctrl.publishCheckResults(ctx, connectionResult); err != nil {
+		t.Fatalf("error publishing check results: %v", err)
+	}
+	results := getResults()
+	if results == nil || results.Severity != "Degrade" || len(results.Results) != 1 || results.Results[0].CheckID != checks.CheckStatusVSphereConnectionFailed {
+		t.Errorf("expected the connection failure to be published, got %+v", results)
+	}
+
+	// Unchanged results are not written again
+	if err := ctrl.kubeClient.CoreV1().ConfigMaps(defaultNamespace).Delete(ctx, checkResultsConfigMapName, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("error deleting ConfigMap with check results: %v", err)
+	}
+	if err := ctrl.publishCheckResults(ctx, connectionResult); err != nil {
+		t.Fatalf("error publishing check results: %v", err)
+	}
+	if results := getResults(); results != nil {
+		t.Errorf("expected unchanged results not to be published, got %+v", results)
+	}
+
+	// The storage policy sync fails after the connection is restored
+	ctrl.operandControllerStarted = true
+	ctrl.storageClassController.(*dummyStorageClassController).lastResult = checks.MakeClusterUnupgradeableError(checks.CheckStatusStoragePolicyConfig, fmt.Errorf("no such datastore"))
+	if err := ctrl.publishCheckResults(ctx, checks.MakeClusterCheckResultPass()); err != nil {
+		t.Fatalf("error publishing check results: %v", err)
+	}
+	results = getResults()
+	if results == nil || results.Severity != "BlockUpgrade" || len(results.Results) != 1 || results.Results[0].CheckID != checks.CheckStatusStoragePolicyConfig {
+		t.Errorf("expected the storage policy failure to be published, got %+v", results)
+	}
+}
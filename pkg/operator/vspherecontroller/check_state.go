@@ -0,0 +1,163 @@
+package vspherecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/storageclasscontroller"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	checkStateConfigMapName = "vmware-vsphere-csi-driver-operator-check-state"
+	checkStateKey           = "state.json"
+	checksTriggeredEvent    = "ClusterChecksTriggered"
+)
+
+// operatorCheckState is saved in checkStateConfigMapName after each check, so a restarted operator resumes
+// the check schedule instead of running all checks against vCenter at once.
+type operatorCheckState struct {
+	Checks checkerState                      `json:"checks"`
+	Nodes  map[string]checks.SavedNodeStatus `json:"nodes,omitempty"`
+	// RunChecksTrigger is the last processed value of utils.RunChecksAnnotation
+	RunChecksTrigger string `json:"runChecksTrigger,omitempty"`
+	// StoragePolicies is the schedule of the storage policy sync
+	StoragePolicies *storageclasscontroller.StoragePolicySyncState `json:"storagePolicies,omitempty"`
+	// VCenterOutages are vCenters that can't be reached and the time since when, so the outage grace period
+	// does not restart with the operator
+	VCenterOutages map[string]metav1.Time `json:"vCenterOutages,omitempty"`
+}
+
+// restoreCheckState loads the check state saved by a previous instance of the operator. It runs only once.
+func (c *VSphereController) restoreCheckState(ctx context.Context, syncContext factory.SyncContext) error {
+	if c.checkStateRestored {
+		return nil
+	}
+	cm, err := c.kubeClient.CoreV1().ConfigMaps(c.targetNamespace).Get(ctx, checkStateConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.checkStateRestored = true
+			return nil
+		}
+		return fmt.Errorf("failed to get saved check state: %v", err)
+	}
+	c.checkStateRestored = true
+
+	state := &operatorCheckState{}
+	if err := json.Unmarshal([]byte(cm.Data[checkStateKey]), state); err != nil {
+		klog.Warningf("Ignoring invalid check state in ConfigMap %s/%s: %v", c.targetNamespace, checkStateConfigMapName, err)
+		return nil
+	}
+	c.runChecksTrigger = state.RunChecksTrigger
+	if len(state.VCenterOutages) > 0 {
+		c.vCenterOutages = make(map[string]time.Time, len(state.VCenterOutages))
+		for server, since := range state.VCenterOutages {
+			klog.Infof("Resuming outage of vCenter %s not available since %s", server, since)
+			c.vCenterOutages[server] = since.Time
+		}
+	}
+
+	// Nothing else schedules the next check until a check runs
+	queue := syncContext.Queue()
+	queueKey := syncContext.QueueKey()
+	if !state.Checks.LastCheck.IsZero() {
+		klog.Infof("Resuming cluster checks last run at %s, the next check is at %s", state.Checks.LastCheck, state.Checks.NextCheck)
+		c.vSphereChecker.RestoreState(state.Checks)
+		if c.nodeStatuses != nil {
+			c.nodeStatuses.Restore(state.Nodes)
+		}
+		time.AfterFunc(time.Until(state.Checks.NextCheck.Time), func() {
+			queue.Add(queueKey)
+		})
+	}
+	if state.StoragePolicies != nil && c.storageClassController != nil {
+		klog.Infof("Resuming storage policy sync last run at %s, the next sync is at %s", state.StoragePolicies.LastSync, state.StoragePolicies.NextSync)
+		c.storageClassController.RestoreState(*state.StoragePolicies)
+		time.AfterFunc(time.Until(state.StoragePolicies.NextSync.Time), func() {
+			queue.Add(queueKey)
+		})
+	}
+	return nil
+}
+
+// saveCheckState saves the schedule and results of the checks, the schedule of the storage policy sync and
+// outages of vCenters.
+func (c *VSphereController) saveCheckState(ctx context.Context) error {
+	checkerState, checksFound := c.vSphereChecker.GetState()
+	state := operatorCheckState{
+		Checks:           checkerState,
+		RunChecksTrigger: c.runChecksTrigger,
+	}
+	if checksFound && c.nodeStatuses != nil {
+		state.Nodes = c.nodeStatuses.Save()
+	}
+	if c.storageClassController != nil {
+		if policyState, found := c.storageClassController.GetState(); found {
+			state.StoragePolicies = &policyState
+		}
+	}
+	if len(c.vCenterOutages) > 0 {
+		state.VCenterOutages = make(map[string]metav1.Time, len(c.vCenterOutages))
+		for server, since := range c.vCenterOutages {
+			state.VCenterOutages[server] = metav1.NewTime(since)
+		}
+	}
+	if !checksFound && state.StoragePolicies == nil && state.VCenterOutages == nil {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return c.applyConfigMapData(ctx, checkStateConfigMapName, map[string]string{checkStateKey: string(data)})
+}
+
+// applyConfigMapData creates or updates a ConfigMap of the operator with the given data.
+func (c *VSphereController) applyConfigMapData(ctx context.Context, name string, data map[string]string) error {
+	client := c.kubeClient.CoreV1().ConfigMaps(c.targetNamespace)
+	cm, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: c.targetNamespace,
+			},
+			Data: data,
+		}
+		_, err = client.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	cm = cm.DeepCopy()
+	cm.Data = data
+	_, err = client.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// processRunChecksTrigger makes the next sync run all checks and sync storage policies when utils.RunChecksAnnotation of ClusterCSIDriver
+// has a new value.
+func (c *VSphereController) processRunChecksTrigger(clusterCSIDriver *operatorapi.ClusterCSIDriver) {
+	trigger := clusterCSIDriver.Annotations[utils.RunChecksAnnotation]
+	if trigger == "" || trigger == c.runChecksTrigger {
+		return
+	}
+	klog.Infof("Running cluster checks on request, %s changed to %q", utils.RunChecksAnnotation, trigger)
+	c.eventRecorder.Eventf(checksTriggeredEvent, "Running cluster checks on request, %s is %q", utils.RunChecksAnnotation, trigger)
+	c.vSphereChecker.ResetExpBackoff()
+	if c.storageClassController != nil {
+		c.storageClassController.ResetBackoff()
+	}
+	c.runChecksTrigger = trigger
+}
@@ -0,0 +1,98 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/storageclasscontroller"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestCheckStatePersistence(t *testing.T) {
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+	ctrl := newVsphereController(commonApiClient)
+	ctx := context.TODO()
+
+	failed := checks.MakeClusterUnupgradeableError(checks.CheckStatusDeprecatedHWVersion, fmt.Errorf("old hardware version"))
+	nextCheck := time.Now().Add(time.Hour).Truncate(time.Second)
+	lastSuccess := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	checker := newVSphereEnvironmentChecker()
+	checker.RestoreState(checkerState{
+		LastCheck:    metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second)),
+		NextCheck:    metav1.NewTime(nextCheck),
+		BackoffSteps: 2,
+		Result:       checks.SaveCheckResult(failed),
+		LastSuccess:  map[string]metav1.Time{"CheckNodes": metav1.NewTime(lastSuccess)},
+	})
+	ctrl.vSphereChecker = checker
+	ctrl.nodeStatuses.Restore(map[string]checks.SavedNodeStatus{
+		"node1": {VolumeLimit: checks.NodeVolumeLimit{Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15}},
+		"node2": {IneligibleReason: "old hardware version", Result: checks.SaveCheckResult(failed)},
+	})
+	policyState := storageclasscontroller.StoragePolicySyncState{
+		LastSync:     metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second)),
+		NextSync:     metav1.NewTime(nextCheck),
+		BackoffSteps: 1,
+		PolicyName:   "openshift-storage-policy-test",
+	}
+	ctrl.storageClassController.RestoreState(policyState)
+	outageStart := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+	ctrl.vCenterOutages = map[string]time.Time{"vcenter2.lan": outageStart}
+	if err := ctrl.saveCheckState(ctx); err != nil {
+		t.Fatalf("error saving check state: %v", err)
+	}
+
+	// A restarted operator resumes the schedule and the last success of the checks
+	utils.CheckLastSuccessMetric.Reset()
+	restarted := newVsphereController(commonApiClient)
+	if err := restarted.restoreCheckState(ctx, factory.NewSyncContext("vsphere-controller", restarted.eventRecorder)); err != nil {
+		t.Fatalf("error restoring check state: %v", err)
+	}
+	restoredChecker := restarted.vSphereChecker.(*vSphereEnvironmentCheckerComposite)
+	if !restoredChecker.nextCheck.Equal(nextCheck) || restoredChecker.backoffSteps != 2 {
+		t.Errorf("expected next check at %s after 2 failed checks, got %s after %d", nextCheck, restoredChecker.nextCheck, restoredChecker.backoffSteps)
+	}
+	if value, err := testutil.GetGaugeMetricValue(utils.CheckLastSuccessMetric.WithLabelValues("CheckNodes")); err != nil || value != float64(lastSuccess.Unix()) {
+		t.Errorf("expected restored last success %d of CheckNodes, got %v: %v", lastSuccess.Unix(), value, err)
+	}
+	if limit, found := restarted.nodeStatuses.ClusterVolumeLimit(); !found || limit != 62 {
+		t.Errorf("expected restored volume limit 62, got %d", limit)
+	}
+	if nodes := restarted.nodeStatuses.IneligibleNodes(); len(nodes) != 1 || nodes[0] != "node2" {
+		t.Errorf("expected node2 to be ineligible, got %v", nodes)
+	}
+	restoredPolicyState, found := restarted.storageClassController.GetState()
+	if !found || !restoredPolicyState.NextSync.Equal(&policyState.NextSync) || restoredPolicyState.BackoffSteps != 1 || restoredPolicyState.PolicyName != policyState.PolicyName {
+		t.Errorf("expected restored storage policy sync state %+v, got %+v", policyState, restoredPolicyState)
+	}
+	if since, found := restarted.vCenterOutages["vcenter2.lan"]; !found || !since.Equal(outageStart) {
+		t.Errorf("expected outage of vcenter2.lan since %s to be restored, got %v", outageStart, restarted.vCenterOutages)
+	}
+	if _, _, checkRan := restarted.vSphereChecker.Check(ctx, checks.CheckArgs{}); checkRan {
+		t.Errorf("expected check not to run before the restored next check")
+	}
+
+	// The trigger runs the checks immediately, but only once for each value
+	clusterCSIDriver := testlib.GetClusterCSIDriver(false)
+	clusterCSIDriver.Annotations = map[string]string{utils.RunChecksAnnotation: "1"}
+	restarted.processRunChecksTrigger(clusterCSIDriver)
+	if restoredChecker.nextCheck.After(time.Now()) {
+		t.Errorf("expected checks to be due after the trigger, next check is at %s", restoredChecker.nextCheck)
+	}
+	if !restarted.storageClassController.(*dummyStorageClassController).backoffReset {
+		t.Errorf("expected the trigger to reset the storage policy sync backoff")
+	}
+	restoredChecker.nextCheck = nextCheck
+	restarted.processRunChecksTrigger(clusterCSIDriver)
+	if !restoredChecker.nextCheck.Equal(nextCheck) {
+		t.Errorf("expected the same trigger to be processed only once")
+	}
+}
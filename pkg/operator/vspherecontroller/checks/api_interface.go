@@ -2,6 +2,7 @@ package checks
 
 import (
 	ocpv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	v1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -11,12 +12,14 @@ import (
 )
 
 type KubeAPIInterface interface {
-	// ListLinuxNodes returns list of all linux nodes in the cluster.
-	ListLinuxNodes() ([]*v1.Node, error)
+	// ListNodes returns list of all linux and windows nodes in the cluster.
+	ListNodes() ([]*v1.Node, error)
 	GetCSIDriver(name string) (*storagev1.CSIDriver, error)
 	ListCSINodes() ([]*storagev1.CSINode, error)
 	GetStorageClass(name string) (*storagev1.StorageClass, error)
 	GetInfrastructure() *ocpv1.Infrastructure
+	// GetOperatorConfig returns the operator configuration. It may return nil, when the configuration is not set.
+	GetOperatorConfig() *utils.OperatorConfig
 }
 
 type KubeAPIInterfaceImpl struct {
@@ -25,20 +28,25 @@ type KubeAPIInterfaceImpl struct {
 	CSINodeLister      storagelister.CSINodeLister
 	CSIDriverLister    storagelister.CSIDriverLister
 	StorageClassLister storagelister.StorageClassLister
+	OperatorConfig     *utils.OperatorConfig
 }
 
-func getLinuxNodeSelector() labels.Selector {
+func getNodeSelector() labels.Selector {
 	labelSelector := metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			"kubernetes.io/os": "linux",
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      "kubernetes.io/os",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   []string{"linux", "windows"},
+			},
 		},
 	}
 	selector, _ := metav1.LabelSelectorAsSelector(&labelSelector)
 	return selector
 }
 
-func (k *KubeAPIInterfaceImpl) ListLinuxNodes() ([]*v1.Node, error) {
-	return k.NodeLister.List(getLinuxNodeSelector())
+func (k *KubeAPIInterfaceImpl) ListNodes() ([]*v1.Node, error) {
+	return k.NodeLister.List(getNodeSelector())
 }
 
 func (k *KubeAPIInterfaceImpl) GetCSIDriver(name string) (*storagev1.CSIDriver, error) {
@@ -56,3 +64,7 @@ func (k *KubeAPIInterfaceImpl) GetStorageClass(name string) (*storagev1.StorageC
 func (k *KubeAPIInterfaceImpl) GetInfrastructure() *ocpv1.Infrastructure {
 	return k.Infrastructure
 }
+
+func (k *KubeAPIInterfaceImpl) GetOperatorConfig() *utils.OperatorConfig {
+	return k.OperatorConfig
+}
@@ -20,6 +20,14 @@ const (
 	CheckStatusDeprecatedESXIVersion   CheckStatusType = "check_deprecated_esxi_version"
 	CheckStatusVcenterAPIError         CheckStatusType = "vcenter_api_error"
 	CheckStatusGenericError            CheckStatusType = "generic_error"
+	CheckStatusStoragePolicyConfig     CheckStatusType = "storage_policy_config_error"
+	CheckStatusVCenterClockSkew        CheckStatusType = "check_vcenter_clock_skew"
+	CheckStatusVCenterCertificate      CheckStatusType = "check_vcenter_certificate_expiry"
+	CheckStatusCNSUnavailable          CheckStatusType = "cns_service_unavailable"
+	// CheckStatusNodeNotInitialized and CheckStatusNodeVMLookup are not failures, they're only reported in
+	// the check results.
+	CheckStatusNodeNotInitialized CheckStatusType = "node_not_initialized"
+	CheckStatusNodeVMLookup       CheckStatusType = "node_vm_lookup"
 )
 
 type ClusterCheckStatus string
@@ -68,6 +76,37 @@ type ClusterCheckResult struct {
 	// this message contains information about why we are degrading the cluster
 	// or blocking upgrades.
 	Reason string
+	// Object is the object the check failed for. It's empty when the failure is not related to a single object.
+	Object CheckObject
+	// Observed and Required are the observed and the required value, e.g. a version, of checks that compare them.
+	Observed string
+	Required string
+}
+
+// CheckObject identifies an object in vCenter or in the cluster.
+type CheckObject struct {
+	// Kind is a vCenter managed object type, like HostSystem, or Node and VCenter.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+const (
+	ObjectKindVCenter = "VCenter"
+	ObjectKindHost    = "HostSystem"
+	ObjectKindNode    = "Node"
+)
+
+// WithObject returns the result with the object it's related to.
+func (r ClusterCheckResult) WithObject(kind, name string) ClusterCheckResult {
+	r.Object = CheckObject{Kind: kind, Name: name}
+	return r
+}
+
+// WithValues returns the result with the observed and the required value.
+func (r ClusterCheckResult) WithValues(observed, required string) ClusterCheckResult {
+	r.Observed = observed
+	r.Required = required
+	return r
 }
 
 func MakeClusterCheckResultPass() ClusterCheckResult {
@@ -150,7 +189,9 @@ func CheckClusterStatus(result ClusterCheckResult, apiDependencies KubeAPIInterf
 		}
 
 		if driverFound {
-			return ClusterCheckDegrade, MakeClusterDegradedError(result.CheckStatus, result.CheckError)
+			degraded := MakeClusterDegradedError(result.CheckStatus, result.CheckError)
+			degraded.Object, degraded.Observed, degraded.Required = result.Object, result.Observed, result.Required
+			return ClusterCheckDegrade, degraded
 		}
 
 		// if we can't connect to vcenter, we can't really block upgrades but
@@ -10,6 +10,10 @@ import (
 
 type CheckExistingDriver struct{}
 
+func (c *CheckExistingDriver) Name() string {
+	return "existing_driver"
+}
+
 func (c *CheckExistingDriver) Check(ctx context.Context, checkOpts CheckArgs) []ClusterCheckResult {
 	csiDriver, err := checkOpts.apiClient.GetCSIDriver(utils.VSphereDriverName)
 	if err != nil {
@@ -3,17 +3,19 @@ package checks
 import (
 	"context"
 	"fmt"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	ocpv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
-	"github.com/vmware/govmomi/find"
-	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 )
 
@@ -27,7 +29,7 @@ const (
 )
 
 var (
-	nodeProperties = []string{"config.extraConfig", "config.flags", "config.version", "runtime.host"}
+	nodeProperties = []string{"config.extraConfig", "config.flags", "config.version", "config.hardware.device", "runtime.host"}
 )
 
 type nodeChannelWorkData struct {
@@ -44,10 +46,17 @@ type NodeChecker struct {
 	results     []ClusterCheckResult
 	resultLock  sync.RWMutex
 	workChannel chan nodeChannelWorkData
+
+	nodeStatuses     map[string]NodeStatus
+	nodeStatusesLock sync.Mutex
 }
 
 var _ CheckInterface = &NodeChecker{}
 
+func (n *NodeChecker) Name() string {
+	return "nodes"
+}
+
 func (n *NodeChecker) createPool(workerCount int) {
 	n.workChannel = make(chan nodeChannelWorkData, workerCount)
 
@@ -85,6 +94,7 @@ func (n *NodeChecker) runWorker(workIndex int) {
 			break
 		}
 		response := n.checkOnNode(workData)
+		n.setNodeResult(workData.node.Name, response)
 
 		// if there was an error performing node check we can add result to worker results
 		// this will allow checks to fail quickly.
@@ -102,6 +112,28 @@ func (n *NodeChecker) addResult(res ClusterCheckResult) {
 	n.results = append(n.results, res)
 }
 
+func (n *NodeChecker) setNodeStatus(nodeName string, status NodeStatus) {
+	n.nodeStatusesLock.Lock()
+	defer n.nodeStatusesLock.Unlock()
+	n.nodeStatuses[nodeName] = status
+}
+
+func (n *NodeChecker) setNodeResult(nodeName string, result ClusterCheckResult) {
+	n.nodeStatusesLock.Lock()
+	defer n.nodeStatusesLock.Unlock()
+	status := n.nodeStatuses[nodeName]
+	status.Result = result
+	n.nodeStatuses[nodeName] = status
+}
+
+func (n *NodeChecker) setNodeHostWarning(nodeName, warning string) {
+	n.nodeStatusesLock.Lock()
+	defer n.nodeStatusesLock.Unlock()
+	status := n.nodeStatuses[nodeName]
+	status.HostWarning = warning
+	n.nodeStatuses[nodeName] = status
+}
+
 func (n *NodeChecker) getResultCount() int {
 	n.resultLock.RLock()
 	defer n.resultLock.RUnlock()
@@ -115,10 +147,18 @@ func (n *NodeChecker) checkOnNode(workInfo nodeChannelWorkData) ClusterCheckResu
 	nodeCheckContext, cancel := context.WithTimeout(workInfo.ctx, nodeCheckTimeout)
 	defer cancel()
 
-	vm, err := getVM(nodeCheckContext, checkOpts, node)
+	found, err := getVM(nodeCheckContext, checkOpts, node)
+	if err == errNodeNotInitialized {
+		// The node will be checked again once the cloud controller manager sets its providerID
+		klog.V(2).Infof("Skipping node %s: %s", node.Name, nodeNotInitializedMessage)
+		n.setNodeStatus(node.Name, NodeStatus{NotInitialized: true})
+		return MakeClusterCheckResultPass()
+	}
 	if err != nil {
-		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, err)
+		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, err).WithObject(ObjectKindNode, node.Name)
 	}
+	vm, vCenter := found.vm, found.vCenter
+	klog.V(4).Infof("Found VM %s of node %s in vCenter %s by %s", vm.Name, node.Name, vCenter, found.lookup)
 
 	hwVersion := vm.Config.Version
 	vmHWVersion := strings.Trim(hwVersion, hardwareVersionPrefix)
@@ -129,10 +169,19 @@ func (n *NodeChecker) checkOnNode(workInfo nodeChannelWorkData) ClusterCheckResu
 	}
 
 	if versionInt < minHardwareVersion {
-		reason := fmt.Errorf("node %s has hardware version %s, which is below the minimum required version %d", node.Name, hwVersion, minHardwareVersion)
-		return makeDeprecatedEnvironmentError(CheckStatusDeprecatedHWVersion, reason)
+		// The CSI driver won't run on this node, but it can run on the others.
+		reason := fmt.Sprintf("hardware version %s is below the minimum required version %d", hwVersion, minHardwareVersion)
+		klog.V(2).Infof("Excluding node %s from the CSI driver: %s", node.Name, reason)
+		n.setNodeStatus(node.Name, NodeStatus{
+			VolumeLimit:      NodeVolumeLimit{HardwareVersion: versionInt},
+			IneligibleReason: reason,
+			VMLookup:         found.lookup,
+		})
+		return MakeClusterCheckResultPass()
 	}
 
+	n.setNodeStatus(node.Name, NodeStatus{VolumeLimit: getNodeVolumeLimit(vm, versionInt), VMLookup: found.lookup})
+
 	// check for esxi version
 	hostRef := vm.Runtime.Host
 	if hostRef == nil {
@@ -140,24 +189,44 @@ func (n *NodeChecker) checkOnNode(workInfo nodeChannelWorkData) ClusterCheckResu
 		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, err)
 	}
 
+	hostSystem, err := getHost(checkOpts, vCenter, hostRef)
+	if err != nil {
+		klog.Errorf("error getting host for node %s: %v", node.Name, err)
+		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, err)
+	}
+
+	// Host state is checked for each node, nodes on the same host may use different datastores
+	if warning := getHostWarning(checkOpts, vCenter, node, hostSystem); warning != "" {
+		klog.V(2).Infof("ESXi host of node %s has problems: %s", node.Name, warning)
+		n.setNodeHostWarning(node.Name, warning)
+	}
+	if state := hostSystem.Runtime.ConnectionState; state != "" && state != types.HostSystemConnectionStateConnected {
+		// vCenter does not know the version of a host it can't reach
+		return MakeClusterCheckResultPass()
+	}
+
 	hostName := hostRef.Value
 	if beingProcessed := n.checkOrMarkHostForProcessing(hostName); beingProcessed {
 		return MakeClusterCheckResultPass()
 	}
 
-	hostSystem, err := n.getHost(nodeCheckContext, checkOpts, hostRef)
-	if err != nil {
+	if hostSystem.Config == nil {
+		err := fmt.Errorf("error getting ESXi host version %s: host.config is nil", hostName)
 		klog.Errorf("error getting host for node %s: %v", node.Name, err)
 		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, err)
 	}
-	hostAPIVersion := hostSystem.Config.Product.ApiVersion
+	product := hostSystem.Config.Product
+	utils.ESXiVersionInfoMetric.WithLabelValues(vCenter, hostSystem.Name, product.Version, product.Build).Set(1)
+	hostAPIVersion := product.ApiVersion
 	hasRequiredMinimum, err := utils.IsMinimumVersion(minRequiredHostVersion, hostAPIVersion)
 	if err != nil {
 		klog.Errorf("error parsing host version for node %s and host %s: %v", node.Name, hostName, err)
 	}
 	if !hasRequiredMinimum {
 		reason := fmt.Errorf("host %s is on ESXI version %s, which is below minimum required version %s", hostName, hostAPIVersion, minRequiredHostVersion)
-		return makeDeprecatedEnvironmentError(CheckStatusDeprecatedESXIVersion, reason)
+		return makeDeprecatedEnvironmentError(CheckStatusDeprecatedESXIVersion, reason).
+			WithObject(ObjectKindHost, hostSystem.Name).
+			WithValues(hostAPIVersion, minRequiredHostVersion)
 	}
 
 	hasUpgradeableMinimum, err := utils.IsMinimumVersion(minUpgradeableHostVersion, hostAPIVersion)
@@ -166,22 +235,112 @@ func (n *NodeChecker) checkOnNode(workInfo nodeChannelWorkData) ClusterCheckResu
 	}
 	if !hasUpgradeableMinimum {
 		reason := fmt.Errorf("host %s is on ESXI version %s, which is below minimum required version %s for cluster upgrade", hostName, hostAPIVersion, minUpgradeableHostVersion)
-		return MakeClusterUnupgradeableError(CheckStatusDeprecatedESXIVersion, reason)
+		return MakeClusterUnupgradeableError(CheckStatusDeprecatedESXIVersion, reason).
+			WithObject(ObjectKindHost, hostSystem.Name).
+			WithValues(hostAPIVersion, minUpgradeableHostVersion)
 	}
 
 	return MakeClusterCheckResultPass()
 }
 
 func (n *NodeChecker) Check(ctx context.Context, checkOpts CheckArgs) []ClusterCheckResult {
-	nodes, err := checkOpts.apiClient.ListLinuxNodes()
+	nodes, err := checkOpts.apiClient.ListNodes()
 	if err != nil {
 		reason := fmt.Errorf("error listing node objects: %v", err)
 		return []ClusterCheckResult{MakeClusterDegradedError(CheckStatusOpenshiftAPIError, reason)}
 	}
 
+	var availableNodes []*v1.Node
+	for _, node := range nodes {
+		if checkOpts.isNodeAvailable(node) {
+			availableNodes = append(availableNodes, node)
+		}
+	}
+	if skipped := len(nodes) - len(availableNodes); skipped > 0 {
+		klog.V(2).Infof("Skipping %d node(s) in zones of unreachable vCenters", skipped)
+	}
+
+	utils.ESXiVersionInfoMetric.Reset()
+	results := n.checkNodes(ctx, checkOpts, availableNodes)
+	utils.CheckEvaluatedObjectsMetric.WithLabelValues(n.Name(), ObjectKindNode).Set(float64(len(n.nodeStatuses)))
+	utils.CheckEvaluatedObjectsMetric.WithLabelValues(n.Name(), ObjectKindHost).Set(float64(len(n.hostESXIVersions)))
+	if n.getResultCount() > 0 {
+		// Not all nodes were checked, keep statuses of the other nodes from the previous check
+		if checkOpts.nodeStatuses != nil {
+			checkOpts.nodeStatuses.merge(n.nodeStatuses)
+		}
+		return results
+	}
+	if len(availableNodes) < len(nodes) && checkOpts.nodeStatuses != nil {
+		// Keep statuses of the skipped nodes from the previous check
+		checkOpts.nodeStatuses.merge(n.nodeStatuses)
+		if result := makeIneligibleNodesResult(checkOpts.nodeStatuses.Get()); result.CheckError != nil {
+			return []ClusterCheckResult{result}
+		}
+		return results
+	}
+
+	// Replace node statuses only when all nodes were checked
+	if checkOpts.nodeStatuses != nil {
+		checkOpts.nodeStatuses.update(n.nodeStatuses)
+	}
+	if result := makeIneligibleNodesResult(n.nodeStatuses); result.CheckError != nil {
+		return []ClusterCheckResult{result}
+	}
+	return results
+}
+
+// CheckNodes checks only the given nodes, e.g. nodes that were just added to the cluster. Their results are merged
+// with cached results of the other nodes and the merged results are returned, so the whole cluster does not need
+// to be checked again. When a node check fails, remaining nodes are not checked; the caller is expected to run
+// the full Check soon.
+func (n *NodeChecker) CheckNodes(ctx context.Context, checkOpts CheckArgs, nodeNames []string) []ClusterCheckResult {
+	allNodes, err := checkOpts.apiClient.ListNodes()
+	if err != nil {
+		reason := fmt.Errorf("error listing node objects: %v", err)
+		return []ClusterCheckResult{MakeClusterDegradedError(CheckStatusOpenshiftAPIError, reason)}
+	}
+
+	wanted := sets.New[string](nodeNames...)
+	var nodes []*v1.Node
+	for _, node := range allNodes {
+		if !wanted.Has(node.Name) {
+			continue
+		}
+		// The next full check checks nodes in zones of unreachable vCenters once they can be reached
+		if !checkOpts.isNodeAvailable(node) {
+			klog.V(2).Infof("Skipping node %s in a zone of an unreachable vCenter", node.Name)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+
+	results := n.checkNodes(ctx, checkOpts, nodes)
+	if checkOpts.nodeStatuses == nil {
+		if result := makeIneligibleNodesResult(n.nodeStatuses); result.CheckError != nil {
+			results = append(results, result)
+		}
+		return results
+	}
+	checkOpts.nodeStatuses.merge(n.nodeStatuses)
+	return checkOpts.nodeStatuses.Results()
+}
+
+// checkNodes checks the given nodes in parallel. It stops at the first failed node.
+func (n *NodeChecker) checkNodes(ctx context.Context, checkOpts CheckArgs, nodes []*v1.Node) []ClusterCheckResult {
 	// Map of host names and their ESXI versions
 	n.hostESXIVersions = map[string]bool{}
 	n.results = []ClusterCheckResult{}
+	n.nodeStatuses = map[string]NodeStatus{}
+
+	// Load all VMs and hosts at once instead of looking them up node by node
+	syncContext, cancel := context.WithTimeout(ctx, nodeCheckTimeout)
+	defer cancel()
+	if err := checkOpts.inventory.Sync(syncContext, checkOpts.vmConnection); err != nil {
+		klog.Errorf("error loading vCenter inventory: %v", err)
+		n.addResult(makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, err))
+		return n.results
+	}
 	workDone := false
 	n.createPool(workerCount)
 
@@ -206,6 +365,33 @@ func (n *NodeChecker) Check(ctx context.Context, checkOpts CheckArgs) []ClusterC
 	return n.waitForWorkFinish()
 }
 
+// makeIneligibleNodesResult lists nodes excluded from the CSI driver. Such nodes only block upgrades, the driver
+// runs on all other nodes. When no node meets the requirements, the driver install is blocked too, since the
+// driver could not run anywhere.
+func makeIneligibleNodesResult(statuses map[string]NodeStatus) ClusterCheckResult {
+	var nodes []string
+	for nodeName, status := range statuses {
+		if status.IneligibleReason != "" {
+			nodes = append(nodes, fmt.Sprintf("%s (%s)", nodeName, status.IneligibleReason))
+		}
+	}
+	if len(nodes) == 0 {
+		return MakeClusterCheckResultPass()
+	}
+	sort.Strings(nodes)
+	if len(nodes) == len(statuses) {
+		reason := fmt.Errorf("no node meets requirements of the CSI driver: %s", strings.Join(nodes, ", "))
+		return ClusterCheckResult{
+			CheckStatus: CheckStatusDeprecatedHWVersion,
+			CheckError:  reason,
+			Action:      CheckActionBlockUpgradeDriverInstall,
+			Reason:      reason.Error(),
+		}
+	}
+	reason := fmt.Errorf("the CSI driver does not run on %d node(s) that do not meet its requirements: %s", len(nodes), strings.Join(nodes, ", "))
+	return MakeClusterUnupgradeableError(CheckStatusDeprecatedHWVersion, reason)
+}
+
 func (n *NodeChecker) checkOrMarkHostForProcessing(hostName string) bool {
 	n.esxiVersionLock.Lock()
 	defer n.esxiVersionLock.Unlock()
@@ -218,73 +404,58 @@ func (n *NodeChecker) checkOrMarkHostForProcessing(hostName string) bool {
 	return false
 }
 
-func (n *NodeChecker) getHost(ctx context.Context, checkOpts CheckArgs, hostRef *types.ManagedObjectReference) (mo.HostSystem, error) {
-	var o mo.HostSystem
-	var err error
-	hostName := hostRef.Value
-
-	// Since we need to iterate through connections again, lets not throw error prematurely.
-	for _, vConn := range checkOpts.vmConnection {
-		hostSystemObject := object.NewHostSystem(vConn.Client.Client, *hostRef)
-
-		err = hostSystemObject.Properties(ctx, hostSystemObject.Reference(), []string{"name", "config.product"}, &o)
-		if err == nil {
-			// Object found
-			break
-		}
+// getHostWarning returns problems of the ESXi host of a node that cause volume attach failures: the host is not
+// connected to vCenter, it's in maintenance mode or it does not mount the datastore of the node failure domain.
+func getHostWarning(checkOpts CheckArgs, vCenter string, node *v1.Node, host *mo.HostSystem) string {
+	if state := host.Runtime.ConnectionState; state != "" && state != types.HostSystemConnectionStateConnected {
+		// vCenter does not know the other properties of a host it can't reach
+		return fmt.Sprintf("host %s is %s", host.Name, state)
 	}
-	if err != nil {
-		return o, fmt.Errorf("failed to load ESXi host %s: %v", hostName, err)
+	var problems []string
+	if host.Runtime.InMaintenanceMode {
+		problems = append(problems, fmt.Sprintf("host %s is in maintenance mode", host.Name))
+	}
+	mounted := sets.New[string]()
+	for _, ref := range host.Datastore {
+		mounted.Insert(checkOpts.inventory.GetDatastoreName(vCenter, ref))
 	}
-	if o.Config == nil {
-		return o, fmt.Errorf("error getting ESXi host version %s: host.config is nil", hostName)
+	for _, datastore := range getNodeDatastores(checkOpts, vCenter, node) {
+		if !mounted.Has(datastore) {
+			problems = append(problems, fmt.Sprintf("host %s does not mount datastore %s", host.Name, datastore))
+		}
 	}
-	return o, nil
+	return strings.Join(problems, ", ")
 }
 
-func getVM(ctx context.Context, checkOpts CheckArgs, node *v1.Node) (*mo.VirtualMachine, error) {
-	klog.V(5).Infof("getVM: node.Name: %v", node.Name)
-	vmUUID := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(node.Spec.ProviderID, "vsphere://")))
-	for _, client := range checkOpts.vmConnection {
-		vmClient := client.Client.Client
-		vmConfig := client.Config
-
-		// When checking VMs, the VMs may be spread across vCenters.  So we'll need to get vCenters from config and
-		// check each for the vm.
-		dataCenterNames, err := vmConfig.GetDatacenters(client.Hostname)
-		if err != nil {
-			return nil, err
+// getNodeDatastores returns names of datastores of the failure domains the node is in. A node without a zone is in
+// the failure domain of its vCenter, when there is only one.
+func getNodeDatastores(checkOpts CheckArgs, vCenter string, node *v1.Node) []string {
+	infra := checkOpts.apiClient.GetInfrastructure()
+	if infra == nil || infra.Spec.PlatformSpec.VSphere == nil {
+		return nil
+	}
+	var domains []ocpv1.VSpherePlatformFailureDomainSpec
+	for _, fd := range infra.Spec.PlatformSpec.VSphere.FailureDomains {
+		if fd.Server == vCenter && fd.Topology.Datastore != "" {
+			domains = append(domains, fd)
 		}
-
-		for _, dcName := range dataCenterNames {
-			// Find datastore
-			finder := find.NewFinder(vmClient, false)
-			dc, err := finder.Datacenter(ctx, dcName)
-			if err != nil {
-				return nil, fmt.Errorf("failed to access Datacenter %s: %s", dcName, err)
-			}
-
-			// Find VM reference in the datastore, by UUID
-			s := object.NewSearchIndex(dc.Client())
-
-			svm, err := s.FindByUuid(ctx, dc, vmUUID, true, nil)
-			if err != nil {
-				return nil, fmt.Errorf("failed to find VM %s by UUID %s: %s", node.Name, vmUUID, err)
-			}
-			if svm == nil {
-				continue
-			}
-
-			// Find VM properties
-			vm := object.NewVirtualMachine(vmClient, svm.Reference())
-
-			var o mo.VirtualMachine
-			err = vm.Properties(ctx, vm.Reference(), nodeProperties, &o)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load VM %s: %s", node.Name, err)
-			}
-			return &o, nil
+	}
+	zone, hasZone := node.Labels[v1.LabelTopologyZone]
+	var datastores []string
+	for _, fd := range domains {
+		if (hasZone && fd.Zone == zone) || (!hasZone && len(domains) == 1) {
+			datastores = append(datastores, path.Base(fd.Topology.Datastore))
 		}
 	}
-	return nil, fmt.Errorf("unable to find VM %s by UUID %s", node.Name, vmUUID)
+	return datastores
+}
+
+// getHost returns the ESXi host with its version, connection state, maintenance mode and datastores. The version
+// is not known when the host is not connected to vCenter.
+func getHost(checkOpts CheckArgs, vCenter string, hostRef *types.ManagedObjectReference) (*mo.HostSystem, error) {
+	host := checkOpts.inventory.GetHost(vCenter, *hostRef)
+	if host == nil {
+		return nil, fmt.Errorf("failed to load ESXi host %s: host not found in vCenter %s", hostRef.Value, vCenter)
+	}
+	return host, nil
 }
@@ -16,33 +16,50 @@ type VCenterChecker struct{}
 
 var _ CheckInterface = &VCenterChecker{}
 
+func (v *VCenterChecker) Name() string {
+	return "vcenter"
+}
+
 func (v *VCenterChecker) Check(ctx context.Context, checkOpts CheckArgs) []ClusterCheckResult {
+	utils.VCenterVersionInfoMetric.Reset()
+	utils.CheckEvaluatedObjectsMetric.WithLabelValues(v.Name(), ObjectKindVCenter).Set(float64(len(checkOpts.vmConnection)))
+	var results []ClusterCheckResult
 	for _, vConn := range checkOpts.vmConnection {
-		vmClient := vConn.Client
-		vcenterAPIVersion := vmClient.ServiceContent.About.ApiVersion
-
-		hasRequiredMinimum, err := utils.IsMinimumVersion(minRequiredVCenterVersion, vcenterAPIVersion)
-		// if we can't determine the version, we are going to mark cluster as upgrade
-		// disabled without degrading the cluster
-		if err != nil {
-			reason := fmt.Errorf("error parsing minimum version %v", err)
-			return []ClusterCheckResult{makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, reason)}
-		}
-		if !hasRequiredMinimum {
-			reason := fmt.Errorf("found older vcenter version %s, minimum required version is %s", vcenterAPIVersion, minRequiredVCenterVersion)
-			return []ClusterCheckResult{makeDeprecatedEnvironmentError(CheckStatusDeprecatedVCenter, reason)}
+		about := vConn.Client.ServiceContent.About
+		utils.VCenterVersionInfoMetric.WithLabelValues(vConn.Hostname, about.Version, about.Build).Set(1)
+		// Check all vCenters, so results show each vCenter that needs an update
+		if result := checkVCenterVersion(vConn.Hostname, about.ApiVersion); result.CheckError != nil {
+			results = append(results, result)
 		}
+	}
+	return results
+}
 
-		hasUpgradeableMinimum, err := utils.IsMinimumVersion(minUpgradeableVCenterVersion, vcenterAPIVersion)
-		if err != nil {
-			reason := fmt.Errorf("error parsing minimum version %v", err)
-			return []ClusterCheckResult{makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, reason)}
-		}
-		if !hasUpgradeableMinimum {
-			reason := fmt.Errorf("found older vcenter version %s, minimum required version for upgrade is %s", vcenterAPIVersion, minUpgradeableVCenterVersion)
-			return []ClusterCheckResult{MakeClusterUnupgradeableError(CheckStatusDeprecatedVCenter, reason)}
-		}
+func checkVCenterVersion(hostname, vcenterAPIVersion string) ClusterCheckResult {
+	hasRequiredMinimum, err := utils.IsMinimumVersion(minRequiredVCenterVersion, vcenterAPIVersion)
+	// if we can't determine the version, we are going to mark cluster as upgrade
+	// disabled without degrading the cluster
+	if err != nil {
+		reason := fmt.Errorf("error parsing minimum version %v", err)
+		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, reason)
+	}
+	if !hasRequiredMinimum {
+		reason := fmt.Errorf("found older vcenter version %s, minimum required version is %s", vcenterAPIVersion, minRequiredVCenterVersion)
+		return makeDeprecatedEnvironmentError(CheckStatusDeprecatedVCenter, reason).
+			WithObject(ObjectKindVCenter, hostname).
+			WithValues(vcenterAPIVersion, minRequiredVCenterVersion)
 	}
 
-	return []ClusterCheckResult{}
+	hasUpgradeableMinimum, err := utils.IsMinimumVersion(minUpgradeableVCenterVersion, vcenterAPIVersion)
+	if err != nil {
+		reason := fmt.Errorf("error parsing minimum version %v", err)
+		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, reason)
+	}
+	if !hasUpgradeableMinimum {
+		reason := fmt.Errorf("found older vcenter version %s, minimum required version for upgrade is %s", vcenterAPIVersion, minUpgradeableVCenterVersion)
+		return MakeClusterUnupgradeableError(CheckStatusDeprecatedVCenter, reason).
+			WithObject(ObjectKindVCenter, hostname).
+			WithValues(vcenterAPIVersion, minUpgradeableVCenterVersion)
+	}
+	return MakeClusterCheckResultPass()
 }
@@ -0,0 +1,116 @@
+package checks
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultClockSkewThreshold       = 5 * time.Minute
+	defaultCertificateExpiryHorizon = 30 * 24 * time.Hour
+	// Certificates are reported as a warning when they expire within certificateExpiryWarningFactor times the horizon.
+	certificateExpiryWarningFactor = 2
+	vCenterTimeCheckTimeout        = time.Minute
+)
+
+// VCenterTimeChecker checks that clocks of vCenter and of the cluster are in sync and that the TLS certificate of
+// vCenter does not expire soon.
+type VCenterTimeChecker struct{}
+
+var _ CheckInterface = &VCenterTimeChecker{}
+
+func (v *VCenterTimeChecker) Name() string {
+	return "vcenter_time"
+}
+
+func (v *VCenterTimeChecker) Check(ctx context.Context, checkOpts CheckArgs) []ClusterCheckResult {
+	utils.VCenterCertificateExpiryMetric.Reset()
+	utils.CheckEvaluatedObjectsMetric.WithLabelValues(v.Name(), ObjectKindVCenter).Set(float64(len(checkOpts.vmConnection)))
+	operatorConfig := checkOpts.apiClient.GetOperatorConfig()
+	skewThreshold := operatorConfig.GetClockSkewThreshold(defaultClockSkewThreshold)
+	expiryHorizon := operatorConfig.GetCertificateExpiryHorizon(defaultCertificateExpiryHorizon)
+
+	var results []ClusterCheckResult
+	warnings := map[string]string{}
+	defer func() {
+		checkOpts.vCenterWarnings.set(warnings)
+	}()
+	for _, vConn := range checkOpts.vmConnection {
+		if result := checkClockSkew(ctx, vConn.Hostname, vConn.VimClient(), skewThreshold); result.CheckError != nil {
+			results = append(results, result)
+		}
+		certificate := vConn.ServerCertificate()
+		if certificate == nil {
+			klog.V(4).Infof("No TLS certificate of vCenter %s is known, skipping its expiry check", vConn.Hostname)
+			continue
+		}
+		result, warning := checkCertificateExpiry(vConn.Hostname, certificate, time.Now(), expiryHorizon)
+		if result.CheckError != nil {
+			results = append(results, result)
+		}
+		if warning != "" {
+			warnings[vConn.Hostname] = warning
+		}
+	}
+	return results
+}
+
+// checkClockSkew compares the current time of vCenter with the local time in the middle of the call, so the skew
+// does not include the network latency.
+func checkClockSkew(ctx context.Context, hostname string, client soap.RoundTripper, threshold time.Duration) ClusterCheckResult {
+	tctx, cancel := context.WithTimeout(ctx, vCenterTimeCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	vCenterTime, err := methods.GetCurrentTime(tctx, client)
+	if err != nil {
+		reason := fmt.Errorf("error getting current time of vCenter %s: %v", hostname, err)
+		klog.Error(reason)
+		return makeDeprecatedEnvironmentError(CheckStatusVcenterAPIError, reason).WithObject(ObjectKindVCenter, hostname)
+	}
+	end := time.Now()
+	localTime := start.Add(end.Sub(start) / 2)
+
+	skew := vCenterTime.Sub(localTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	klog.V(4).Infof("Clock of vCenter %s differs by %s", hostname, skew)
+	if skew > threshold {
+		reason := fmt.Errorf("clock of vCenter %s differs from the cluster clock by %s, more than %s", hostname, skew.Round(time.Second), threshold)
+		return MakeClusterUnupgradeableError(CheckStatusVCenterClockSkew, reason).
+			WithObject(ObjectKindVCenter, hostname).
+			WithValues(skew.Round(time.Second).String(), threshold.String())
+	}
+	return MakeClusterCheckResultPass()
+}
+
+// checkCertificateExpiry blocks upgrades when the certificate expires within the horizon. It only returns a warning
+// when the certificate expires later, but still soon.
+func checkCertificateExpiry(hostname string, certificate *x509.Certificate, now time.Time, horizon time.Duration) (ClusterCheckResult, string) {
+	remaining := certificate.NotAfter.Sub(now)
+	utils.VCenterCertificateExpiryMetric.WithLabelValues(hostname).Set(remaining.Hours() / 24)
+	expiry := certificate.NotAfter.UTC().Format(time.RFC3339)
+
+	if remaining < horizon {
+		var reason error
+		if remaining <= 0 {
+			reason = fmt.Errorf("TLS certificate of vCenter %s expired at %s", hostname, expiry)
+		} else {
+			reason = fmt.Errorf("TLS certificate of vCenter %s expires at %s, in less than %s", hostname, expiry, horizon)
+		}
+		return MakeClusterUnupgradeableError(CheckStatusVCenterCertificate, reason).
+			WithObject(ObjectKindVCenter, hostname).
+			WithValues(expiry, "after "+now.Add(horizon).UTC().Format(time.RFC3339)), ""
+	}
+	if remaining < certificateExpiryWarningFactor*horizon {
+		return MakeClusterCheckResultPass(), fmt.Sprintf("TLS certificate expires at %s, upgrades will be blocked %s before it expires", expiry, horizon)
+	}
+	return MakeClusterCheckResultPass(), ""
+}
@@ -2,22 +2,67 @@ package checks
 
 import (
 	"context"
+
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
 	check "github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type CheckArgs struct {
 	vmConnection []*check.VSphereConnection
 	apiClient    KubeAPIInterface
+	// nodeStatuses receives results of NodeChecker for individual nodes, it's optional
+	nodeStatuses *NodeStatuses
+	// inventory caches vCenter objects used by the checks
+	inventory *Inventory
+	// unavailableZones are zones of vCenters that can't be reached, nodes in them are not checked
+	unavailableZones sets.Set[string]
+	// vCenterWarnings receives problems of vCenters that do not block upgrades yet, it's optional
+	vCenterWarnings *VCenterWarnings
 }
 
 func NewCheckArgs(connection []*check.VSphereConnection, apiClient KubeAPIInterface, gates featuregates.FeatureGate) CheckArgs {
 	return CheckArgs{
 		vmConnection: connection,
 		apiClient:    apiClient,
+		inventory:    NewInventory(),
 	}
 }
 
+// WithNodeStatuses returns CheckArgs that make NodeChecker store results for individual nodes to statuses.
+func (c CheckArgs) WithNodeStatuses(statuses *NodeStatuses) CheckArgs {
+	c.nodeStatuses = statuses
+	return c
+}
+
+// WithVCenterWarnings returns CheckArgs that make VCenterTimeChecker store warnings about vCenters to warnings.
+func (c CheckArgs) WithVCenterWarnings(warnings *VCenterWarnings) CheckArgs {
+	c.vCenterWarnings = warnings
+	return c
+}
+
+// WithInventory returns CheckArgs that use the given inventory cache, so it can be shared by subsequent checks.
+func (c CheckArgs) WithInventory(inventory *Inventory) CheckArgs {
+	c.inventory = inventory
+	return c
+}
+
+// WithUnavailableZones returns CheckArgs that make NodeChecker skip nodes in the given zones, because their vCenter
+// can't be reached. Results of the previous check are kept for these nodes.
+func (c CheckArgs) WithUnavailableZones(zones []string) CheckArgs {
+	c.unavailableZones = sets.New[string](zones...)
+	return c
+}
+
+// isNodeAvailable returns false when the node is in a zone whose vCenter can't be reached.
+func (c CheckArgs) isNodeAvailable(node *v1.Node) bool {
+	zone, found := node.Labels[v1.LabelTopologyZone]
+	return !found || !c.unavailableZones.Has(zone)
+}
+
 type CheckInterface interface {
+	// Name identifies the check in metrics.
+	Name() string
 	Check(ctx context.Context, args CheckArgs) []ClusterCheckResult
 }
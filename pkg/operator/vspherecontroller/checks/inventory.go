@@ -0,0 +1,391 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vim25/xml"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	virtualMachineType = "VirtualMachine"
+	hostSystemType     = "HostSystem"
+	datastoreType      = "Datastore"
+)
+
+var (
+	inventoryVMProperties   = append([]string{"name", "config.uuid"}, nodeProperties...)
+	inventoryHostProperties = []string{"name", "config.product", "runtime.connectionState", "runtime.inMaintenanceMode", "datastore"}
+	// names of datastores mounted by the hosts
+	inventoryDatastoreProperties = []string{"name"}
+)
+
+// Inventory caches VirtualMachine, HostSystem and Datastore properties of all vCenters, so checks don't need to look up
+// the objects one by one. It's filled by a property collector filter over container views of the configured
+// datacenters and updated incrementally with WaitForUpdatesEx. The filter belongs to the vCenter session,
+// the inventory of a vCenter is loaded again when a new session is used. Objects returned by the inventory are
+// deep copies, they don't change when the inventory is synced again.
+type Inventory struct {
+	lock     sync.RWMutex
+	vCenters map[string]*vCenterInventory
+}
+
+type vCenterInventory struct {
+	// client of the session that owns collector and views
+	client    *vim25.Client
+	collector *property.Collector
+	views     []*view.ContainerView
+	// version of the last update received from collector
+	version string
+
+	vms        map[types.ManagedObjectReference]*mo.VirtualMachine
+	hosts      map[types.ManagedObjectReference]*mo.HostSystem
+	datastores map[types.ManagedObjectReference]*mo.Datastore
+	// VM references indexed by lower case BIOS UUID, sorted by their values. More VMs have the same UUID when
+	// they were cloned without changing it.
+	vmsByUUID map[string][]types.ManagedObjectReference
+}
+
+func NewInventory() *Inventory {
+	return &Inventory{
+		vCenters: map[string]*vCenterInventory{},
+	}
+}
+
+// Sync updates inventory of the given vCenters with changes since the last Sync. vCenters are synced in parallel,
+// each with its own timeout.
+func (i *Inventory) Sync(ctx context.Context, connections []*vclib.VSphereConnection) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, conn := range connections {
+		if conn.Client == nil {
+			return fmt.Errorf("no connection found to vcenter %s", conn.Hostname)
+		}
+	}
+	inventories := make([]*vCenterInventory, len(connections))
+	// inventories that failed to update, they're loaded again by the next Sync
+	failed := make([]*vCenterInventory, len(connections))
+	errs := vclib.ForEachConnection(ctx, connections, nodeCheckTimeout, func(ctx context.Context, idx int, conn *vclib.VSphereConnection) error {
+		inv := i.vCenters[conn.Hostname]
+		if inv == nil || inv.client != conn.Client.Client {
+			var err error
+			klog.V(4).Infof("Loading inventory of vCenter %s", conn.Hostname)
+			inv, err = newVCenterInventory(ctx, conn)
+			if err != nil {
+				return fmt.Errorf("error loading inventory of vCenter %s: %v", conn.Hostname, err)
+			}
+		}
+		if err := inv.update(ctx); err != nil {
+			failed[idx] = inv
+			return fmt.Errorf("error updating inventory of vCenter %s: %v", conn.Hostname, err)
+		}
+		inventories[idx] = inv
+		return nil
+	})
+
+	// Keep only inventories that were synced. Destroy the others, including inventories of old sessions and of
+	// vCenters that were removed from the configuration, so their collectors and views don't pile up in vCenter.
+	vCenters := make(map[string]*vCenterInventory, len(connections))
+	discarded := map[*vCenterInventory]bool{}
+	for _, inv := range i.vCenters {
+		discarded[inv] = true
+	}
+	for idx, conn := range connections {
+		if inv := inventories[idx]; inv != nil {
+			vCenters[conn.Hostname] = inv
+			delete(discarded, inv)
+		}
+		if inv := failed[idx]; inv != nil {
+			discarded[inv] = true
+		}
+	}
+	for inv := range discarded {
+		inv.destroy(ctx)
+	}
+	i.vCenters = vCenters
+	return utilerrors.NewAggregate(errs)
+}
+
+// GetVM returns VM with the given BIOS UUID and the vCenter it was found in. It returns nil when there is no
+// such VM and an error when more VMs have the UUID, e.g. VMs cloned from the same template, because it's not
+// possible to tell which of them runs the node.
+func (i *Inventory) GetVM(uuid string) (*mo.VirtualMachine, string, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	uuid = strings.ToLower(uuid)
+
+	vCenters := make([]string, 0, len(i.vCenters))
+	for vCenter := range i.vCenters {
+		vCenters = append(vCenters, vCenter)
+	}
+	sort.Strings(vCenters)
+
+	var foundVCenter string
+	var found *mo.VirtualMachine
+	var duplicates []string
+	for _, vCenter := range vCenters {
+		inv := i.vCenters[vCenter]
+		for _, ref := range inv.vmsByUUID[uuid] {
+			vm := inv.vms[ref]
+			duplicates = append(duplicates, fmt.Sprintf("%s (%s) in vCenter %s", vm.Name, ref.Value, vCenter))
+			if found == nil {
+				found, foundVCenter = vm, vCenter
+			}
+		}
+	}
+	if found == nil {
+		return nil, "", nil
+	}
+	if len(duplicates) > 1 {
+		return nil, "", fmt.Errorf("BIOS UUID %s is used by more VMs: %s", uuid, strings.Join(duplicates, ", "))
+	}
+
+	vm := &mo.VirtualMachine{}
+	if err := deepCopy(found, vm); err != nil {
+		return nil, "", fmt.Errorf("error copying VM %s of vCenter %s: %v", found.Self.Value, foundVCenter, err)
+	}
+	return vm, foundVCenter, nil
+}
+
+// GetVMByReference returns VM with the given reference from the given vCenter. It returns nil when there is no
+// such VM.
+func (i *Inventory) GetVMByReference(vCenter string, ref types.ManagedObjectReference) *mo.VirtualMachine {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	inv := i.vCenters[vCenter]
+	if inv == nil || inv.vms[ref] == nil {
+		return nil
+	}
+	vm := &mo.VirtualMachine{}
+	if err := deepCopy(inv.vms[ref], vm); err != nil {
+		klog.Errorf("error copying VM %s of vCenter %s: %v", ref.Value, vCenter, err)
+		return nil
+	}
+	return vm
+}
+
+// GetHost returns ESXi host with the given reference from the given vCenter. It returns nil when there is no
+// such host.
+func (i *Inventory) GetHost(vCenter string, ref types.ManagedObjectReference) *mo.HostSystem {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	inv := i.vCenters[vCenter]
+	if inv == nil || inv.hosts[ref] == nil {
+		return nil
+	}
+	host := &mo.HostSystem{}
+	if err := deepCopy(inv.hosts[ref], host); err != nil {
+		klog.Errorf("error copying host %s of vCenter %s: %v", ref.Value, vCenter, err)
+		return nil
+	}
+	return host
+}
+
+// deepCopy copies src to dst with XML encoding, which keeps the concrete types of vSphere data objects stored
+// in interfaces, like VM devices.
+func deepCopy(src, dst interface{}) error {
+	data, err := xml.Marshal(src)
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.TypeFunc = types.TypeFunc()
+	return decoder.Decode(dst)
+}
+
+// GetDatastoreName returns name of the datastore with the given reference from the given vCenter. It returns an
+// empty string when there is no such datastore.
+func (i *Inventory) GetDatastoreName(vCenter string, ref types.ManagedObjectReference) string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	inv := i.vCenters[vCenter]
+	if inv == nil || inv.datastores[ref] == nil {
+		return ""
+	}
+	return inv.datastores[ref].Name
+}
+
+func newVCenterInventory(ctx context.Context, conn *vclib.VSphereConnection) (*vCenterInventory, error) {
+	client := conn.Client.Client
+	dataCenterNames, err := conn.Config.GetDatacenters(conn.Hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	// A separate collector, so the filter does not affect other users of the session
+	collector, err := property.DefaultCollector(client).Create(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inv := &vCenterInventory{
+		client:     client,
+		collector:  collector,
+		vms:        map[types.ManagedObjectReference]*mo.VirtualMachine{},
+		hosts:      map[types.ManagedObjectReference]*mo.HostSystem{},
+		datastores: map[types.ManagedObjectReference]*mo.Datastore{},
+		vmsByUUID:  map[string][]types.ManagedObjectReference{},
+	}
+
+	finder := find.NewFinder(client, false)
+	viewManager := view.NewManager(client)
+	var objectSpecs []types.ObjectSpec
+	for _, dcName := range dataCenterNames {
+		dc, err := finder.Datacenter(ctx, dcName)
+		if err != nil {
+			inv.destroy(ctx)
+			return nil, fmt.Errorf("failed to access Datacenter %s: %s", dcName, err)
+		}
+		containerView, err := viewManager.CreateContainerView(ctx, dc.Reference(), []string{virtualMachineType, hostSystemType, datastoreType}, true)
+		if err != nil {
+			inv.destroy(ctx)
+			return nil, fmt.Errorf("failed to create view of Datacenter %s: %s", dcName, err)
+		}
+		inv.views = append(inv.views, containerView)
+		objectSpecs = append(objectSpecs, types.ObjectSpec{
+			Obj:  containerView.Reference(),
+			Skip: types.NewBool(true),
+			SelectSet: []types.BaseSelectionSpec{
+				&types.TraversalSpec{
+					Type: containerView.Reference().Type,
+					Path: "view",
+				},
+			},
+		})
+	}
+
+	_, err = collector.CreateFilter(ctx, types.CreateFilter{
+		Spec: types.PropertyFilterSpec{
+			ObjectSet: objectSpecs,
+			PropSet: []types.PropertySpec{
+				{Type: virtualMachineType, PathSet: inventoryVMProperties},
+				{Type: hostSystemType, PathSet: inventoryHostProperties},
+				{Type: datastoreType, PathSet: inventoryDatastoreProperties},
+			},
+		},
+	})
+	if err != nil {
+		inv.destroy(ctx)
+		return nil, err
+	}
+	return inv, nil
+}
+
+// destroy removes the property collector and the container views of the inventory from vCenter. Errors are only
+// logged, the objects are removed by vCenter anyway when the session ends.
+func (v *vCenterInventory) destroy(ctx context.Context) {
+	if v.collector != nil {
+		if err := v.collector.Destroy(ctx); err != nil {
+			klog.V(2).Infof("error destroying inventory property collector: %v", err)
+		}
+		v.collector = nil
+	}
+	for _, containerView := range v.views {
+		if err := containerView.Destroy(ctx); err != nil {
+			klog.V(2).Infof("error destroying inventory container view: %v", err)
+		}
+	}
+	v.views = nil
+}
+
+// update applies all changes since the last update. The first update loads the whole inventory.
+func (v *vCenterInventory) update(ctx context.Context) error {
+	for {
+		req := types.WaitForUpdatesEx{
+			This:    v.collector.Reference(),
+			Version: v.version,
+			Options: &types.WaitOptions{
+				// Don't wait for new changes, return the pending ones
+				MaxWaitSeconds: types.NewInt32(0),
+			},
+		}
+		res, err := methods.WaitForUpdatesEx(ctx, v.client, &req)
+		if err != nil {
+			return err
+		}
+		set := res.Returnval
+		if set == nil {
+			// No more changes
+			break
+		}
+		v.version = set.Version
+		for _, filterSet := range set.FilterSet {
+			for _, update := range filterSet.ObjectSet {
+				v.applyUpdate(update)
+			}
+		}
+		if set.Truncated == nil || !*set.Truncated {
+			break
+		}
+	}
+
+	v.vmsByUUID = make(map[string][]types.ManagedObjectReference, len(v.vms))
+	for ref, vm := range v.vms {
+		if vm.Config != nil && vm.Config.Uuid != "" {
+			uuid := strings.ToLower(vm.Config.Uuid)
+			v.vmsByUUID[uuid] = append(v.vmsByUUID[uuid], ref)
+		}
+	}
+	for _, refs := range v.vmsByUUID {
+		sort.Slice(refs, func(i, j int) bool {
+			return refs[i].Value < refs[j].Value
+		})
+	}
+	return nil
+}
+
+func (v *vCenterInventory) applyUpdate(update types.ObjectUpdate) {
+	ref := update.Obj
+	switch ref.Type {
+	case virtualMachineType:
+		if update.Kind == types.ObjectUpdateKindLeave {
+			delete(v.vms, ref)
+			return
+		}
+		vm := v.vms[ref]
+		if vm == nil {
+			vm = &mo.VirtualMachine{}
+			vm.Self = ref
+			v.vms[ref] = vm
+		}
+		mo.ApplyPropertyChange(vm, update.ChangeSet)
+	case hostSystemType:
+		if update.Kind == types.ObjectUpdateKindLeave {
+			delete(v.hosts, ref)
+			return
+		}
+		host := v.hosts[ref]
+		if host == nil {
+			host = &mo.HostSystem{}
+			host.Self = ref
+			v.hosts[ref] = host
+		}
+		mo.ApplyPropertyChange(host, update.ChangeSet)
+	case datastoreType:
+		if update.Kind == types.ObjectUpdateKindLeave {
+			delete(v.datastores, ref)
+			return
+		}
+		datastore := v.datastores[ref]
+		if datastore == nil {
+			datastore = &mo.Datastore{}
+			datastore.Self = ref
+			v.datastores[ref] = datastore
+		}
+		mo.ApplyPropertyChange(datastore, update.ChangeSet)
+	}
+}
@@ -0,0 +1,259 @@
+package checks
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// DefaultMaxVolumesPerNode is the limit set in controller.yaml and node.yaml, it's used until node VMs are checked.
+	DefaultMaxVolumesPerNode = 59
+	// maxVolumesPerNode is the maximum supported by the CSI driver.
+	maxVolumesPerNode = 255
+
+	// PVSCSI controllers of VMs with older hardware versions support only 16 targets.
+	legacyPVSCSITargets = 16
+	// PVSCSI controllers of VMs with hardware version vmx-15 and newer support 64 targets.
+	pvscsiTargets             = 64
+	minPVSCSI64TargetsVersion = 15
+	// unit number 7 of each SCSI controller is reserved for the controller itself
+	reservedTargetsPerController = 1
+	// first class disks (volumes provisioned by the CSI driver) are stored in this directory
+	fcdDirectory = "/fcd/"
+)
+
+// NodeStatuses holds results of NodeChecker for individual nodes: their attachable volume limits computed from
+// the VM hardware version and paravirtual SCSI controllers and whether the CSI driver can run on them.
+type NodeStatuses struct {
+	lock     sync.RWMutex
+	statuses map[string]NodeStatus
+}
+
+// NodeStatus is the result of NodeChecker for a single node.
+type NodeStatus struct {
+	VolumeLimit NodeVolumeLimit
+	// IneligibleReason is not empty when the node does not meet requirements of the CSI driver and the driver
+	// must not run there.
+	IneligibleReason string
+	// Result is the result of the last check of the node.
+	Result ClusterCheckResult
+	// VMLookup is the way the node VM was found in vCenter.
+	VMLookup VMLookupMethod
+	// NotInitialized is true when the node has no providerID yet and its VM was not found in any other way.
+	// Such node is not checked until it's initialized.
+	NotInitialized bool
+	// HostWarning describes problems of the ESXi host that runs the node VM, like maintenance mode, that cause
+	// volume attach failures. They do not block upgrades.
+	HostWarning string
+}
+
+// NodeVolumeLimit is the attachable volume limit of a single node.
+type NodeVolumeLimit struct {
+	// Limit is the number of volumes the CSI driver can attach to the node
+	Limit int
+	// PVSCSIControllers is the number of paravirtual SCSI controllers of the node VM
+	PVSCSIControllers int
+	// HardwareVersion is the node VM hardware version
+	HardwareVersion int64
+}
+
+func NewNodeStatuses() *NodeStatuses {
+	return &NodeStatuses{}
+}
+
+// update replaces all node statuses. It's called only after all nodes were checked, so nodes removed from the
+// cluster are removed from the statuses too.
+func (s *NodeStatuses) update(statuses map[string]NodeStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.statuses = statuses
+}
+
+// merge updates statuses of the given nodes and keeps statuses of all other nodes.
+func (s *NodeStatuses) merge(statuses map[string]NodeStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.statuses == nil {
+		s.statuses = make(map[string]NodeStatus, len(statuses))
+	}
+	for node, status := range statuses {
+		s.statuses[node] = status
+	}
+}
+
+// Remove removes the status of a node that was deleted from the cluster. It's safe to call on nil statuses.
+func (s *NodeStatuses) Remove(nodeName string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.statuses, nodeName)
+}
+
+// Results returns cached results of all checked nodes, including nodes excluded from the CSI driver.
+func (s *NodeStatuses) Results() []ClusterCheckResult {
+	statuses := s.Get()
+	nodeNames := make([]string, 0, len(statuses))
+	for node := range statuses {
+		nodeNames = append(nodeNames, node)
+	}
+	sort.Strings(nodeNames)
+
+	var results []ClusterCheckResult
+	for _, node := range nodeNames {
+		if statuses[node].Result.CheckError != nil {
+			results = append(results, statuses[node].Result)
+		}
+	}
+	if result := makeIneligibleNodesResult(statuses); result.CheckError != nil {
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return []ClusterCheckResult{MakeClusterCheckResultPass()}
+	}
+	return results
+}
+
+// Get returns a copy of the node statuses. It returns nil when node VMs were not checked yet.
+func (s *NodeStatuses) Get() map[string]NodeStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.statuses == nil {
+		return nil
+	}
+	statuses := make(map[string]NodeStatus, len(s.statuses))
+	for node, status := range s.statuses {
+		statuses[node] = status
+	}
+	return statuses
+}
+
+// HostWarnings returns problems of ESXi hosts, indexed by names of the nodes that run on them.
+func (s *NodeStatuses) HostWarnings() map[string]string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	warnings := map[string]string{}
+	for node, status := range s.statuses {
+		if status.HostWarning != "" {
+			warnings[node] = status.HostWarning
+		}
+	}
+	return warnings
+}
+
+// IneligibleNodes returns sorted names of nodes the CSI driver must not run on.
+func (s *NodeStatuses) IneligibleNodes() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var nodes []string
+	for node, status := range s.statuses {
+		if status.IneligibleReason != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// NotInitializedNodes returns sorted names of nodes that were not checked, because they're not initialized yet.
+func (s *NodeStatuses) NotInitializedNodes() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var nodes []string
+	for node, status := range s.statuses {
+		if status.NotInitialized {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// ClusterVolumeLimit returns the attachable volume limit of the CSI driver. The driver is configured with a single
+// limit for all nodes, so it's the lowest limit of eligible nodes that can attach at least DefaultMaxVolumesPerNode
+// volumes. Nodes with a lower limit do not lower it for all other nodes, they're reported separately. It returns
+// false when the limit is not known.
+func (s *NodeStatuses) ClusterVolumeLimit() (int, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	found := false
+	clusterLimit := 0
+	for _, status := range s.statuses {
+		// Nodes without PVSCSI controllers can't attach any volume, they're reported separately.
+		if status.IneligibleReason != "" || status.VolumeLimit.Limit == 0 {
+			continue
+		}
+		found = true
+		if status.VolumeLimit.Limit < DefaultMaxVolumesPerNode {
+			continue
+		}
+		if clusterLimit == 0 || status.VolumeLimit.Limit < clusterLimit {
+			clusterLimit = status.VolumeLimit.Limit
+		}
+	}
+	if found && clusterLimit == 0 {
+		clusterLimit = DefaultMaxVolumesPerNode
+	}
+	return clusterLimit, found
+}
+
+// ExtendedVolumeLimit returns true when the CSI driver can attach more than DefaultMaxVolumesPerNode volumes to
+// all nodes that are not limited by their controllers, which needs the max-pvscsi-targets-per-vm feature of the driver. It's safe to call on nil statuses.
+func (s *NodeStatuses) ExtendedVolumeLimit() bool {
+	if s == nil {
+		return false
+	}
+	limit, found := s.ClusterVolumeLimit()
+	return found && limit > DefaultMaxVolumesPerNode
+}
+
+// getNodeVolumeLimit computes how many volumes can be attached to a node VM. Each PVSCSI controller provides
+// its targets without the reserved one and disks that are not first class disks (e.g. the boot disk) occupy
+// a target too.
+func getNodeVolumeLimit(vm *mo.VirtualMachine, hwVersion int64) NodeVolumeLimit {
+	result := NodeVolumeLimit{HardwareVersion: hwVersion}
+	if vm.Config == nil {
+		return result
+	}
+
+	targets := legacyPVSCSITargets
+	if hwVersion >= minPVSCSI64TargetsVersion {
+		targets = pvscsiTargets
+	}
+
+	controllers := map[int32]bool{}
+	for _, device := range vm.Config.Hardware.Device {
+		if controller, ok := device.(*types.ParaVirtualSCSIController); ok {
+			controllers[controller.Key] = true
+		}
+	}
+	result.PVSCSIControllers = len(controllers)
+
+	limit := len(controllers) * (targets - reservedTargetsPerController)
+	for _, device := range vm.Config.Hardware.Device {
+		disk, ok := device.(*types.VirtualDisk)
+		if !ok || !controllers[disk.ControllerKey] {
+			continue
+		}
+		if backing, ok := disk.Backing.(types.BaseVirtualDeviceFileBackingInfo); ok {
+			if strings.Contains(backing.GetVirtualDeviceFileBackingInfo().FileName, fcdDirectory) {
+				continue
+			}
+		}
+		limit--
+	}
+
+	if limit < 0 {
+		limit = 0
+	}
+	if limit > maxVolumesPerNode {
+		limit = maxVolumesPerNode
+	}
+	result.Limit = limit
+	return result
+}
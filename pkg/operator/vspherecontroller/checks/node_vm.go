@@ -0,0 +1,184 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25/mo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const providerIDPrefix = "vsphere://"
+
+// VMLookupMethod is the way a node VM was found in vCenter.
+type VMLookupMethod string
+
+const (
+	// VMLookupProviderID means the VM was found by BIOS UUID from the node providerID.
+	VMLookupProviderID VMLookupMethod = "providerID"
+	// VMLookupSystemUUID means the VM was found by BIOS UUID converted from the node system UUID.
+	VMLookupSystemUUID VMLookupMethod = "systemUUID"
+	// VMLookupName means the VM was found by the node name in the VM folder.
+	VMLookupName VMLookupMethod = "name"
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// errNodeNotInitialized is returned when a node has no providerID and its VM can't be found in any other way.
+// Such node is most likely still being initialized by the cloud controller manager.
+var errNodeNotInitialized = errors.New(nodeNotInitializedMessage)
+
+const nodeNotInitializedMessage = "the node is not initialized yet, it has no providerID and its VM was not found by system UUID or name"
+
+// nodeVM is a node VM found in vCenter.
+type nodeVM struct {
+	vm      *mo.VirtualMachine
+	vCenter string
+	lookup  VMLookupMethod
+}
+
+// getVM finds VM of the node. It tries BIOS UUID from the node providerID, then the node system UUID converted
+// to BIOS UUID and then the node name in the VM folders of all vCenters.
+func getVM(ctx context.Context, checkOpts CheckArgs, node *v1.Node) (*nodeVM, error) {
+	klog.V(5).Infof("getVM: node.Name: %v", node.Name)
+
+	providerUUID, providerIDErr := parseProviderID(node.Spec.ProviderID)
+	if providerIDErr == nil {
+		vm, vCenter, err := checkOpts.inventory.GetVM(providerUUID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find VM %s: %v", node.Name, err)
+		}
+		if vm != nil {
+			return &nodeVM{vm: vm, vCenter: vCenter, lookup: VMLookupProviderID}, nil
+		}
+	}
+
+	if systemUUID := strings.ToLower(node.Status.NodeInfo.SystemUUID); systemUUID != "" {
+		// Guests report the first three fields in little-endian byte order, older hardware versions don't
+		for _, uuid := range []string{biosUUIDFromSystemUUID(systemUUID), systemUUID} {
+			vm, vCenter, err := checkOpts.inventory.GetVM(uuid)
+			if err != nil {
+				return nil, fmt.Errorf("unable to find VM %s: %v", node.Name, err)
+			}
+			if vm != nil {
+				return &nodeVM{vm: vm, vCenter: vCenter, lookup: VMLookupSystemUUID}, nil
+			}
+		}
+	}
+
+	found, err := getVMByName(ctx, checkOpts, node.Name)
+	if err != nil {
+		return nil, err
+	}
+	if found != nil {
+		return found, nil
+	}
+
+	switch {
+	case node.Spec.ProviderID == "":
+		return nil, errNodeNotInitialized
+	case providerIDErr != nil:
+		return nil, fmt.Errorf("unable to find VM %s: %v and the VM was not found by system UUID or name", node.Name, providerIDErr)
+	default:
+		return nil, fmt.Errorf("unable to find VM %s by UUID %s", node.Name, providerUUID)
+	}
+}
+
+// getVMByName finds VM with the given name in VM folders of all vCenters. vCenters are searched in parallel, when
+// the VM is found in more of them, the first vCenter in the configuration wins. It returns nil when there is no
+// such VM.
+func getVMByName(ctx context.Context, checkOpts CheckArgs, name string) (*nodeVM, error) {
+	found := make([]*nodeVM, len(checkOpts.vmConnection))
+	errs := vclib.ForEachConnection(ctx, checkOpts.vmConnection, nodeCheckTimeout, func(ctx context.Context, i int, conn *vclib.VSphereConnection) error {
+		if conn.Client == nil {
+			return nil
+		}
+		finder := find.NewFinder(conn.Client.Client, false)
+		for _, folder := range getVMFolders(checkOpts, conn) {
+			vmObject, err := finder.VirtualMachine(ctx, path.Join(folder, name))
+			if err != nil {
+				var notFound *find.NotFoundError
+				if errors.As(err, &notFound) {
+					continue
+				}
+				return fmt.Errorf("failed to find VM %s in folder %s: %v", name, folder, err)
+			}
+			if vm := checkOpts.inventory.GetVMByReference(conn.Hostname, vmObject.Reference()); vm != nil {
+				found[i] = &nodeVM{vm: vm, vCenter: conn.Hostname, lookup: VMLookupName}
+				return nil
+			}
+		}
+		return nil
+	})
+	for i := range found {
+		if found[i] != nil {
+			return found[i], nil
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// getVMFolders returns VM folders of the given vCenter from failure domains or, when there are none, from
+// the legacy cloud config.
+func getVMFolders(checkOpts CheckArgs, conn *vclib.VSphereConnection) []string {
+	var folders []string
+	if infra := checkOpts.apiClient.GetInfrastructure(); infra != nil && infra.Spec.PlatformSpec.VSphere != nil {
+		for _, fd := range infra.Spec.PlatformSpec.VSphere.FailureDomains {
+			if fd.Server == conn.Hostname && fd.Topology.Folder != "" {
+				folders = append(folders, fd.Topology.Folder)
+			}
+		}
+	}
+	if len(folders) == 0 && conn.Config != nil && conn.Config.LegacyConfig != nil && conn.Config.LegacyConfig.Workspace.Folder != "" {
+		folders = append(folders, conn.Config.LegacyConfig.Workspace.Folder)
+	}
+	return folders
+}
+
+// parseProviderID returns lower case BIOS UUID from the node providerID.
+func parseProviderID(providerID string) (string, error) {
+	if providerID == "" {
+		return "", fmt.Errorf("providerID is empty")
+	}
+	if !strings.HasPrefix(providerID, providerIDPrefix) {
+		return "", fmt.Errorf("providerID %q does not start with %s", providerID, providerIDPrefix)
+	}
+	uuid := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(providerID, providerIDPrefix)))
+	if !uuidRegexp.MatchString(uuid) {
+		return "", fmt.Errorf("providerID %q does not contain a valid UUID", providerID)
+	}
+	return uuid, nil
+}
+
+// biosUUIDFromSystemUUID swaps byte order of the first three fields of the UUID. It returns an empty string when
+// the system UUID is not a valid UUID.
+func biosUUIDFromSystemUUID(systemUUID string) string {
+	if !uuidRegexp.MatchString(systemUUID) {
+		return ""
+	}
+	fields := strings.Split(systemUUID, "-")
+	for i := 0; i < 3; i++ {
+		fields[i] = swapHexBytes(fields[i])
+	}
+	return strings.Join(fields, "-")
+}
+
+func swapHexBytes(s string) string {
+	swapped := make([]byte, 0, len(s))
+	for i := len(s); i >= 2; i -= 2 {
+		swapped = append(swapped, s[i-2:i]...)
+	}
+	return string(swapped)
+}
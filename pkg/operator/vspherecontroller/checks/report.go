@@ -0,0 +1,115 @@
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// CheckReport is a machine readable result of a failed check or of a node check that needs attention, published for
+// tools that can't parse condition messages.
+type CheckReport struct {
+	// CheckID identifies the check, it's the same as the reason of the operator conditions.
+	CheckID CheckStatusType `json:"checkID"`
+	// Severity is the action the failed check causes, like BlockUpgrade or Degrade.
+	Severity string `json:"severity"`
+	// Kind and Name identify the object the check failed for, when the failure is related to a single object.
+	Kind     string `json:"kind,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Observed string `json:"observed,omitempty"`
+	Required string `json:"required,omitempty"`
+	Message  string `json:"message"`
+	// VMLookup is the way the VM of the node was found in vCenter, it's set only for nodes.
+	VMLookup VMLookupMethod `json:"vmLookup,omitempty"`
+	// NotInitialized is true for nodes without providerID whose VM was not found in any other way.
+	NotInitialized bool `json:"notInitialized,omitempty"`
+}
+
+func MakeCheckReport(result ClusterCheckResult) CheckReport {
+	return CheckReport{
+		CheckID:  result.CheckStatus,
+		Severity: ActionToString(result.Action),
+		Kind:     result.Object.Kind,
+		Name:     result.Object.Name,
+		Observed: result.Observed,
+		Required: result.Required,
+		Message:  result.Reason,
+	}
+}
+
+// remediationHints describe how to fix failed checks, they are shown by the diagnose command.
+var remediationHints = map[CheckStatusType]string{
+	CheckStatusVSphereConnectionFailed: "Verify the vCenter address and credentials, and that vCenter can be reached from the cluster network, including any proxy.",
+	CheckStatusOpenshiftAPIError:       "Verify that the API server is available and that the objects used by the checks can be read.",
+	CheckStatusExistingDriverFound:     "Uninstall the vSphere CSI driver that was not installed by OpenShift, existing volumes are kept.",
+	CheckStatusDeprecatedVCenter:       "Upgrade vCenter to the required version.",
+	CheckStatusDeprecatedHWVersion:     "Upgrade the virtual hardware version of the node VMs to the required version.",
+	CheckStatusDeprecatedESXIVersion:   "Upgrade ESXi hosts that run cluster nodes to the required version.",
+	CheckStatusVcenterAPIError:         "Verify that the vCenter user has the required privileges and check the vCenter logs.",
+	CheckStatusStoragePolicyConfig:     "Verify the storage policy in the operator config and that the vCenter user may manage storage policies.",
+	CheckStatusVCenterClockSkew:        "Synchronize clocks of vCenter and of the cluster nodes with the same NTP servers.",
+	CheckStatusVCenterCertificate:      "Renew the machine SSL certificate of vCenter and update the CA bundle of the cluster when it changes.",
+	CheckStatusCNSUnavailable:          "Verify that the vSAN health service (vsan-health) runs in vCenter, volumes can't be provisioned or attached without it.",
+}
+
+// RemediationHint returns a hint how to fix a failed check, or an empty string when there is none.
+func RemediationHint(status CheckStatusType) string {
+	return remediationHints[status]
+}
+
+// Reports returns reports of failed node checks, of nodes excluded from the CSI driver, of nodes that are not
+// initialized yet and of nodes whose VM was not found by providerID, sorted by node name.
+func (s *NodeStatuses) Reports() []CheckReport {
+	statuses := s.Get()
+	nodeNames := make([]string, 0, len(statuses))
+	for node := range statuses {
+		nodeNames = append(nodeNames, node)
+	}
+	sort.Strings(nodeNames)
+
+	var reports []CheckReport
+	for _, node := range nodeNames {
+		status := statuses[node]
+		reported := false
+		if status.Result.CheckError != nil {
+			report := MakeCheckReport(status.Result)
+			report.VMLookup = status.VMLookup
+			reports = append(reports, report)
+			reported = true
+		}
+		if status.IneligibleReason != "" {
+			reports = append(reports, CheckReport{
+				CheckID:  CheckStatusDeprecatedHWVersion,
+				Severity: ActionToString(CheckActionBlockUpgrade),
+				Kind:     ObjectKindNode,
+				Name:     node,
+				Observed: hardwareVersionPrefix + strconv.FormatInt(status.VolumeLimit.HardwareVersion, 10),
+				Required: fmt.Sprintf("%s%d", hardwareVersionPrefix, minHardwareVersion),
+				Message:  status.IneligibleReason,
+				VMLookup: status.VMLookup,
+			})
+			reported = true
+		}
+		switch {
+		case status.NotInitialized:
+			reports = append(reports, CheckReport{
+				CheckID:        CheckStatusNodeNotInitialized,
+				Severity:       ActionToString(CheckActionPass),
+				Kind:           ObjectKindNode,
+				Name:           node,
+				Message:        nodeNotInitializedMessage,
+				NotInitialized: true,
+			})
+		case !reported && status.VMLookup != "" && status.VMLookup != VMLookupProviderID:
+			reports = append(reports, CheckReport{
+				CheckID:  CheckStatusNodeVMLookup,
+				Severity: ActionToString(CheckActionPass),
+				Kind:     ObjectKindNode,
+				Name:     node,
+				Message:  fmt.Sprintf("the node VM was found by %s because the node has no valid providerID", status.VMLookup),
+				VMLookup: status.VMLookup,
+			})
+		}
+	}
+	return reports
+}
@@ -0,0 +1,95 @@
+package checks
+
+import "errors"
+
+// SavedCheckResult is ClusterCheckResult in a form that can be stored in a ConfigMap and restored after
+// the operator restarts.
+type SavedCheckResult struct {
+	Status   CheckStatusType `json:"status"`
+	Action   CheckAction     `json:"action,omitempty"`
+	Reason   string          `json:"reason,omitempty"`
+	Object   *CheckObject    `json:"object,omitempty"`
+	Observed string          `json:"observed,omitempty"`
+	Required string          `json:"required,omitempty"`
+}
+
+// SavedNodeStatus is NodeStatus in a form that can be stored in a ConfigMap.
+type SavedNodeStatus struct {
+	VolumeLimit      NodeVolumeLimit  `json:"volumeLimit"`
+	IneligibleReason string           `json:"ineligibleReason,omitempty"`
+	Result           SavedCheckResult `json:"result"`
+	VMLookup         VMLookupMethod   `json:"vmLookup,omitempty"`
+	NotInitialized   bool             `json:"notInitialized,omitempty"`
+}
+
+func SaveCheckResult(result ClusterCheckResult) SavedCheckResult {
+	saved := SavedCheckResult{
+		Status:   result.CheckStatus,
+		Action:   result.Action,
+		Reason:   result.Reason,
+		Observed: result.Observed,
+		Required: result.Required,
+	}
+	if result.Object.Kind != "" {
+		object := result.Object
+		saved.Object = &object
+	}
+	return saved
+}
+
+// Restore returns the saved result. The original error is not known, the reason is used as the error message.
+func (r SavedCheckResult) Restore() ClusterCheckResult {
+	if r.Status == "" || r.Status == CheckStatusPass {
+		return MakeClusterCheckResultPass()
+	}
+	result := ClusterCheckResult{
+		CheckError:  errors.New(r.Reason),
+		CheckStatus: r.Status,
+		Action:      r.Action,
+		Reason:      r.Reason,
+		Observed:    r.Observed,
+		Required:    r.Required,
+	}
+	if r.Object != nil {
+		result.Object = *r.Object
+	}
+	return result
+}
+
+// Save returns node statuses in a form that can be stored in a ConfigMap. It returns nil when node VMs were not
+// checked yet.
+func (s *NodeStatuses) Save() map[string]SavedNodeStatus {
+	statuses := s.Get()
+	if statuses == nil {
+		return nil
+	}
+	saved := make(map[string]SavedNodeStatus, len(statuses))
+	for node, status := range statuses {
+		saved[node] = SavedNodeStatus{
+			VolumeLimit:      status.VolumeLimit,
+			IneligibleReason: status.IneligibleReason,
+			Result:           SaveCheckResult(status.Result),
+			VMLookup:         status.VMLookup,
+			NotInitialized:   status.NotInitialized,
+		}
+	}
+	return saved
+}
+
+// Restore replaces node statuses with saved ones.
+func (s *NodeStatuses) Restore(saved map[string]SavedNodeStatus) {
+	if saved == nil {
+		return
+	}
+	statuses := make(map[string]NodeStatus, len(saved))
+	for node, status := range saved {
+		statuses[node] = NodeStatus{
+			VolumeLimit:      status.VolumeLimit,
+			IneligibleReason: status.IneligibleReason,
+			Result:           status.Result.Restore(),
+			VMLookup:         status.VMLookup,
+			NotInitialized:   status.NotInitialized,
+		}
+	}
+	s.update(statuses)
+}
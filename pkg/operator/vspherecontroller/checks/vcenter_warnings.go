@@ -0,0 +1,37 @@
+package checks
+
+import (
+	"sync"
+)
+
+// VCenterWarnings holds problems of vCenters found by the checks that do not block upgrades yet, like a TLS
+// certificate that expires soon, indexed by vCenter hostnames.
+type VCenterWarnings struct {
+	lock     sync.RWMutex
+	warnings map[string]string
+}
+
+func NewVCenterWarnings() *VCenterWarnings {
+	return &VCenterWarnings{warnings: map[string]string{}}
+}
+
+// set replaces all warnings, it's safe to call on nil warnings.
+func (w *VCenterWarnings) set(warnings map[string]string) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.warnings = warnings
+}
+
+// Warnings returns a copy of the warnings.
+func (w *VCenterWarnings) Warnings() map[string]string {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	warnings := make(map[string]string, len(w.warnings))
+	for vCenter, warning := range w.warnings {
+		warnings[vCenter] = warning
+	}
+	return warnings
+}
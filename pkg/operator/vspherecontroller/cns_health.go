@@ -0,0 +1,47 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"k8s.io/klog/v2"
+)
+
+// cnsHealthTimeout limits the CNS query of a single vCenter, vCenters are checked in parallel.
+const cnsHealthTimeout = time.Minute
+
+// checkCNSHealth checks that the CNS service responds in the given connected vCenters. It returns errors of
+// vCenters whose CNS service is not available, indexed by their hostnames.
+func (c *VSphereController) checkCNSHealth(ctx context.Context, connected []*vclib.VSphereConnection) map[string]error {
+	healthFunc := queryCNS
+	if c.cnsHealthFunc != nil {
+		healthFunc = c.cnsHealthFunc
+	}
+	errs := vclib.ForEachConnection(ctx, connected, cnsHealthTimeout, func(ctx context.Context, _ int, vConn *vclib.VSphereConnection) error {
+		return healthFunc(ctx, vConn)
+	})
+
+	failed := map[string]error{}
+	for i, vConn := range connected {
+		if err := errs[i]; err != nil {
+			klog.Errorf("CNS service of vCenter %s is not available: %v", vConn.Hostname, err)
+			failed[vConn.Hostname] = err
+		}
+	}
+	return failed
+}
+
+// queryCNS creates the CNS client of the connection and queries a single volume.
+func queryCNS(ctx context.Context, vConn *vclib.VSphereConnection) error {
+	if err := vConn.LoginToCNS(ctx); err != nil {
+		return err
+	}
+	filter := cnstypes.CnsQueryFilter{Cursor: &cnstypes.CnsCursor{Limit: 1}}
+	if _, err := vConn.CnsClient().QueryVolume(ctx, filter); err != nil {
+		return fmt.Errorf("error querying CNS volumes: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	"github.com/vmware/govmomi"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCheckCNSHealth(t *testing.T) {
+	tests := []struct {
+		name            string
+		pvs             []runtime.Object
+		failedCNS       []string
+		unreachable     bool
+		failedSince     time.Duration
+		expectedStatus  checks.CheckStatusType
+		expectedAction  checks.CheckAction
+		expectedObject  string
+		expectedQueried int
+	}{
+		{
+			name:            "CNS is available",
+			expectedStatus:  checks.CheckStatusPass,
+			expectedAction:  checks.CheckActionPass,
+			expectedQueried: 2,
+		},
+		{
+			name:            "CNS of a vCenter is not available within the grace period",
+			pvs:             []runtime.Object{makeZonalPV("pv1", "us-west-1a")},
+			failedCNS:       []string{"vcenter2.lan"},
+			failedSince:     10 * time.Minute,
+			expectedStatus:  checks.CheckStatusCNSUnavailable,
+			expectedAction:  checks.CheckActionBlockUpgrade,
+			expectedObject:  "vcenter2.lan",
+			expectedQueried: 2,
+		},
+		{
+			name:            "CNS of a vCenter with volumes is not available for longer than the grace period",
+			pvs:             []runtime.Object{makeZonalPV("pv1", "us-west-1a")},
+			failedCNS:       []string{"vcenter2.lan"},
+			failedSince:     2 * time.Hour,
+			expectedStatus:  checks.CheckStatusCNSUnavailable,
+			expectedAction:  checks.CheckActionDegrade,
+			expectedObject:  "vcenter2.lan",
+			expectedQueried: 2,
+		},
+		{
+			name:            "CNS of all vCenters is not available",
+			failedCNS:       []string{"vcenter.lan", "vcenter2.lan"},
+			expectedStatus:  checks.CheckStatusCNSUnavailable,
+			expectedAction:  checks.CheckActionBlockUpgradeOrDegrade,
+			expectedQueried: 2,
+		},
+		{
+			name:            "unreachable vCenter is not queried",
+			unreachable:     true,
+			expectedStatus:  checks.CheckStatusVSphereConnectionFailed,
+			expectedAction:  checks.CheckActionBlockUpgrade,
+			expectedObject:  "127.0.0.1:1",
+			expectedQueried: 2,
+		},
+		{
+			name:            "CNS outage is not reported as a connection failure of another vCenter",
+			pvs:             []runtime.Object{makeZonalPV("pv1", "us-east-1a")},
+			failedCNS:       []string{"vcenter.lan"},
+			unreachable:     true,
+			failedSince:     2 * time.Hour,
+			expectedStatus:  checks.CheckStatusCNSUnavailable,
+			expectedAction:  checks.CheckActionDegrade,
+			expectedObject:  "vcenter.lan",
+			expectedQueried: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := testlib.GetZonalMultiVCenterInfra()
+			commonApiClient := testlib.NewFakeClients(test.pvs, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+			ctrl := newVsphereController(commonApiClient)
+			// Connections with a client are already logged in
+			ctrl.vSphereConnections = []*vclib.VSphereConnection{
+				{Hostname: "vcenter.lan", Client: &govmomi.Client{}},
+				{Hostname: "vcenter2.lan", Client: &govmomi.Client{}},
+			}
+			if test.unreachable {
+				// Nothing listens on the port
+				ctrl.vSphereConnections = append(ctrl.vSphereConnections, &vclib.VSphereConnection{Hostname: "127.0.0.1:1", Insecure: true})
+			}
+			ctrl.vCenterOutages = map[string]time.Time{}
+			for _, server := range test.failedCNS {
+				ctrl.vCenterOutages[server] = time.Now().Add(-test.failedSince)
+			}
+			queried := make(chan string, len(ctrl.vSphereConnections))
+			ctrl.cnsHealthFunc = func(ctx context.Context, vConn *vclib.VSphereConnection) error {
+				queried <- vConn.Hostname
+				for _, server := range test.failedCNS {
+					if server == vConn.Hostname {
+						return fmt.Errorf("503 Service Unavailable")
+					}
+				}
+				return nil
+			}
+
+			result := ctrl.connectVCenters(context.TODO(), infra)
+			if result.CheckStatus != test.expectedStatus {
+				t.Errorf("expected status %s, got %s: %s", test.expectedStatus, result.CheckStatus, result.Reason)
+			}
+			if result.Action != test.expectedAction {
+				t.Errorf("expected action %s, got %s: %s", checks.ActionToString(test.expectedAction), checks.ActionToString(result.Action), result.Reason)
+			}
+			if result.Object.Name != test.expectedObject {
+				t.Errorf("expected object %q, got %q", test.expectedObject, result.Object.Name)
+			}
+			if len(queried) != test.expectedQueried {
+				t.Errorf("expected %d vCenters to be queried, got %d", test.expectedQueried, len(queried))
+			}
+		})
+	}
+}
+
+func TestQueryCNSWithoutCNSService(t *testing.T) {
+	connections, cleanUpFunc, err := testlib.SetupSimulator(testlib.DefaultModel, testlib.GetInfraObject())
+	if err != nil {
+		t.Fatalf("unexpected error while connecting to simulator: %v", err)
+	}
+	defer cleanUpFunc()
+
+	// The simulator does not run the CNS service
+	if err := queryCNS(context.TODO(), connections[0]); err == nil {
+		t.Errorf("expected error querying CNS of the simulator")
+	}
+}
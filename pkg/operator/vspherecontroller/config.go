@@ -33,6 +33,16 @@ func (i *iniConfig) Set(section, key, value string) {
 	i.file.Section(section).Key(key).SetValue(value)
 }
 
+// FindKey returns value of the first key with the given name in any section.
+func (i *iniConfig) FindKey(key string) (string, bool) {
+	for _, section := range i.file.Sections() {
+		if section.HasKey(key) {
+			return section.Key(key).String(), true
+		}
+	}
+	return "", false
+}
+
 // String returns the string representation of the INI configuration.
 func (i *iniConfig) String() string {
 	if i == nil {
@@ -0,0 +1,163 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+
+	ocpv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	corelister "k8s.io/client-go/listers/core/v1"
+	storagelister "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// DiagnoseInputs are the objects needed to run the environment checks without the operator.
+type DiagnoseInputs struct {
+	Infrastructure *ocpv1.Infrastructure
+	// CloudConfig is the cloud provider ConfigMap referenced by the Infrastructure
+	CloudConfig *corev1.ConfigMap
+	// Credentials is the Secret with vCenter credentials of the CSI driver
+	Credentials *corev1.Secret
+	// Nodes, CSIDrivers and CSINodes are objects of the cluster, they're empty when the cluster does not exist yet
+	Nodes      []*corev1.Node
+	CSIDrivers []*storagev1.CSIDriver
+	CSINodes   []*storagev1.CSINode
+	// OperatorConfig is optional
+	OperatorConfig *utils.OperatorConfig
+	FeatureGates   featuregates.FeatureGate
+}
+
+// DiagnosticFinding is a failed check with a hint how to fix it.
+type DiagnosticFinding struct {
+	checks.CheckReport
+	Remediation string `json:"remediation,omitempty"`
+	// Blocking is true when the finding blocks installation of the CSI driver or cluster upgrades, or degrades
+	// the cluster.
+	Blocking bool `json:"blocking"`
+}
+
+// DiagnosticReport lists all failed checks.
+type DiagnosticReport struct {
+	Findings []DiagnosticFinding `json:"findings"`
+}
+
+// Blocking returns true when at least one finding is blocking.
+func (r *DiagnosticReport) Blocking() bool {
+	for _, finding := range r.Findings {
+		if finding.Blocking {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *DiagnosticReport) add(result checks.ClusterCheckResult, apiClient checks.KubeAPIInterface) {
+	if result.CheckError == nil {
+		return
+	}
+	// Report the severity the operator would use in this cluster
+	_, result = checks.CheckClusterStatus(result, apiClient)
+	r.Findings = append(r.Findings, DiagnosticFinding{
+		CheckReport: checks.MakeCheckReport(result),
+		Remediation: checks.RemediationHint(result.CheckStatus),
+		Blocking:    result.Action > checks.CheckActionPass,
+	})
+}
+
+// Diagnose connects to vCenters in the cloud config and runs all environment checks of the operator, with nodes
+// and CSI drivers from the inputs. Unlike the operator, it reports results of all checks and not only the most
+// severe one.
+func Diagnose(ctx context.Context, inputs DiagnoseInputs) (*DiagnosticReport, error) {
+	c, err := newOfflineController(inputs.Infrastructure, inputs.CloudConfig, inputs.Credentials, inputs.FeatureGates)
+	if err != nil {
+		return nil, err
+	}
+	return c.diagnose(ctx, inputs)
+}
+
+func (c *VSphereController) diagnose(ctx context.Context, inputs DiagnoseInputs) (*DiagnosticReport, error) {
+	c.operatorConfig = inputs.OperatorConfig
+	var err error
+	c.cloudConfig, err = c.loadCloudConfig(inputs.Infrastructure)
+	if err != nil {
+		return nil, err
+	}
+	infra := inputs.Infrastructure.DeepCopy() // ConvertToPlatformSpec modifies the object in place
+	ConvertToPlatformSpec(c.cloudConfig, infra)
+	apiClient := newDiagnosticAPIClient(infra, inputs)
+
+	report := &DiagnosticReport{}
+	if err := c.cloudConfig.ValidateConfig(c.featureGates); err != nil {
+		reason := fmt.Errorf("invalid cloud config: %v", err)
+		report.add(checks.MakeClusterDegradedError(checks.CheckStatusGenericError, reason), apiClient)
+	}
+
+	var connectionResult checks.ClusterCheckResult
+	logout := true
+	if c.vsphereConnectionFunc != nil {
+		c.vSphereConnections, connectionResult, logout = c.vsphereConnectionFunc()
+	} else {
+		connectionResult = c.loginToVCenter(ctx, infra)
+	}
+	defer func() {
+		for _, vConn := range c.vSphereConnections {
+			if vConn.Client != nil && logout {
+				if err := vConn.Logout(ctx); err != nil {
+					klog.Errorf("error closing connection to vCenter %s: %v", vConn.Hostname, err)
+				}
+			}
+		}
+		c.vSphereConnections = nil
+	}()
+	report.add(connectionResult, apiClient)
+
+	var connected []*vclib.VSphereConnection
+	for _, vConn := range c.vSphereConnections {
+		if vConn.Client != nil {
+			connected = append(connected, vConn)
+		}
+	}
+	if len(connected) == 0 {
+		// The checks need at least one vCenter, the connection failure is already reported
+		return report, nil
+	}
+
+	checkOpts := checks.NewCheckArgs(connected, apiClient, c.featureGates).WithUnavailableZones(c.unavailableZones)
+	for _, checker := range append(newCheckers(), &checks.NodeChecker{}) {
+		klog.V(2).Infof("Running check %s", checker.Name())
+		for _, result := range checker.Check(ctx, checkOpts) {
+			report.add(result, apiClient)
+		}
+	}
+	return report, nil
+}
+
+// newDiagnosticAPIClient returns API dependencies of the checks that serve objects from the inputs.
+func newDiagnosticAPIClient(infra *ocpv1.Infrastructure, inputs DiagnoseInputs) checks.KubeAPIInterface {
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range inputs.Nodes {
+		nodeIndexer.Add(node)
+	}
+	csiDriverIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, csiDriver := range inputs.CSIDrivers {
+		csiDriverIndexer.Add(csiDriver)
+	}
+	csiNodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, csiNode := range inputs.CSINodes {
+		csiNodeIndexer.Add(csiNode)
+	}
+	return &checks.KubeAPIInterfaceImpl{
+		Infrastructure:     infra,
+		NodeLister:         corelister.NewNodeLister(nodeIndexer),
+		CSIDriverLister:    storagelister.NewCSIDriverLister(csiDriverIndexer),
+		CSINodeLister:      storagelister.NewCSINodeLister(csiNodeIndexer),
+		StorageClassLister: storagelister.NewStorageClassLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		OperatorConfig:     inputs.OperatorConfig,
+	}
+}
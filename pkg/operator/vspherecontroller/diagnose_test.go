@@ -0,0 +1,200 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/api/features"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name              string
+		vcenterVersion    string
+		hardwareVersions  []string
+		csiDrivers        []*storagev1.CSIDriver
+		operatorConfig    *utils.OperatorConfig
+		failConnection    bool
+		unreachableServer bool
+		cnsError          error
+		expectedFindings  []checks.CheckStatusType
+		expectedBlocking  bool
+		expectedMessage   string
+		expectRemediation bool
+	}{
+		{
+			name:             "all checks pass",
+			vcenterVersion:   "7.0.2",
+			hardwareVersions: []string{"vmx-15", "vmx-15"},
+		},
+		{
+			name:              "old vCenter and unsupported driver",
+			vcenterVersion:    "6.7.0",
+			hardwareVersions:  []string{"vmx-15", "vmx-15"},
+			csiDrivers:        []*storagev1.CSIDriver{testlib.GetCSIDriver(false)},
+			expectedFindings:  []checks.CheckStatusType{checks.CheckStatusExistingDriverFound, checks.CheckStatusDeprecatedVCenter},
+			expectedBlocking:  true,
+			expectRemediation: true,
+		},
+		{
+			name:              "old hardware version",
+			vcenterVersion:    "7.0.2",
+			hardwareVersions:  []string{"vmx-13", "vmx-15"},
+			expectedFindings:  []checks.CheckStatusType{checks.CheckStatusDeprecatedHWVersion},
+			expectedBlocking:  true,
+			expectRemediation: true,
+		},
+		{
+			name:             "clock skew",
+			vcenterVersion:   "7.0.2",
+			hardwareVersions: []string{"vmx-15", "vmx-15"},
+			// Any real skew is larger than the threshold
+			operatorConfig:    &utils.OperatorConfig{ClockSkewThreshold: &metav1.Duration{Duration: time.Nanosecond}},
+			expectedFindings:  []checks.CheckStatusType{checks.CheckStatusVCenterClockSkew},
+			expectedBlocking:  true,
+			expectRemediation: true,
+		},
+		{
+			name:              "one of two vCenters unreachable for longer than the grace period",
+			vcenterVersion:    "7.0.2",
+			hardwareVersions:  []string{"vmx-15", "vmx-15"},
+			operatorConfig:    &utils.OperatorConfig{VCenterOutageGracePeriod: &metav1.Duration{}},
+			unreachableServer: true,
+			expectedFindings:  []checks.CheckStatusType{checks.CheckStatusVSphereConnectionFailed},
+			expectedBlocking:  true,
+			// Volumes are not known without a cluster, they're assumed to be in the unreachable vCenter
+			expectedMessage:   "has not been available for more than",
+			expectRemediation: true,
+		},
+		{
+			name:              "CNS unavailable",
+			vcenterVersion:    "7.0.2",
+			hardwareVersions:  []string{"vmx-15", "vmx-15"},
+			cnsError:          fmt.Errorf("CNS service is stopped"),
+			expectedFindings:  []checks.CheckStatusType{checks.CheckStatusCNSUnavailable},
+			expectedBlocking:  true,
+			expectRemediation: true,
+		},
+		{
+			name:              "connection failure",
+			vcenterVersion:    "7.0.2",
+			hardwareVersions:  []string{"vmx-15", "vmx-15"},
+			failConnection:    true,
+			expectedFindings:  []checks.CheckStatusType{checks.CheckStatusVSphereConnectionFailed},
+			expectedBlocking:  true,
+			expectRemediation: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := testlib.GetInfraObject()
+			connections, cleanUpFunc, err := testlib.SetupSimulator(testlib.DefaultModel, infra)
+			if err != nil {
+				t.Fatalf("unexpected error while connecting to simulator: %v", err)
+			}
+			defer cleanUpFunc()
+
+			nodes := testlib.DefaultNodes()
+			// The diagnose command logs in by itself, set the version reported to new clients
+			testlib.CustomizeSimulatorVCenterVersion(test.vcenterVersion, test.vcenterVersion)
+			if err := testlib.CustomizeHostVersion(testlib.DefaultHostId, "7.0.2"); err != nil {
+				t.Fatalf("error setting host version: %v", err)
+			}
+			if err := setHardwareVersionsFunc(nodes, connections[0], test.hardwareVersions)(); err != nil {
+				t.Fatalf("error setting hardware version: %v", err)
+			}
+
+			// Nothing listens on the port of the unreachable server
+			servers := []string{connections[0].Client.URL().Host}
+			if test.failConnection {
+				servers = []string{"127.0.0.1:1"}
+			}
+			if test.unreachableServer {
+				servers = append(servers, "127.0.0.1:1")
+			}
+			for _, server := range servers {
+				infra.Spec.PlatformSpec.VSphere.VCenters = append(infra.Spec.PlatformSpec.VSphere.VCenters,
+					configv1.VSpherePlatformVCenterSpec{Server: server, Port: 443, Datacenters: []string{"DC0"}})
+			}
+			cloudConfig, credentials := getSimulatorCloudConfig(servers)
+			inputs := DiagnoseInputs{
+				Infrastructure: infra,
+				CloudConfig:    cloudConfig,
+				Credentials:    credentials,
+				Nodes:          nodes,
+				CSIDrivers:     test.csiDrivers,
+				OperatorConfig: test.operatorConfig,
+				FeatureGates:   featuregates.NewFeatureGate([]configv1.FeatureGateName{features.FeatureGateVSphereMultiVCenters}, []configv1.FeatureGateName{}),
+			}
+			c, err := newOfflineController(inputs.Infrastructure, inputs.CloudConfig, inputs.Credentials, inputs.FeatureGates)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// vcsim does not simulate the CNS service
+			c.cnsHealthFunc = func(ctx context.Context, vConn *vclib.VSphereConnection) error {
+				return test.cnsError
+			}
+
+			report, err := c.diagnose(context.TODO(), inputs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(report.Findings) != len(test.expectedFindings) {
+				t.Fatalf("expected findings %v, got %+v", test.expectedFindings, report.Findings)
+			}
+			for i, finding := range report.Findings {
+				if finding.CheckID != test.expectedFindings[i] {
+					t.Errorf("expected finding %d to be %s, got %s: %s", i, test.expectedFindings[i], finding.CheckID, finding.Message)
+				}
+				if !strings.Contains(finding.Message, test.expectedMessage) {
+					t.Errorf("expected finding %s with message containing %q, got %q", finding.CheckID, test.expectedMessage, finding.Message)
+				}
+				if test.expectRemediation && finding.Remediation == "" {
+					t.Errorf("expected remediation of finding %s", finding.CheckID)
+				}
+			}
+			if report.Blocking() != test.expectedBlocking {
+				t.Errorf("expected blocking %v, got %v", test.expectedBlocking, report.Blocking())
+			}
+		})
+	}
+}
+
+// getSimulatorCloudConfig returns the cloud config and credentials of simulated vCenters listening at servers.
+func getSimulatorCloudConfig(servers []string) (*v1.ConfigMap, *v1.Secret) {
+	config := `
+global:
+  insecureFlag: true
+  secretName: vsphere-creds
+  secretNamespace: kube-system
+vcenter:
+`
+	credentials := testlib.GetSecret()
+	credentials.Data = map[string][]byte{}
+	for _, server := range servers {
+		config += fmt.Sprintf(`  "%[1]s":
+    server: "%[1]s"
+    insecureFlag: true
+    datacenters:
+    - DC0
+`, server)
+		credentials.Data[server+".username"] = []byte("vsphere-user")
+		credentials.Data[server+".password"] = []byte("vsphere-password")
+	}
+	cloudConfig := testlib.GetNewConfigMap()
+	cloudConfig.Data["config"] = config
+	return cloudConfig, credentials
+}
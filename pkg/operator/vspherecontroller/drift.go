@@ -0,0 +1,335 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	ocpv1 "github.com/openshift/api/config/v1"
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/assets"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	driftDetectedEvent = "OperandDriftDetected"
+	driftResolvedEvent = "OperandDriftResolved"
+	driftReason        = "OperandDrift"
+
+	migrationDatastoreURLKey = "migration-datastore-url"
+)
+
+// operandDrift lists differences between a live operand and what the operator would render.
+type operandDrift struct {
+	kind        string
+	name        string
+	differences []string
+}
+
+func (d operandDrift) String() string {
+	return fmt.Sprintf("%s %s: %s", d.kind, d.name, strings.Join(d.differences, "; "))
+}
+
+func (c *VSphereController) getDriftConditionName() string {
+	return c.name + "OperandDrift"
+}
+
+// syncDrift compares the live operands with what the operator would render while it is Unmanaged. It does not
+// change the operands, differences are reported in a condition, events and utils.OperandDriftMetric.
+func (c *VSphereController) syncDrift(ctx context.Context, opSpec *operatorapi.OperatorSpec, opStatus *operatorapi.OperatorStatus, infra *ocpv1.Infrastructure) error {
+	drifts := c.detectDrift(ctx, opSpec, infra)
+
+	utils.OperandDriftMetric.Reset()
+	messages := make([]string, 0, len(drifts))
+	for _, drift := range drifts {
+		utils.OperandDriftMetric.WithLabelValues(drift.kind, drift.name).Set(1)
+		messages = append(messages, drift.String())
+	}
+
+	existing := v1helpers.FindOperatorCondition(opStatus.Conditions, c.getDriftConditionName())
+	if len(drifts) == 0 {
+		if existing == nil {
+			return nil
+		}
+		klog.Infof("CSI driver operands match the operator configuration again")
+		c.eventRecorder.Eventf(driftResolvedEvent, "CSI driver operands match the operator configuration again")
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *operatorapi.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.getDriftConditionName())
+			return nil
+		})
+		return err
+	}
+
+	// Report only new differences, the same drift is found in every sync
+	reported := sets.New[string]()
+	if existing != nil {
+		reported.Insert(strings.Split(existing.Message, "\n")...)
+	}
+	for _, message := range messages {
+		if !reported.Has(message) {
+			klog.Warningf("Operand drift detected: %s", message)
+			c.eventRecorder.Warningf(driftDetectedEvent, "%s", message)
+		}
+	}
+
+	cond := operatorapi.OperatorCondition{
+		Type:    c.getDriftConditionName(),
+		Status:  operatorapi.ConditionTrue,
+		Reason:  driftReason,
+		Message: strings.Join(messages, "\n"),
+	}
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// clearDrift removes the drift condition and metric when the operator manages the operands again.
+func (c *VSphereController) clearDrift(ctx context.Context, opStatus *operatorapi.OperatorStatus) error {
+	utils.OperandDriftMetric.Reset()
+	if v1helpers.FindOperatorCondition(opStatus.Conditions, c.getDriftConditionName()) == nil {
+		return nil
+	}
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *operatorapi.OperatorStatus) error {
+		v1helpers.RemoveOperatorCondition(&status.Conditions, c.getDriftConditionName())
+		return nil
+	})
+	return err
+}
+
+// detectDrift returns operands that differ from what the operator would render. Operands that can't be rendered
+// or read are logged and skipped, they must not degrade an Unmanaged operator.
+func (c *VSphereController) detectDrift(ctx context.Context, opSpec *operatorapi.OperatorSpec, infra *ocpv1.Infrastructure) []operandDrift {
+	clusterCSIDriver, err := c.clusterCSIDriverLister.Get(utils.VSphereDriverName)
+	if err != nil {
+		klog.Errorf("error getting ClusterCSIDriver, skipping drift detection: %v", err)
+		return nil
+	}
+
+	var drifts []operandDrift
+	for _, detect := range []func() (*operandDrift, error){
+		func() (*operandDrift, error) { return c.detectDeploymentDrift(opSpec, clusterCSIDriver, infra) },
+		func() (*operandDrift, error) { return c.detectDaemonSetDrift(opSpec) },
+		func() (*operandDrift, error) { return c.detectDriverConfigDrift(ctx, clusterCSIDriver, infra) },
+		func() (*operandDrift, error) { return c.detectFeatureConfigDrift(ctx, clusterCSIDriver, infra) },
+	} {
+		drift, err := detect()
+		if err != nil {
+			klog.Errorf("error detecting operand drift: %v", err)
+			continue
+		}
+		if drift != nil {
+			drifts = append(drifts, *drift)
+		}
+	}
+	return drifts
+}
+
+// volumeLimitKnown returns true when the cluster checks computed the volume limit of the nodes. Until then, the
+// operands can carry a limit computed before the operator restarted, which is not compared.
+func (c *VSphereController) volumeLimitKnown() bool {
+	if c.nodeStatuses == nil {
+		return false
+	}
+	_, found := c.nodeStatuses.ClusterVolumeLimit()
+	return found
+}
+
+// ignoredEnv returns environment variables of the CSI driver whose values are not known yet.
+func (c *VSphereController) ignoredEnv() sets.Set[string] {
+	if c.volumeLimitKnown() {
+		return sets.New[string]()
+	}
+	return sets.New[string](maxVolumesPerNodeEnv)
+}
+
+func (c *VSphereController) detectDeploymentDrift(opSpec *operatorapi.OperatorSpec, clusterCSIDriver *operatorapi.ClusterCSIDriver, infra *ocpv1.Infrastructure) (*operandDrift, error) {
+	expected, err := renderControllerDeployment(opSpec, c.apiClients.ConfigInformers, clusterCSIDriver, infra, c.nodeStatuses)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering Deployment: %v", err)
+	}
+	drift := &operandDrift{kind: "Deployment", name: expected.Name}
+	live, err := c.deploymentLister.Deployments(expected.Namespace).Get(expected.Name)
+	if apierrors.IsNotFound(err) {
+		drift.differences = []string{"not found"}
+		return drift, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if expected.Spec.Replicas != nil && live.Spec.Replicas != nil && *expected.Spec.Replicas != *live.Spec.Replicas {
+		drift.differences = append(drift.differences, fmt.Sprintf("replicas is %d, expected %d", *live.Spec.Replicas, *expected.Spec.Replicas))
+	}
+	drift.differences = append(drift.differences, diffContainers(expected.Spec.Template.Spec.Containers, live.Spec.Template.Spec.Containers, c.ignoredEnv())...)
+	if len(drift.differences) == 0 {
+		return nil, nil
+	}
+	return drift, nil
+}
+
+func (c *VSphereController) detectDaemonSetDrift(opSpec *operatorapi.OperatorSpec) (*operandDrift, error) {
+	expected, err := renderNodeDaemonSet(opSpec, c.nodeStatuses)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering DaemonSet: %v", err)
+	}
+	drift := &operandDrift{kind: "DaemonSet", name: expected.Name}
+	live, err := c.daemonSetLister.DaemonSets(expected.Namespace).Get(expected.Name)
+	if apierrors.IsNotFound(err) {
+		drift.differences = []string{"not found"}
+		return drift, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	drift.differences = diffContainers(expected.Spec.Template.Spec.Containers, live.Spec.Template.Spec.Containers, c.ignoredEnv())
+	if len(drift.differences) == 0 {
+		return nil, nil
+	}
+	return drift, nil
+}
+
+// detectDriverConfigDrift compares cloud.conf of the CSI driver. The migration datastore URL is taken from the live
+// configuration, it can't be read from vCenter without changing anything.
+func (c *VSphereController) detectDriverConfigDrift(ctx context.Context, clusterCSIDriver *operatorapi.ClusterCSIDriver, infra *ocpv1.Infrastructure) (*operandDrift, error) {
+	drift := &operandDrift{kind: "Secret", name: driverConfigSecretName}
+	live, err := c.kubeClient.CoreV1().Secrets(defaultNamespace).Get(ctx, driverConfigSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		drift.differences = []string{"not found"}
+		return drift, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	liveConfig, err := newINIConfig(string(live.Data["cloud.conf"]))
+	if err != nil {
+		drift.differences = []string{fmt.Sprintf("cloud.conf can't be parsed: %v", err)}
+		return drift, nil
+	}
+	datastoreURL, _ := liveConfig.FindKey(migrationDatastoreURLKey)
+
+	cloudConfig, err := c.loadCloudConfig(infra)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := c.applyClusterCSIDriverChange(infra, cloudConfig, clusterCSIDriver, datastoreURL)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering cloud.conf: %v", err)
+	}
+	// The content is not reported, it contains vCenter credentials
+	if string(expected.Data["cloud.conf"]) != liveConfig.String() {
+		drift.differences = []string{"cloud.conf differs from the configuration rendered by the operator"}
+		return drift, nil
+	}
+	return nil, nil
+}
+
+func (c *VSphereController) detectFeatureConfigDrift(ctx context.Context, clusterCSIDriver *operatorapi.ClusterCSIDriver, infra *ocpv1.Infrastructure) (*operandDrift, error) {
+	manifest, err := assets.ReadFile("vsphere_features_config.yaml")
+	if err != nil {
+		return nil, err
+	}
+	expected := utils.RenderFeatureConfigMap(manifest, clusterCSIDriver, infra, c.nodeStatuses.ExtendedVolumeLimit())
+	drift := &operandDrift{kind: "ConfigMap", name: expected.Name}
+	live, err := c.kubeClient.CoreV1().ConfigMaps(expected.Namespace).Get(ctx, expected.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		drift.differences = []string{"not found"}
+		return drift, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	liveData := live.Data
+	if !c.volumeLimitKnown() {
+		// Without the volume limit, the feature of the limit can't be compared
+		liveData = make(map[string]string, len(live.Data))
+		for key, value := range live.Data {
+			liveData[key] = value
+		}
+		delete(expected.Data, utils.MaxPVSCSITargetsPerVMFeature)
+		delete(liveData, utils.MaxPVSCSITargetsPerVMFeature)
+	}
+	drift.differences = diffValues("key", expected.Data, liveData)
+	if len(drift.differences) == 0 {
+		return nil, nil
+	}
+	return drift, nil
+}
+
+// diffContainers compares images, commands, arguments and environment variables of containers, except for
+// the ignored variables. Other fields are defaulted by the API server or set by hooks that need other objects
+// in the cluster.
+func diffContainers(expected, live []v1.Container, ignoredEnv sets.Set[string]) []string {
+	var differences []string
+	liveContainers := map[string]v1.Container{}
+	for _, container := range live {
+		liveContainers[container.Name] = container
+	}
+	for _, exp := range expected {
+		container, found := liveContainers[exp.Name]
+		if !found {
+			differences = append(differences, fmt.Sprintf("container %s is missing", exp.Name))
+			continue
+		}
+		delete(liveContainers, exp.Name)
+		if container.Image != exp.Image {
+			differences = append(differences, fmt.Sprintf("container %s image is %q, expected %q", exp.Name, container.Image, exp.Image))
+		}
+		if !equality.Semantic.DeepEqual(container.Command, exp.Command) {
+			differences = append(differences, fmt.Sprintf("container %s command is %q, expected %q", exp.Name, container.Command, exp.Command))
+		}
+		if !equality.Semantic.DeepEqual(container.Args, exp.Args) {
+			differences = append(differences, fmt.Sprintf("container %s args are %q, expected %q", exp.Name, container.Args, exp.Args))
+		}
+		for _, difference := range diffValues("env", envValues(exp.Env, ignoredEnv), envValues(container.Env, ignoredEnv)) {
+			differences = append(differences, fmt.Sprintf("container %s %s", exp.Name, difference))
+		}
+	}
+	var extra []string
+	for name := range liveContainers {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		differences = append(differences, fmt.Sprintf("unexpected container %s", name))
+	}
+	return differences
+}
+
+// envValues returns values of environment variables without the ignored ones. Variables from references are
+// compared only by their name, the API server adds defaults to the references.
+func envValues(env []v1.EnvVar, ignored sets.Set[string]) map[string]string {
+	values := make(map[string]string, len(env))
+	for _, e := range env {
+		if !ignored.Has(e.Name) {
+			values[e.Name] = e.Value
+		}
+	}
+	return values
+}
+
+// diffValues compares two maps and returns sorted differences.
+func diffValues(what string, expected, live map[string]string) []string {
+	var differences []string
+	for key, value := range expected {
+		liveValue, found := live[key]
+		switch {
+		case !found:
+			differences = append(differences, fmt.Sprintf("%s %s is missing", what, key))
+		case liveValue != value:
+			differences = append(differences, fmt.Sprintf("%s %s is %q, expected %q", what, key, liveValue, value))
+		}
+	}
+	for key := range live {
+		if _, found := expected[key]; !found {
+			differences = append(differences, fmt.Sprintf("unexpected %s %s", what, key))
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
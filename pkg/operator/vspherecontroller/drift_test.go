@@ -0,0 +1,176 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/assets"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestDriftDetection(t *testing.T) {
+	utils.OperandDriftMetric.Reset()
+	unmanaged := func(instance *testlib.FakeDriverInstance) *testlib.FakeDriverInstance {
+		instance.Spec.ManagementState = opv1.Unmanaged
+		return instance
+	}
+	infra := testlib.GetInfraObject()
+	initialObjects := []runtime.Object{testlib.GetNewConfigMap(), testlib.GetSecret()}
+	commonApiClient := testlib.NewFakeClients(initialObjects, testlib.MakeFakeDriverInstance(unmanaged), runtime.Object(infra))
+	clusterCSIDriver := testlib.GetClusterCSIDriver(false)
+	testlib.AddClusterCSIDriverClient(commonApiClient, clusterCSIDriver)
+	initialObjects = append(initialObjects, runtime.Object(clusterCSIDriver))
+	// Create the controller first, informers of its listers must be synced before the operands are added to them
+	ctrl := newVsphereController(commonApiClient)
+	ctx := context.TODO()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	testlib.StartFakeInformer(commonApiClient, stopCh)
+	testlib.WaitForSync(commonApiClient, stopCh)
+	if err := testlib.AddInitialObjects(initialObjects, commonApiClient); err != nil {
+		t.Fatalf("error adding initial objects: %v", err)
+	}
+
+	// The checks computed a volume limit above the default
+	checker := newVSphereEnvironmentChecker()
+	checker.RestoreState(checkerState{
+		LastCheck: metav1.NewTime(time.Now()),
+		NextCheck: metav1.NewTime(time.Now().Add(time.Hour)),
+	})
+	ctrl.vSphereChecker = checker
+	ctrl.nodeStatuses.Restore(map[string]checks.SavedNodeStatus{
+		"node1": {VolumeLimit: checks.NodeVolumeLimit{Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15}},
+	})
+	if err := ctrl.saveCheckState(ctx); err != nil {
+		t.Fatalf("error saving check state: %v", err)
+	}
+
+	// Create the operands as the operator would render them
+	opSpec, _, _, err := ctrl.operatorClient.GetOperatorState()
+	if err != nil {
+		t.Fatalf("failed to get operator state: %v", err)
+	}
+	deployment, err := renderControllerDeployment(opSpec, commonApiClient.ConfigInformers, clusterCSIDriver, infra, ctrl.nodeStatuses)
+	if err != nil {
+		t.Fatalf("failed to render Deployment: %v", err)
+	}
+	daemonSet, err := renderNodeDaemonSet(opSpec, ctrl.nodeStatuses)
+	if err != nil {
+		t.Fatalf("failed to render DaemonSet: %v", err)
+	}
+	cloudConfig, err := ctrl.loadCloudConfig(infra)
+	if err != nil {
+		t.Fatalf("failed to load cloud config: %v", err)
+	}
+	secret, err := ctrl.applyClusterCSIDriverChange(infra, cloudConfig, clusterCSIDriver, "")
+	if err != nil {
+		t.Fatalf("failed to render cloud.conf: %v", err)
+	}
+	featureManifest, _ := assets.ReadFile("vsphere_features_config.yaml")
+	featureConfigMap := utils.RenderFeatureConfigMap(featureManifest, clusterCSIDriver, infra, ctrl.nodeStatuses.ExtendedVolumeLimit())
+
+	kubeClient := commonApiClient.KubeClient
+	if err := testlib.AddInitialObjects([]runtime.Object{deployment, daemonSet}, commonApiClient); err != nil {
+		t.Fatalf("failed to add operands: %v", err)
+	}
+	deploymentStore := commonApiClient.KubeInformers.InformersFor(defaultNamespace).Apps().V1().Deployments().Informer().GetStore()
+	if _, err := kubeClient.CoreV1().Secrets(secret.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create Secret: %v", err)
+	}
+	if _, err := kubeClient.CoreV1().ConfigMaps(featureConfigMap.Namespace).Create(ctx, featureConfigMap, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create ConfigMap: %v", err)
+	}
+
+	sync := func() *opv1.OperatorCondition {
+		if err := ctrl.sync(ctx, factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, status, _, err := ctrl.operatorClient.GetOperatorState()
+		if err != nil {
+			t.Fatalf("failed to get operator state: %v", err)
+		}
+		return v1helpers.FindOperatorCondition(status.Conditions, ctrl.getDriftConditionName())
+	}
+
+	if cond := sync(); cond != nil {
+		t.Fatalf("expected no drift, got: %s", cond.Message)
+	}
+
+	// Hotfix of the driver image
+	hotfix := deployment.DeepCopy()
+	for i := range hotfix.Spec.Template.Spec.Containers {
+		if hotfix.Spec.Template.Spec.Containers[i].Name == driverContainerName {
+			hotfix.Spec.Template.Spec.Containers[i].Image = "quay.io/hotfix/vsphere-csi-driver:test"
+		}
+	}
+	if err := deploymentStore.Update(hotfix); err != nil {
+		t.Fatalf("failed to update Deployment: %v", err)
+	}
+	cond := sync()
+	if cond == nil || cond.Status != opv1.ConditionTrue {
+		t.Fatalf("expected drift condition to be true, got %+v", cond)
+	}
+	if !strings.Contains(cond.Message, `Deployment vmware-vsphere-csi-driver-controller: container csi-driver image is "quay.io/hotfix/vsphere-csi-driver:test"`) {
+		t.Errorf("unexpected drift message: %s", cond.Message)
+	}
+	value, err := testutil.GetGaugeMetricValue(utils.OperandDriftMetric.WithLabelValues("Deployment", deployment.Name))
+	if err != nil || value != 1 {
+		t.Errorf("expected drift metric of the Deployment to be 1, got %v (%v)", value, err)
+	}
+	if !hasEvent(ctrl.eventRecorder, driftDetectedEvent) {
+		t.Errorf("expected event %s", driftDetectedEvent)
+	}
+
+	// Revert the hotfix
+	if err := deploymentStore.Update(deployment); err != nil {
+		t.Fatalf("failed to update Deployment: %v", err)
+	}
+	if cond := sync(); cond != nil {
+		t.Fatalf("expected drift condition to be removed, got: %s", cond.Message)
+	}
+	if !hasEvent(ctrl.eventRecorder, driftResolvedEvent) {
+		t.Errorf("expected event %s", driftResolvedEvent)
+	}
+
+	// A restarted operator compares the volume limit restored from the check state
+	ctrl = newVsphereController(commonApiClient)
+	if cond := sync(); cond != nil {
+		t.Fatalf("expected no drift after restart, got: %s", cond.Message)
+	}
+	if !ctrl.volumeLimitKnown() {
+		t.Errorf("expected the volume limit to be restored before drift detection")
+	}
+
+	// Without the check state, the volume limit is not known and it's not compared
+	if err := kubeClient.CoreV1().ConfigMaps(defaultNamespace).Delete(ctx, checkStateConfigMapName, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete check state: %v", err)
+	}
+	ctrl = newVsphereController(commonApiClient)
+	if cond := sync(); cond != nil {
+		t.Fatalf("expected no drift without check state, got: %s", cond.Message)
+	}
+}
+
+func hasEvent(recorder events.Recorder, reason string) bool {
+	inMemoryRecorder, ok := recorder.(events.InMemoryRecorder)
+	if !ok {
+		return false
+	}
+	for _, event := range inMemoryRecorder.Events() {
+		if event.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
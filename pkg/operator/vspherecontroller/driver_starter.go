@@ -8,8 +8,11 @@ import (
 
 	"github.com/openshift/library-go/pkg/operator/resource/resourcehash"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
 
+	ocpv1 "github.com/openshift/api/config/v1"
 	operatorapi "github.com/openshift/api/operator/v1"
+	cfginformers "github.com/openshift/client-go/config/informers/externalversions"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/csi/csicontrollerset"
 	"github.com/openshift/library-go/pkg/operator/csi/csidrivercontrollerservicecontroller"
@@ -26,8 +29,35 @@ import (
 )
 
 func (c *VSphereController) createCSIDriver() {
+	controllerHooks := append(
+		controllerDeploymentHooks(c.apiClients.ConfigInformers, c.topologyHook, c.nodeStatuses),
+		csidrivercontrollerservicecontroller.WithCABundleDeploymentHook(
+			defaultNamespace,
+			trustedCAConfigMap,
+			c.apiClients.ConfigMapInformer,
+		),
+		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(
+			defaultNamespace,
+			metricsCertSecretName,
+			c.apiClients.SecretInformer,
+		),
+		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(
+			defaultNamespace,
+			driverConfigSecretName,
+			c.apiClients.SecretInformer,
+		),
+	)
+	nodeHooks := append(
+		nodeDaemonSetHooks(c.nodeStatuses),
+		csidrivernodeservicecontroller.WithCABundleDaemonSetHook(
+			defaultNamespace,
+			trustedCAConfigMap,
+			c.apiClients.ConfigMapInformer,
+		),
+		WithSecretDaemonSetAnnotationHook(driverConfigSecretName, defaultNamespace, c.apiClients.SecretInformer),
+	)
 	csiControllerSet := csicontrollerset.NewCSIControllerSet(
-		c.operatorClient,
+		c.operandOperatorClient,
 		c.eventRecorder,
 	).WithLogLevelController().WithManagementStateController(
 		driverOperandName,
@@ -76,10 +106,10 @@ func (c *VSphereController) createCSIDriver() {
 			"webhook/pdb.yaml",
 		},
 		func() bool {
-			return getOperatorSyncState(c.operatorClient) == operatorapi.Managed
+			return getOperatorSyncState(c.operandOperatorClient) == operatorapi.Managed
 		},
 		func() bool {
-			return getOperatorSyncState(c.operatorClient) == operatorapi.Removed
+			return getOperatorSyncState(c.operandOperatorClient) == operatorapi.Removed
 		},
 	).WithConditionalStaticResourcesController(
 		"VMwareVSphereDriverConditionalStaticResourcesController",
@@ -100,6 +130,25 @@ func (c *VSphereController) createCSIDriver() {
 		func() bool {
 			return false
 		},
+	).WithConditionalStaticResourcesController(
+		"VMwareVSphereDriverMonitoringStaticResourcesController",
+		c.kubeClient,
+		c.apiClients.DynamicClient,
+		c.apiClients.KubeInformers,
+		assets.ReadFile,
+		[]string{
+			"prometheusrule.yaml",
+		},
+		// Only install when monitoring CRD exists.
+		func() bool {
+			name := "prometheusrules.monitoring.coreos.com"
+			_, err := c.apiClients.ApiExtClient.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), name, metav1.GetOptions{})
+			return err == nil
+		},
+		// Removed together with the other assets in operator_removal.go.
+		func() bool {
+			return false
+		},
 	).WithCSIConfigObserverController(
 		"VMwareVSphereDriverCSIConfigObserverController",
 		c.apiClients.ConfigInformers,
@@ -115,26 +164,7 @@ func (c *VSphereController) createCSIDriver() {
 			c.apiClients.ConfigMapInformer.Informer(),
 			c.apiClients.NodeInformer.Informer(),
 		},
-		WithSyncerImageHook("vsphere-syncer"),
-		WithLogLevelDeploymentHook(),
-		c.topologyHook,
-		csidrivercontrollerservicecontroller.WithObservedProxyDeploymentHook(),
-		csidrivercontrollerservicecontroller.WithCABundleDeploymentHook(
-			defaultNamespace,
-			trustedCAConfigMap,
-			c.apiClients.ConfigMapInformer,
-		),
-		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(
-			defaultNamespace,
-			metricsCertSecretName,
-			c.apiClients.SecretInformer,
-		),
-		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(
-			defaultNamespace,
-			driverConfigSecretName,
-			c.apiClients.SecretInformer,
-		),
-		csidrivercontrollerservicecontroller.WithReplicasHook(c.apiClients.ConfigInformers),
+		controllerHooks...,
 	).WithCSIDriverNodeService(
 		"VMwareVSphereDriverNodeServiceController",
 		assets.ReadFile,
@@ -142,14 +172,7 @@ func (c *VSphereController) createCSIDriver() {
 		c.apiClients.KubeClient,
 		c.apiClients.KubeInformers.InformersFor(defaultNamespace),
 		[]factory.Informer{c.apiClients.ConfigMapInformer.Informer()},
-		WithLogLevelDaemonSetHook(),
-		csidrivernodeservicecontroller.WithObservedProxyDaemonSetHook(),
-		csidrivernodeservicecontroller.WithCABundleDaemonSetHook(
-			defaultNamespace,
-			trustedCAConfigMap,
-			c.apiClients.ConfigMapInformer,
-		),
-		WithSecretDaemonSetAnnotationHook(driverConfigSecretName, defaultNamespace, c.apiClients.SecretInformer),
+		nodeHooks...,
 	).WithServiceMonitorController(
 		"VMWareVSphereDriverServiceMonitorController",
 		c.apiClients.DynamicClient,
@@ -162,6 +185,33 @@ func (c *VSphereController) createCSIDriver() {
 	})
 }
 
+// controllerDeploymentHooks returns the Deployment hooks that don't need other objects in the cluster. They are
+// shared by the controller service controller and renderControllerDeployment.
+func controllerDeploymentHooks(
+	configInformers cfginformers.SharedInformerFactory,
+	topologyHook deploymentcontroller.DeploymentHookFunc,
+	nodeStatuses *checks.NodeStatuses) []deploymentcontroller.DeploymentHookFunc {
+
+	return []deploymentcontroller.DeploymentHookFunc{
+		WithSyncerImageHook("vsphere-syncer"),
+		WithLogLevelDeploymentHook(),
+		topologyHook,
+		csidrivercontrollerservicecontroller.WithObservedProxyDeploymentHook(),
+		csidrivercontrollerservicecontroller.WithReplicasHook(configInformers),
+		WithVolumeLimitDeploymentHook(nodeStatuses),
+	}
+}
+
+// nodeDaemonSetHooks returns the DaemonSet hooks that don't need other objects in the cluster. They are shared by
+// the node service controller and renderNodeDaemonSet.
+func nodeDaemonSetHooks(nodeStatuses *checks.NodeStatuses) []csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return []csidrivernodeservicecontroller.DaemonSetHookFunc{
+		WithLogLevelDaemonSetHook(),
+		csidrivernodeservicecontroller.WithObservedProxyDaemonSetHook(),
+		WithVolumeLimitDaemonSetHook(nodeStatuses),
+	}
+}
+
 func (c *VSphereController) topologyHook(opSpec *operatorapi.OperatorSpec, deployment *appsv1.Deployment) error {
 	clusterCSIDriver, err := c.apiClients.ClusterCSIDriverInformer.Lister().Get(utils.VSphereDriverName)
 	if err != nil {
@@ -172,29 +222,23 @@ func (c *VSphereController) topologyHook(opSpec *operatorapi.OperatorSpec, deplo
 	if err != nil {
 		return err
 	}
-	topologyCategories := utils.GetTopologyCategories(clusterCSIDriver, infra)
-	if len(topologyCategories) > 0 {
-		containers := deployment.Spec.Template.Spec.Containers
-		for i := range containers {
-			if containers[i].Name != "csi-provisioner" {
-				continue
-			}
-
-			containers[i].Args = append(containers[i].Args, "--feature-gates=Topology=true", "--strict-topology")
-		}
-		deployment.Spec.Template.Spec.Containers = containers
-	} else {
-		containers := deployment.Spec.Template.Spec.Containers
-		for i := range containers {
-			if containers[i].Name != "csi-provisioner" {
-				continue
-			}
+	deployment.Spec.Template.Spec.Containers = setTopologyArgs(deployment.Spec.Template.Spec.Containers, clusterCSIDriver, infra)
+	return nil
+}
 
-			containers[i].Args = append(containers[i].Args, "--feature-gates=Topology=false")
+// setTopologyArgs enables or disables topology in the CSI provisioner.
+func setTopologyArgs(containers []v1.Container, clusterCSIDriver *operatorapi.ClusterCSIDriver, infra *ocpv1.Infrastructure) []v1.Container {
+	args := []string{"--feature-gates=Topology=false"}
+	if topologyCategories := utils.GetTopologyCategories(clusterCSIDriver, infra); len(topologyCategories) > 0 {
+		args = []string{"--feature-gates=Topology=true", "--strict-topology"}
+	}
+	for i := range containers {
+		if containers[i].Name != "csi-provisioner" {
+			continue
 		}
-		deployment.Spec.Template.Spec.Containers = containers
+		containers[i].Args = append(containers[i].Args, args...)
 	}
-	return nil
+	return containers
 }
 
 func WithSyncerImageHook(containerName string) deploymentcontroller.DeploymentHookFunc {
@@ -0,0 +1,50 @@
+package vspherecontroller
+
+import (
+	"context"
+	"sync/atomic"
+
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// gatedOperatorClient is the operator client of operand controllers that must run only under some conditions.
+// library-go controllers can't be started again once they were stopped and their informer event handlers can't
+// be removed, so the controllers keep running and the client reports the Removed management state to them while
+// the gate is closed. They remove their operand then, like when the whole driver is removed.
+type gatedOperatorClient struct {
+	v1helpers.OperatorClientWithFinalizers
+	open atomic.Bool
+}
+
+func newGatedOperatorClient(client v1helpers.OperatorClientWithFinalizers) *gatedOperatorClient {
+	return &gatedOperatorClient{OperatorClientWithFinalizers: client}
+}
+
+// setOpen opens or closes the gate. It returns true when the state of the gate changed.
+func (g *gatedOperatorClient) setOpen(open bool) bool {
+	return g.open.Swap(open) != open
+}
+
+func (g *gatedOperatorClient) isOpen() bool {
+	return g.open.Load()
+}
+
+func (g *gatedOperatorClient) GetOperatorState() (*operatorapi.OperatorSpec, *operatorapi.OperatorStatus, string, error) {
+	spec, status, resourceVersion, err := g.OperatorClientWithFinalizers.GetOperatorState()
+	return g.gateSpec(spec), status, resourceVersion, err
+}
+
+func (g *gatedOperatorClient) GetOperatorStateWithQuorum(ctx context.Context) (*operatorapi.OperatorSpec, *operatorapi.OperatorStatus, string, error) {
+	spec, status, resourceVersion, err := g.OperatorClientWithFinalizers.GetOperatorStateWithQuorum(ctx)
+	return g.gateSpec(spec), status, resourceVersion, err
+}
+
+func (g *gatedOperatorClient) gateSpec(spec *operatorapi.OperatorSpec) *operatorapi.OperatorSpec {
+	if spec == nil || g.isOpen() {
+		return spec
+	}
+	spec = spec.DeepCopy()
+	spec.ManagementState = operatorapi.Removed
+	return spec
+}
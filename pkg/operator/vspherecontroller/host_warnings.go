@@ -0,0 +1,46 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+const (
+	hostProblemEvent         = "ESXiHostProblem"
+	hostProblemResolvedEvent = "ESXiHostProblemsResolved"
+	hostProblemReason        = "ESXiHostProblems"
+)
+
+func (c *VSphereController) getHostWarningConditionName() string {
+	return c.name + "ESXiHostWarning"
+}
+
+// syncHostWarnings reports problems of ESXi hosts that run cluster nodes, found by the last node check, in
+// a condition and events. The problems cause volume attach failures on the affected nodes, but they are usually
+// temporary, like a host maintenance, so they do not block upgrades.
+func (c *VSphereController) syncHostWarnings(ctx context.Context) error {
+	if c.nodeStatuses == nil {
+		return nil
+	}
+	warnings := c.nodeStatuses.HostWarnings()
+	nodeNames := make([]string, 0, len(warnings))
+	for node := range warnings {
+		nodeNames = append(nodeNames, node)
+	}
+	sort.Strings(nodeNames)
+	messages := make([]string, 0, len(nodeNames))
+	for _, node := range nodeNames {
+		messages = append(messages, fmt.Sprintf("node %s: %s", node, warnings[node]))
+	}
+
+	return c.syncWarningCondition(
+		ctx,
+		c.getHostWarningConditionName(),
+		hostProblemReason,
+		hostProblemEvent,
+		hostProblemResolvedEvent,
+		"ESXi hosts of all nodes are available again",
+		messages,
+	)
+}
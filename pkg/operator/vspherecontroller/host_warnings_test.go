@@ -0,0 +1,174 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type hostTask func(ctx context.Context, host *object.HostSystem) (*object.Task, error)
+
+var (
+	enterMaintenanceMode hostTask = func(ctx context.Context, host *object.HostSystem) (*object.Task, error) {
+		return host.EnterMaintenanceMode(ctx, 0, false, nil)
+	}
+	exitMaintenanceMode hostTask = func(ctx context.Context, host *object.HostSystem) (*object.Task, error) {
+		return host.ExitMaintenanceMode(ctx, 0)
+	}
+	disconnectHost hostTask = func(ctx context.Context, host *object.HostSystem) (*object.Task, error) {
+		return host.Disconnect(ctx)
+	}
+	reconnectHost hostTask = func(ctx context.Context, host *object.HostSystem) (*object.Task, error) {
+		return host.Reconnect(ctx, nil, nil)
+	}
+)
+
+// runHostTask runs a task on the simulated host of the default nodes.
+func runHostTask(conn *vclib.VSphereConnection, run hostTask) error {
+	host := object.NewHostSystem(conn.Client.Client, types.ManagedObjectReference{Type: "HostSystem", Value: testlib.DefaultHostId})
+	task, err := run(context.TODO(), host)
+	if err != nil {
+		return err
+	}
+	return task.Wait(context.TODO())
+}
+
+func TestHostWarnings(t *testing.T) {
+	tests := []struct {
+		name             string
+		datastore        string
+		hostTask         hostTask
+		resolveTask      hostTask
+		expectedMessages []string
+	}{
+		{
+			name:      "hosts without problems",
+			datastore: "/DC0/datastore/LocalDS_0",
+		},
+		{
+			name:        "host in maintenance mode",
+			datastore:   "/DC0/datastore/LocalDS_0",
+			hostTask:    enterMaintenanceMode,
+			resolveTask: exitMaintenanceMode,
+			expectedMessages: []string{
+				"node DC0_H0_VM0: host DC0_H0 is in maintenance mode",
+				"node DC0_H0_VM1: host DC0_H0 is in maintenance mode",
+			},
+		},
+		{
+			name:        "disconnected host",
+			datastore:   "/DC0/datastore/LocalDS_0",
+			hostTask:    disconnectHost,
+			resolveTask: reconnectHost,
+			expectedMessages: []string{
+				"node DC0_H0_VM0: host DC0_H0 is disconnected",
+				"node DC0_H0_VM1: host DC0_H0 is disconnected",
+			},
+		},
+		{
+			name:      "failure domain datastore is not mounted",
+			datastore: "/DC0/datastore/missing",
+			expectedMessages: []string{
+				"node DC0_H0_VM0: host DC0_H0 does not mount datastore missing",
+				"node DC0_H0_VM1: host DC0_H0 does not mount datastore missing",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := testlib.GetSingleFailureDomainInfra()
+			infra.Spec.PlatformSpec.VSphere.FailureDomains[0].Topology.Datastore = test.datastore
+			nodes := testlib.DefaultNodes()
+			var initialObjects []runtime.Object
+			for _, node := range nodes {
+				initialObjects = append(initialObjects, runtime.Object(node))
+			}
+			commonApiClient := testlib.NewFakeClients(initialObjects, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			go testlib.StartFakeInformer(commonApiClient, stopCh)
+			if err := testlib.AddInitialObjects(initialObjects, commonApiClient); err != nil {
+				t.Fatalf("error adding initial objects: %v", err)
+			}
+			testlib.WaitForSync(commonApiClient, stopCh)
+
+			connections, cleanUpFunc, err := testlib.SetupSimulator(testlib.DefaultModel, infra)
+			if err != nil {
+				t.Fatalf("unexpected error while connecting to simulator: %v", err)
+			}
+			defer cleanUpFunc()
+			if err := testlib.CustomizeHostVersion(testlib.DefaultHostId, "7.0.2"); err != nil {
+				t.Fatalf("error setting host version: %v", err)
+			}
+			if err := setHardwareVersionsFunc(nodes, connections[0], []string{"vmx-15", "vmx-15"})(); err != nil {
+				t.Fatalf("error setting hardware version: %v", err)
+			}
+			if test.hostTask != nil {
+				if err := runHostTask(connections[0], test.hostTask); err != nil {
+					t.Fatalf("error changing host state: %v", err)
+				}
+			}
+
+			ctrl := newVsphereController(commonApiClient)
+			checkHostWarnings := func() *opv1.OperatorCondition {
+				// The simulator does not report changes of host state to property collectors, a new inventory
+				// loads the current state
+				checkOpts := checks.NewCheckArgs(connections, ctrl.getCheckAPIDependency(infra), ctrl.featureGates).WithNodeStatuses(ctrl.nodeStatuses)
+				for _, result := range (&checks.NodeChecker{}).Check(context.TODO(), checkOpts) {
+					if result.Action != checks.CheckActionPass {
+						t.Errorf("expected host problems not to fail the node check, got %s: %s", result.CheckStatus, result.Reason)
+					}
+				}
+				if err := ctrl.syncHostWarnings(context.TODO()); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				_, status, _, err := ctrl.operatorClient.GetOperatorState()
+				if err != nil {
+					t.Fatalf("failed to get operator state: %v", err)
+				}
+				return v1helpers.FindOperatorCondition(status.Conditions, ctrl.getHostWarningConditionName())
+			}
+
+			cond := checkHostWarnings()
+			if len(test.expectedMessages) == 0 {
+				if cond != nil {
+					t.Errorf("expected no host warning condition, got: %s", cond.Message)
+				}
+				return
+			}
+			if cond == nil || cond.Status != opv1.ConditionTrue {
+				t.Fatalf("expected host warning condition to be true, got %+v", cond)
+			}
+			if expected := strings.Join(test.expectedMessages, "\n"); cond.Message != expected {
+				t.Errorf("expected message %q, got %q", expected, cond.Message)
+			}
+			if !hasEvent(ctrl.eventRecorder, hostProblemEvent) {
+				t.Errorf("expected event %s", hostProblemEvent)
+			}
+			if test.resolveTask == nil {
+				return
+			}
+
+			// The host is available again
+			if err := runHostTask(connections[0], test.resolveTask); err != nil {
+				t.Fatalf("error changing host state: %v", err)
+			}
+			if cond := checkHostWarnings(); cond != nil {
+				t.Errorf("expected host warning condition to be removed, got: %s", cond.Message)
+			}
+			if !hasEvent(ctrl.eventRecorder, hostProblemResolvedEvent) {
+				t.Errorf("expected event %s", hostProblemResolvedEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestInventoryUpdates(t *testing.T) {
+	infra := testlib.GetInfraObject()
+	nodes := testlib.DefaultNodes()
+	connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	if connError != nil {
+		t.Fatalf("unexpected error while connecting to simulator: %v", connError)
+	}
+	defer func() {
+		if cleanUpFunc != nil {
+			cleanUpFunc()
+		}
+	}()
+	conn := connections[0]
+
+	getNodeVM := func(inventory *checks.Inventory) *mo.VirtualMachine {
+		t.Helper()
+		uuid := strings.TrimPrefix(nodes[0].Spec.ProviderID, "vsphere://")
+		vm, vCenter, err := inventory.GetVM(uuid)
+		if err != nil {
+			t.Fatalf("unexpected error getting VM with UUID %s: %v", uuid, err)
+		}
+		if vm == nil {
+			t.Fatalf("expected VM with UUID %s in the inventory", uuid)
+		}
+		if vCenter != conn.Hostname {
+			t.Errorf("expected VM in vCenter %s, got %s", conn.Hostname, vCenter)
+		}
+		if vm.Runtime.Host == nil || inventory.GetHost(vCenter, *vm.Runtime.Host) == nil {
+			t.Errorf("expected host of VM %s in the inventory", vm.Name)
+		}
+		return vm
+	}
+
+	inventory := checks.NewInventory()
+	if err := inventory.Sync(context.TODO(), connections); err != nil {
+		t.Fatalf("error loading inventory: %v", err)
+	}
+	vm := getNodeVM(inventory)
+	if vm.Name != nodes[0].Name {
+		t.Errorf("expected VM %s, got %s", nodes[0].Name, vm.Name)
+	}
+
+	if err := testlib.SetHWVersion(conn, nodes[0], "vmx-19"); err != nil {
+		t.Fatalf("error setting hardware version: %v", err)
+	}
+	if vm.Config.Version == "vmx-19" {
+		t.Fatalf("expected VM hardware version to change only after sync")
+	}
+	if err := inventory.Sync(context.TODO(), connections); err != nil {
+		t.Fatalf("error updating inventory: %v", err)
+	}
+	if version := getNodeVM(inventory).Config.Version; version != "vmx-19" {
+		t.Errorf("expected updated hardware version vmx-19, got %s", version)
+	}
+	if vm.Config.Version == "vmx-19" {
+		t.Errorf("expected VM returned before sync not to change")
+	}
+
+	if vm, _, _ := inventory.GetVM("00000000-0000-0000-0000-000000000000"); vm != nil {
+		t.Errorf("expected unknown VM not to be found, got %s", vm.Name)
+	}
+
+	// A VM cloned without changing its UUID is reported instead of picking one of the VMs
+	uuid := strings.TrimPrefix(nodes[0].Spec.ProviderID, "vsphere://")
+	if err := testlib.CustomizeVM(conn, nodes[1], &types.VirtualMachineConfigSpec{Uuid: uuid}); err != nil {
+		t.Fatalf("error setting VM UUID: %v", err)
+	}
+	// vcsim does not send property updates of reconfigured VMs, load the inventory again
+	inventory = checks.NewInventory()
+	if err := inventory.Sync(context.TODO(), connections); err != nil {
+		t.Fatalf("error loading inventory: %v", err)
+	}
+	vm, _, err := inventory.GetVM(uuid)
+	if err == nil || vm != nil {
+		t.Fatalf("expected an error for UUID %s used by two VMs, got VM %v", uuid, vm)
+	}
+	for _, node := range nodes[:2] {
+		if !strings.Contains(err.Error(), node.Name) {
+			t.Errorf("expected error to name VM %s, got %v", node.Name, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	maintenanceStartedEvent = "MaintenanceStarted"
+	maintenanceEndedEvent   = "MaintenanceEnded"
+	maintenanceReason       = "MaintenanceWindow"
+
+	// maintenanceMetricCondition is the condition label of InstallErrorMetric for failures that happened during
+	// a maintenance window.
+	maintenanceMetricCondition = "maintenance"
+)
+
+func (c *VSphereController) getMaintenanceConditionName() string {
+	return c.name + "Maintenance"
+}
+
+// syncMaintenance updates the maintenance condition from the maintenance window in the operator configuration
+// and schedules a sync when the window starts or ends. It returns true when the window is in effect.
+func (c *VSphereController) syncMaintenance(ctx context.Context, syncContext factory.SyncContext, status *operatorapi.OperatorStatus) (bool, error) {
+	window := c.operatorConfig.GetMaintenanceWindow()
+	now := time.Now()
+	active := window.IsActive(now)
+	wasActive := v1helpers.IsOperatorConditionTrue(status.Conditions, c.getMaintenanceConditionName())
+
+	var next time.Time
+	switch {
+	case window == nil:
+	case now.Before(window.Start.Time):
+		next = window.Start.Time
+	case active:
+		next = window.End.Time
+	}
+	if !next.IsZero() && !next.Equal(c.nextMaintenanceSync) {
+		queue := syncContext.Queue()
+		queueKey := syncContext.QueueKey()
+		time.AfterFunc(next.Sub(now), func() {
+			queue.Add(queueKey)
+		})
+		c.nextMaintenanceSync = next
+	}
+
+	if active {
+		end := window.End.UTC().Format(time.RFC3339)
+		if !wasActive {
+			klog.Infof("vCenter maintenance window started, it ends at %s", end)
+			c.eventRecorder.Eventf(maintenanceStartedEvent, "vCenter maintenance window is in effect until %s, failed checks do not degrade the cluster", end)
+		}
+		cond := operatorapi.OperatorCondition{
+			Type:    c.getMaintenanceConditionName(),
+			Status:  operatorapi.ConditionTrue,
+			Reason:  maintenanceReason,
+			Message: fmt.Sprintf("vCenter maintenance window is in effect until %s", end),
+		}
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+		return true, err
+	}
+
+	if wasActive {
+		klog.Infof("vCenter maintenance window ended, resetting exp. backoff")
+		c.vSphereChecker.ResetExpBackoff()
+		utils.ClearInstallErrors(maintenanceMetricCondition)
+		c.eventRecorder.Eventf(maintenanceEndedEvent, "vCenter maintenance window ended")
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *operatorapi.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.getMaintenanceConditionName())
+			return nil
+		})
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,97 @@
+package vspherecontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	nodeExcludedEvent       = "NodeExcludedFromCSIDriver"
+	nodeIncludedEvent       = "NodeIncludedInCSIDriver"
+	nodeNotInitializedEvent = "NodeNotInitialized"
+)
+
+// syncNodeEligibilityLabels sets utils.NodeIneligibleLabel on nodes that do not meet requirements of the CSI driver
+// and removes it from all other nodes. The driver DaemonSet does not run on the labeled nodes.
+func (c *VSphereController) syncNodeEligibilityLabels(ctx context.Context) error {
+	if c.nodeStatuses == nil {
+		return nil
+	}
+	statuses := c.nodeStatuses.Get()
+	if statuses == nil {
+		// nodes were not checked yet
+		return nil
+	}
+
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		status, checked := statuses[node.Name]
+		if !checked {
+			continue
+		}
+		_, labeled := node.Labels[utils.NodeIneligibleLabel]
+		ineligible := status.IneligibleReason != ""
+		if labeled == ineligible {
+			continue
+		}
+
+		var labelValue interface{}
+		if ineligible {
+			labelValue = "true"
+		}
+		// nil value removes the label
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": map[string]interface{}{
+					utils.NodeIneligibleLabel: labelValue,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = c.kubeClient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error updating label %s of node %s: %v", utils.NodeIneligibleLabel, node.Name, err))
+			continue
+		}
+
+		if ineligible {
+			klog.Warningf("Node %s excluded from the CSI driver: %s", node.Name, status.IneligibleReason)
+			c.eventRecorder.Warningf(nodeExcludedEvent, "The CSI driver does not run on node %s: %s", node.Name, status.IneligibleReason)
+		} else {
+			klog.Infof("Node %s meets requirements of the CSI driver again", node.Name)
+			c.eventRecorder.Eventf(nodeIncludedEvent, "Node %s meets requirements of the CSI driver, the driver will run on it", node.Name)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// reportNotInitializedNodes emits an event for each node that was not checked, because it has no providerID yet and
+// its VM was not found in any other way. Each node is reported once, until it's initialized.
+func (c *VSphereController) reportNotInitializedNodes() {
+	if c.nodeStatuses == nil {
+		return
+	}
+	reported := map[string]bool{}
+	for _, nodeName := range c.nodeStatuses.NotInitializedNodes() {
+		reported[nodeName] = true
+		if !c.reportedNotInitializedNodes[nodeName] {
+			c.eventRecorder.Eventf(nodeNotInitializedEvent, "Node %s was not checked: the node is not initialized yet, it has no providerID and its VM was not found by system UUID or name", nodeName)
+		}
+	}
+	c.reportedNotInitializedNodes = reported
+}
@@ -0,0 +1,142 @@
+package vspherecontroller
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNodeVMLookup(t *testing.T) {
+	infra := testlib.GetInfraObject()
+	defaultNodes := testlib.DefaultNodes()
+	vm1Name := defaultNodes[1].Name
+
+	linuxNode := func(name string, modifiers ...func(*corev1.Node)) *corev1.Node {
+		node := testlib.Node(name, modifiers...)
+		node.Labels = map[string]string{"kubernetes.io/os": "linux"}
+		return node
+	}
+	withSystemUUID := func(uuid string) func(*corev1.Node) {
+		return func(node *corev1.Node) {
+			node.Status.NodeInfo.SystemUUID = uuid
+		}
+	}
+	// Guest reports BIOS UUID 39365506-5a0a-5fd0-... of the second VM with the first three fields byte-swapped
+	swappedVM1UUID := "06553639-0a5a-d05f-be10-9586ad53aaad"
+
+	nodes := []*corev1.Node{
+		linuxNode("by-provider-id", testlib.WithProviderID(defaultNodes[0].Spec.ProviderID)),
+		linuxNode("by-system-uuid", testlib.WithProviderID("vsphere:/broken"), withSystemUUID(swappedVM1UUID)),
+		linuxNode(vm1Name),
+		linuxNode("new-node"),
+	}
+	expectedStatuses := map[string]checks.NodeStatus{
+		"by-provider-id": {VMLookup: checks.VMLookupProviderID},
+		"by-system-uuid": {VMLookup: checks.VMLookupSystemUUID},
+		vm1Name:          {VMLookup: checks.VMLookupName},
+		"new-node":       {NotInitialized: true},
+	}
+
+	var initialObjects []runtime.Object
+	for _, node := range nodes {
+		initialObjects = append(initialObjects, runtime.Object(node))
+	}
+	commonApiClient := testlib.NewFakeClients(initialObjects, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go testlib.StartFakeInformer(commonApiClient, stopCh)
+	if err := testlib.AddInitialObjects(initialObjects, commonApiClient); err != nil {
+		t.Fatalf("error adding initial objects: %v", err)
+	}
+	testlib.WaitForSync(commonApiClient, stopCh)
+
+	connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	if connError != nil {
+		t.Fatalf("unexpected error while connecting to simulator: %v", connError)
+	}
+	defer func() {
+		if cleanUpFunc != nil {
+			cleanUpFunc()
+		}
+	}()
+	if err := testlib.CustomizeHostVersion(testlib.DefaultHostId, "7.0.2"); err != nil {
+		t.Fatalf("error setting host version: %v", err)
+	}
+	if err := setHardwareVersionsFunc(defaultNodes, connections[0], []string{"vmx-15", "vmx-15"})(); err != nil {
+		t.Fatalf("error setting hardware version: %v", err)
+	}
+
+	checkerApiClient := &checks.KubeAPIInterfaceImpl{
+		Infrastructure: infra,
+		NodeLister:     commonApiClient.NodeInformer.Lister(),
+	}
+	nodeStatuses := checks.NewNodeStatuses()
+	fg := featuregates.NewFeatureGate([]v1.FeatureGateName{}, []v1.FeatureGateName{})
+	checkOpts := checks.NewCheckArgs(connections, checkerApiClient, fg).WithNodeStatuses(nodeStatuses)
+
+	checker := &checks.NodeChecker{}
+	for _, result := range checker.Check(context.TODO(), checkOpts) {
+		if result.CheckError != nil {
+			t.Fatalf("unexpected node check error: %v", result.CheckError)
+		}
+	}
+
+	statuses := nodeStatuses.Get()
+	for nodeName, expected := range expectedStatuses {
+		status, found := statuses[nodeName]
+		if !found {
+			t.Errorf("expected status of node %s", nodeName)
+			continue
+		}
+		if status.VMLookup != expected.VMLookup || status.NotInitialized != expected.NotInitialized {
+			t.Errorf("expected node %s to have lookup %q and not initialized %v, got %+v",
+				nodeName, expected.VMLookup, expected.NotInitialized, status)
+		}
+	}
+}
+
+func TestNodeVMLookupMissingVM(t *testing.T) {
+	infra := testlib.GetInfraObject()
+	node := testlib.Node("missing", testlib.WithProviderID("vsphere://00000000-0000-0000-0000-000000000000"))
+	node.Labels = map[string]string{"kubernetes.io/os": "linux"}
+
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{node}, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go testlib.StartFakeInformer(commonApiClient, stopCh)
+	if err := testlib.AddInitialObjects([]runtime.Object{node}, commonApiClient); err != nil {
+		t.Fatalf("error adding initial objects: %v", err)
+	}
+	testlib.WaitForSync(commonApiClient, stopCh)
+
+	connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	if connError != nil {
+		t.Fatalf("unexpected error while connecting to simulator: %v", connError)
+	}
+	defer func() {
+		if cleanUpFunc != nil {
+			cleanUpFunc()
+		}
+	}()
+
+	checkerApiClient := &checks.KubeAPIInterfaceImpl{
+		Infrastructure: infra,
+		NodeLister:     commonApiClient.NodeInformer.Lister(),
+	}
+	fg := featuregates.NewFeatureGate([]v1.FeatureGateName{}, []v1.FeatureGateName{})
+	checkOpts := checks.NewCheckArgs(connections, checkerApiClient, fg)
+
+	checker := &checks.NodeChecker{}
+	results := checker.Check(context.TODO(), checkOpts)
+	if len(results) != 1 || results[0].CheckStatus != checks.CheckStatusVcenterAPIError {
+		t.Errorf("expected vCenter API error for node with unknown VM, got %+v", results)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
 	"github.com/openshift/library-go/pkg/operator/v1helpers"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/assets"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
@@ -66,7 +68,9 @@ func (c *VSphereController) removeOperands(ctx context.Context, status *operator
 		"volumesnapshotclass.yaml",
 		"controller.yaml",
 		"node.yaml",
+		"node_windows.yaml",
 		"servicemonitor.yaml",
+		"prometheusrule.yaml",
 		"webhook/deployment.yaml",
 		"vsphere_cloud_config_secret.yaml",
 		"vsphere_features_config.yaml",
@@ -88,6 +92,15 @@ func (c *VSphereController) removeOperands(ctx context.Context, status *operator
 	if err != nil {
 		return fmt.Errorf("error removing storageclass: %v", err)
 	}
+
+	// Checks must run again when the driver is re-enabled
+	for _, name := range []string{checkStateConfigMapName, checkResultsConfigMapName} {
+		err = c.kubeClient.CoreV1().ConfigMaps(c.targetNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error removing ConfigMap %s with check results: %v", name, err)
+		}
+	}
+	c.publishedCheckResults = ""
 	return c.removeConditions(ctx, status)
 }
 
@@ -0,0 +1,98 @@
+package vspherecontroller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// blockingController runs until its context is cancelled
+type blockingController struct {
+	started chan struct{}
+	stopped chan struct{}
+	runs    atomic.Int32
+}
+
+func (b *blockingController) Run(ctx context.Context, workers int) {
+	if b.runs.Add(1) == 1 {
+		close(b.started)
+	}
+	<-ctx.Done()
+	close(b.stopped)
+}
+
+func TestRemoveOperandsOnRemoved(t *testing.T) {
+	removed := func(instance *testlib.FakeDriverInstance) *testlib.FakeDriverInstance {
+		instance.Spec.ManagementState = opv1.Removed
+		return instance
+	}
+	commonApiClient := testlib.NewFakeClients(nil, testlib.MakeFakeDriverInstance(removed), runtime.Object(testlib.GetInfraObject()))
+	commonApiClient.ConfigMapInformer = commonApiClient.KubeInformers.InformersFor(defaultNamespace).Core().V1().ConfigMaps()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go testlib.StartFakeInformer(commonApiClient, stopCh)
+	testlib.WaitForSync(commonApiClient, stopCh)
+
+	ctrl := newVsphereController(commonApiClient)
+	operand := &blockingController{started: make(chan struct{}), stopped: make(chan struct{})}
+	ctrl.controllers = []conditionalController{{name: "operand", controller: operand}}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	syncContext := factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)
+
+	assertOperands := func(step string, installed bool) {
+		t.Helper()
+		if ctrl.operandControllerStarted != installed {
+			t.Errorf("%s: expected operand controllers started to be %v", step, installed)
+		}
+		if ctrl.operandOperatorClient.isOpen() != installed {
+			t.Errorf("%s: expected operand gate open to be %v", step, installed)
+		}
+		select {
+		case <-operand.stopped:
+			t.Errorf("%s: expected operand controller to keep running, it can't be started again", step)
+		default:
+		}
+		if runs := operand.runs.Load(); runs != 1 {
+			t.Errorf("%s: expected operand controller to run once, it ran %d times", step, runs)
+		}
+		if len(ctrl.controllers) != 1 || ctrl.controllers[0].controller != operand {
+			t.Errorf("%s: expected operand controllers not to be replaced", step)
+		}
+	}
+
+	ctrl.currentManagmentState = opv1.Managed
+	ctrl.enableOperandControllers(ctx)
+	select {
+	case <-operand.started:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("operand controller was not started")
+	}
+	assertOperands("managed", true)
+
+	// Managed -> Removed
+	if err := ctrl.sync(ctx, syncContext); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ctrl.currentManagmentState != opv1.Removed {
+		t.Errorf("expected management state %s, got %s", opv1.Removed, ctrl.currentManagmentState)
+	}
+	assertOperands("removed", false)
+
+	// Removed -> Managed, the operands are installed again after the checks passed
+	ctrl.currentManagmentState = opv1.Managed
+	ctrl.enableOperandControllers(ctx)
+	assertOperands("managed again", true)
+
+	// Managed -> Removed again
+	if err := ctrl.sync(ctx, syncContext); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertOperands("removed again", false)
+}
@@ -0,0 +1,80 @@
+package vspherecontroller
+
+import (
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// pendingNodes tracks nodes that need to be checked before the next full cluster check: nodes that were added
+// to the cluster and nodes whose providerID changed. Deleted nodes are pending too, so results of the remaining
+// nodes are evaluated again without them.
+type pendingNodes struct {
+	lock  sync.Mutex
+	nodes sets.Set[string]
+}
+
+func newPendingNodes() *pendingNodes {
+	return &pendingNodes{
+		nodes: sets.New[string](),
+	}
+}
+
+// eventHandler returns a Node informer event handler that marks nodes as pending and calls enqueue. remove is
+// called with the name of a deleted node before it's marked pending, to forget everything known about it. Other
+// node changes, e.g. status updates, are ignored and do not trigger a sync.
+func (p *pendingNodes) eventHandler(enqueue func(), remove func(nodeName string)) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			node, ok := obj.(*v1.Node)
+			if !ok || node.Spec.ProviderID == "" {
+				// The node is not initialized yet, it is checked once the providerID is set
+				return
+			}
+			p.add(node.Name)
+			enqueue()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok := oldObj.(*v1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := newObj.(*v1.Node)
+			if !ok || newNode.Spec.ProviderID == "" || newNode.Spec.ProviderID == oldNode.Spec.ProviderID {
+				return
+			}
+			p.add(newNode.Name)
+			enqueue()
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			node, ok := obj.(*v1.Node)
+			if !ok {
+				return
+			}
+			remove(node.Name)
+			p.add(node.Name)
+			enqueue()
+		},
+	}
+}
+
+// take returns sorted names of pending nodes and clears them.
+func (p *pendingNodes) take() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	nodes := sets.List(p.nodes)
+	p.nodes = sets.New[string]()
+	return nodes
+}
+
+// add marks nodes as pending, e.g. when they could not be checked yet.
+func (p *pendingNodes) add(nodes ...string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.nodes.Insert(nodes...)
+}
@@ -0,0 +1,146 @@
+package vspherecontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPendingNodesEventHandler(t *testing.T) {
+	pending := newPendingNodes()
+	enqueued := 0
+	var removed []string
+	handler := pending.eventHandler(func() {
+		enqueued++
+	}, func(nodeName string) {
+		removed = append(removed, nodeName)
+	})
+
+	withoutID := testlib.Node("node-1")
+	withID := testlib.Node("node-1", testlib.WithProviderID("vsphere://uuid-1"))
+	relabeled := withID.DeepCopy()
+	relabeled.Labels = map[string]string{"foo": "bar"}
+	changedID := testlib.Node("node-1", testlib.WithProviderID("vsphere://uuid-2"))
+
+	handler.OnAdd(withoutID, false)
+	if enqueued != 0 {
+		t.Errorf("expected node without providerID to be ignored")
+	}
+	handler.OnUpdate(withoutID, withID)
+	handler.OnUpdate(withID, relabeled)
+	handler.OnAdd(testlib.Node("node-2", testlib.WithProviderID("vsphere://uuid-3")), false)
+	if enqueued != 2 {
+		t.Errorf("expected 2 syncs, got %d", enqueued)
+	}
+	if nodes := pending.take(); !reflect.DeepEqual(nodes, []string{"node-1", "node-2"}) {
+		t.Errorf("expected pending nodes node-1 and node-2, got %v", nodes)
+	}
+
+	handler.OnUpdate(relabeled, changedID)
+	if nodes := pending.take(); !reflect.DeepEqual(nodes, []string{"node-1"}) {
+		t.Errorf("expected node-1 to be pending after providerID change, got %v", nodes)
+	}
+	if nodes := pending.take(); len(nodes) != 0 {
+		t.Errorf("expected no pending nodes, got %v", nodes)
+	}
+
+	handler.OnDelete(changedID)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "node-2", Obj: testlib.Node("node-2")})
+	if !reflect.DeepEqual(removed, []string{"node-1", "node-2"}) {
+		t.Errorf("expected deleted nodes node-1 and node-2 to be removed, got %v", removed)
+	}
+	if nodes := pending.take(); !reflect.DeepEqual(nodes, []string{"node-1", "node-2"}) {
+		t.Errorf("expected deleted nodes to be pending, got %v", nodes)
+	}
+}
+
+func TestCheckNewNodes(t *testing.T) {
+	infra := testlib.GetInfraObject()
+	nodes := testlib.DefaultNodes()
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{nodes[0]}, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go testlib.StartFakeInformer(commonApiClient, stopCh)
+	if err := testlib.AddInitialObjects([]runtime.Object{nodes[0]}, commonApiClient); err != nil {
+		t.Fatalf("error adding initial objects: %v", err)
+	}
+	testlib.WaitForSync(commonApiClient, stopCh)
+
+	connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	if connError != nil {
+		t.Fatalf("unexpected error while connecting to simulator: %v", connError)
+	}
+	defer func() {
+		if cleanUpFunc != nil {
+			cleanUpFunc()
+		}
+	}()
+	testlib.CustomizeVCenterVersion("7.0.2", "7.0.2", connections[0])
+	if err := testlib.CustomizeHostVersion(testlib.DefaultHostId, "7.0.2"); err != nil {
+		t.Fatalf("error setting host version: %v", err)
+	}
+	if err := setHardwareVersionsFunc(nodes, connections[0], []string{"vmx-15", "vmx-13"})(); err != nil {
+		t.Fatalf("error setting hardware version: %v", err)
+	}
+
+	kubeInformers := commonApiClient.KubeInformers.InformersFor("")
+	checkerApiClient := &checks.KubeAPIInterfaceImpl{
+		Infrastructure:  infra,
+		NodeLister:      commonApiClient.NodeInformer.Lister(),
+		CSINodeLister:   kubeInformers.Storage().V1().CSINodes().Lister(),
+		CSIDriverLister: kubeInformers.Storage().V1().CSIDrivers().Lister(),
+	}
+	nodeStatuses := checks.NewNodeStatuses()
+	fg := featuregates.NewFeatureGate([]v1.FeatureGateName{}, []v1.FeatureGateName{})
+	checkOpts := checks.NewCheckArgs(connections, checkerApiClient, fg).WithNodeStatuses(nodeStatuses)
+
+	checker := newVSphereEnvironmentChecker()
+	if _, _, checkRan := checker.CheckNodes(context.TODO(), checkOpts, []string{nodes[0].Name}); checkRan {
+		t.Fatalf("expected nodes not to be checked before the full check")
+	}
+	_, result, checkRan := checker.Check(context.TODO(), checkOpts)
+	if !checkRan || result.Action != checks.CheckActionPass {
+		t.Fatalf("expected full check to pass, got %+v", result)
+	}
+	nextCheck := checker.nextCheck
+
+	// The second node joins the cluster with an old hardware version
+	if err := testlib.AddInitialObjects([]runtime.Object{nodes[1]}, commonApiClient); err != nil {
+		t.Fatalf("error adding node: %v", err)
+	}
+	_, result, checkRan = checker.CheckNodes(context.TODO(), checkOpts, []string{nodes[1].Name})
+	if !checkRan {
+		t.Fatalf("expected new node to be checked")
+	}
+	if result.Action != checks.CheckActionBlockUpgrade || result.CheckStatus != checks.CheckStatusDeprecatedHWVersion {
+		t.Errorf("expected new node to block upgrade, got %+v", result)
+	}
+	statuses := nodeStatuses.Get()
+	if len(statuses) != 2 || statuses[nodes[1].Name].IneligibleReason == "" {
+		t.Errorf("expected statuses of both nodes with the new node ineligible, got %+v", statuses)
+	}
+	if !checker.nextCheck.Before(nextCheck) {
+		t.Errorf("expected full check to be scheduled sooner than %s, got %s", nextCheck, checker.nextCheck)
+	}
+
+	// The node with the old hardware version is deleted
+	if err := commonApiClient.NodeInformer.Informer().GetIndexer().Delete(nodes[1]); err != nil {
+		t.Fatalf("error deleting node: %v", err)
+	}
+	nodeStatuses.Remove(nodes[1].Name)
+	_, result, checkRan = checker.CheckNodes(context.TODO(), checkOpts, []string{nodes[1].Name})
+	if !checkRan || result.Action != checks.CheckActionPass {
+		t.Errorf("expected check to pass without the deleted node, got %+v", result)
+	}
+	if statuses := nodeStatuses.Get(); len(statuses) != 1 || nodeStatuses.IneligibleNodes() != nil {
+		t.Errorf("expected only the status of the remaining node, got %+v", statuses)
+	}
+}
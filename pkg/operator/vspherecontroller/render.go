@@ -0,0 +1,217 @@
+package vspherecontroller
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	ocpv1 "github.com/openshift/api/config/v1"
+	operatorapi "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	cfginformers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/config/leaderelection"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivercontrollerservicecontroller"
+	"github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/loglevel"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/assets"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/storageclasscontroller"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corelister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RenderInputs are the cluster objects needed to render the CSI driver operands without a cluster.
+type RenderInputs struct {
+	Infrastructure   *ocpv1.Infrastructure
+	ClusterCSIDriver *operatorapi.ClusterCSIDriver
+	// CloudConfig is the cloud provider ConfigMap referenced by the Infrastructure
+	CloudConfig *corev1.ConfigMap
+	// Credentials is the Secret with vCenter credentials of the CSI driver
+	Credentials *corev1.Secret
+	// OperatorConfig is optional
+	OperatorConfig *utils.OperatorConfig
+	FeatureGates   featuregates.FeatureGate
+}
+
+// RenderOperands renders cloud.conf of the CSI driver, the controller Deployment, the node DaemonSet, the feature
+// ConfigMap and the StorageClass without contacting any cluster or vCenter. The migration datastore URL in
+// cloud.conf is left empty, it is read from vCenter, and the default storage policy name is used unless the
+// operator config sets another one.
+func RenderOperands(inputs RenderInputs) ([]runtime.Object, error) {
+	infra := inputs.Infrastructure
+	if infra.Spec.PlatformSpec.VSphere == nil {
+		return nil, fmt.Errorf("infrastructure %s has no vSphere platform spec", infra.Name)
+	}
+	clusterCSIDriver := inputs.ClusterCSIDriver
+	opSpec := &clusterCSIDriver.Spec.OperatorSpec
+
+	c, err := newOfflineController(infra, inputs.CloudConfig, inputs.Credentials, inputs.FeatureGates)
+	if err != nil {
+		return nil, err
+	}
+	configInformers := cfginformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(infra), 0)
+	if err := configInformers.Config().V1().Infrastructures().Informer().GetIndexer().Add(infra); err != nil {
+		return nil, err
+	}
+
+	vSphereConfig, err := c.loadCloudConfig(infra)
+	if err != nil {
+		return nil, err
+	}
+	driverConfig, err := c.applyClusterCSIDriverChange(infra, vSphereConfig, clusterCSIDriver, "")
+	if err != nil {
+		return nil, fmt.Errorf("error rendering cloud.conf: %v", err)
+	}
+
+	deployment, err := renderControllerDeployment(opSpec, configInformers, clusterCSIDriver, infra, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering Deployment: %v", err)
+	}
+	daemonSet, err := renderNodeDaemonSet(opSpec, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering DaemonSet: %v", err)
+	}
+
+	featureManifest, err := assets.ReadFile("vsphere_features_config.yaml")
+	if err != nil {
+		return nil, err
+	}
+	scManifest, err := assets.ReadFile("storageclass.yaml")
+	if err != nil {
+		return nil, err
+	}
+	return []runtime.Object{
+		driverConfig,
+		deployment,
+		daemonSet,
+		// Node VMs are not known offline, the driver starts with the default volume limit
+		utils.RenderFeatureConfigMap(featureManifest, clusterCSIDriver, infra, false),
+		storageclasscontroller.RenderStorageClass(scManifest, infra, inputs.OperatorConfig),
+	}, nil
+}
+
+// newOfflineController returns a controller that reads the cloud config and the credentials from the given objects
+// instead of informers, so its methods can be used without a cluster.
+func newOfflineController(
+	infra *ocpv1.Infrastructure,
+	cloudConfig *corev1.ConfigMap,
+	credentials *corev1.Secret,
+	featureGates featuregates.FeatureGate) (*VSphereController, error) {
+
+	// The operator finds the objects by name, use the names it expects
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	cloudConfig = cloudConfig.DeepCopy()
+	cloudConfig.Namespace, cloudConfig.Name = cloudConfigNamespace, infra.Spec.CloudConfig.Name
+	if err := configMapIndexer.Add(cloudConfig); err != nil {
+		return nil, err
+	}
+	secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	credentials = credentials.DeepCopy()
+	credentials.Namespace, credentials.Name = defaultNamespace, cloudCredSecretName
+	if err := secretIndexer.Add(credentials); err != nil {
+		return nil, err
+	}
+
+	csiConfigManifest, err := assets.ReadFile("csi_cloud_config.ini")
+	if err != nil {
+		return nil, err
+	}
+	secretManifest, err := assets.ReadFile("vsphere_cloud_config_secret.yaml")
+	if err != nil {
+		return nil, err
+	}
+	return &VSphereController{
+		name:              "vsphere-offline",
+		targetNamespace:   defaultNamespace,
+		configMapLister:   corelister.NewConfigMapLister(configMapIndexer),
+		secretLister:      corelister.NewSecretLister(secretIndexer),
+		csiConfigManifest: csiConfigManifest,
+		secretManifest:    secretManifest,
+		featureGates:      featureGates,
+	}, nil
+}
+
+// Environment variables with images of the node DaemonSet, the same as used by csidrivernodeservicecontroller.
+var nodeImageEnvNames = map[string]string{
+	"${DRIVER_IMAGE}":                "DRIVER_IMAGE",
+	"${NODE_DRIVER_REGISTRAR_IMAGE}": "NODE_DRIVER_REGISTRAR_IMAGE",
+	"${LIVENESS_PROBE_IMAGE}":        "LIVENESS_PROBE_IMAGE",
+	"${KUBE_RBAC_PROXY_IMAGE}":       "KUBE_RBAC_PROXY_IMAGE",
+}
+
+// renderControllerDeployment renders the CSI driver controller Deployment with the same manifest and Deployment
+// hooks as the controller service controller created in createCSIDriver. Hooks that only add volumes and
+// annotations with hashes of other objects in the cluster are skipped.
+func renderControllerDeployment(
+	opSpec *operatorapi.OperatorSpec,
+	configInformers cfginformers.SharedInformerFactory,
+	clusterCSIDriver *operatorapi.ClusterCSIDriver,
+	infra *ocpv1.Infrastructure,
+	nodeStatuses *checks.NodeStatuses) (*appsv1.Deployment, error) {
+
+	manifest, err := assets.ReadFile("controller.yaml")
+	if err != nil {
+		return nil, err
+	}
+	manifestHooks := []deploymentcontroller.ManifestHookFunc{
+		csidrivercontrollerservicecontroller.WithPlaceholdersHook(configInformers),
+		csidrivercontrollerservicecontroller.WithServingInfo(),
+		csidrivercontrollerservicecontroller.WithLeaderElectionReplacerHook(
+			leaderelection.LeaderElectionDefaulting(ocpv1.LeaderElection{}, "default", "default"),
+		),
+	}
+	for i, hook := range manifestHooks {
+		manifest, err = hook(opSpec, manifest)
+		if err != nil {
+			return nil, fmt.Errorf("error running manifest hook (index=%d): %w", i, err)
+		}
+	}
+	deployment := resourceread.ReadDeploymentV1OrDie(manifest)
+
+	topologyHook := func(_ *operatorapi.OperatorSpec, deployment *appsv1.Deployment) error {
+		deployment.Spec.Template.Spec.Containers = setTopologyArgs(deployment.Spec.Template.Spec.Containers, clusterCSIDriver, infra)
+		return nil
+	}
+	hooks := append(
+		controllerDeploymentHooks(configInformers, topologyHook, nodeStatuses),
+		csidrivercontrollerservicecontroller.WithControlPlaneTopologyHook(configInformers),
+	)
+	for i, hook := range hooks {
+		if err := hook(opSpec, deployment); err != nil {
+			return nil, fmt.Errorf("error running hook function (index=%d): %w", i, err)
+		}
+	}
+	return deployment, nil
+}
+
+// renderNodeDaemonSet renders the CSI driver node DaemonSet with the same manifest and DaemonSet hooks as the node
+// service controller created in createCSIDriver. Hooks that only add volumes and annotations with hashes of other
+// objects in the cluster are skipped.
+func renderNodeDaemonSet(opSpec *operatorapi.OperatorSpec, nodeStatuses *checks.NodeStatuses) (*appsv1.DaemonSet, error) {
+	manifest, err := assets.ReadFile("node.yaml")
+	if err != nil {
+		return nil, err
+	}
+	pairs := []string{"${LOG_LEVEL}", strconv.Itoa(loglevel.LogLevelToVerbosity(opSpec.LogLevel))}
+	for placeholder, envName := range nodeImageEnvNames {
+		if image := os.Getenv(envName); image != "" {
+			pairs = append(pairs, placeholder, image)
+		}
+	}
+	daemonSet := resourceread.ReadDaemonSetV1OrDie([]byte(strings.NewReplacer(pairs...).Replace(string(manifest))))
+
+	hooks := nodeDaemonSetHooks(nodeStatuses)
+	for i, hook := range hooks {
+		if err := hook(opSpec, daemonSet); err != nil {
+			return nil, fmt.Errorf("error running hook function (index=%d): %w", i, err)
+		}
+	}
+	return daemonSet, nil
+}
@@ -0,0 +1,70 @@
+package vspherecontroller
+
+import (
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestRenderOperands(t *testing.T) {
+	clusterCSIDriver := testlib.GetClusterCSIDriver(false)
+	clusterCSIDriver.Spec.LogLevel = opv1.Debug
+	credentials := testlib.GetSecret()
+	credentials.Name = "credentials-from-file"
+
+	objects, err := RenderOperands(RenderInputs{
+		Infrastructure:   testlib.GetInfraObject(),
+		ClusterCSIDriver: clusterCSIDriver,
+		CloudConfig:      testlib.GetNewConfigMap(),
+		Credentials:      credentials,
+		FeatureGates:     featuregates.NewFeatureGate(nil, []configv1.FeatureGateName{"VSphereMultiVCenters"}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(objects) != 5 {
+		t.Fatalf("expected 5 objects, got %d", len(objects))
+	}
+
+	secret := objects[0].(*v1.Secret)
+	cloudConf := string(secret.Data["cloud.conf"])
+	for _, expected := range []string{`cluster-id = "vsphere"`, `[VirtualCenter "localhost"]`, `user                    = "vsphere-user"`, `datacenters             = DC0`} {
+		if !strings.Contains(cloudConf, expected) {
+			t.Errorf("expected cloud.conf to contain %q, got:\n%s", expected, cloudConf)
+		}
+	}
+
+	deployment := objects[1].(*appsv1.Deployment)
+	daemonSet := objects[2].(*appsv1.DaemonSet)
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "csi-provisioner" && !sets.New(container.Args...).Has("--feature-gates=Topology=false") {
+			t.Errorf("expected topology to be disabled in csi-provisioner, got args %v", container.Args)
+		}
+	}
+	for _, containers := range [][]v1.Container{deployment.Spec.Template.Spec.Containers, daemonSet.Spec.Template.Spec.Containers} {
+		for _, container := range containers {
+			if container.Name != driverContainerName {
+				continue
+			}
+			if value := envValues(container.Env, nil)["X_CSI_DEBUG"]; value != "true" {
+				t.Errorf("expected debug logging of the CSI driver, got X_CSI_DEBUG=%q", value)
+			}
+		}
+	}
+
+	if featureConfigMap := objects[3].(*v1.ConfigMap); featureConfigMap.Name != "internal-feature-states.csi.vsphere.vmware.com" {
+		t.Errorf("unexpected feature ConfigMap %s", featureConfigMap.Name)
+	}
+	storageClass := objects[4].(*storagev1.StorageClass)
+	if policy := storageClass.Parameters["StoragePolicyName"]; policy != "openshift-storage-policy-vsphere" {
+		t.Errorf("expected default storage policy, got %q", policy)
+	}
+}
@@ -0,0 +1,285 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ocpv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultVCenterOutageGracePeriod is how long one of several vCenters may be unreachable before the operator
+	// degrades.
+	defaultVCenterOutageGracePeriod = time.Hour
+	// vCenterConnectTimeout limits login to a single vCenter, vCenters are connected in parallel.
+	vCenterConnectTimeout = 10 * time.Minute
+)
+
+var (
+	// topologyZoneKeys and topologyRegionKeys are node affinity keys of volumes provisioned in a zone and region,
+	// by the CSI driver with the default topology categories or with the well-known topology labels.
+	topologyZoneKeys   = sets.New[string](v1.LabelTopologyZone, v1.LabelFailureDomainBetaZone, "topology.csi.vmware.com/openshift-zone")
+	topologyRegionKeys = sets.New[string](v1.LabelTopologyRegion, v1.LabelFailureDomainBetaRegion, "topology.csi.vmware.com/openshift-region")
+)
+
+// connectVCenters connects to all vCenters in parallel and checks their CNS service. When only some of them can't
+// be reached or their CNS service is not available, connections to the others are kept in c.vSphereConnections,
+// so the operator keeps serving them, and the returned result lists the affected failure domains.
+func (c *VSphereController) connectVCenters(ctx context.Context, infra *ocpv1.Infrastructure) checks.ClusterCheckResult {
+	var connected []*vclib.VSphereConnection
+	var firstErr error
+	loginFailed := map[string]error{}
+	total := len(c.vSphereConnections)
+	errs := vclib.ForEachConnection(ctx, c.vSphereConnections, vCenterConnectTimeout, func(ctx context.Context, _ int, vConn *vclib.VSphereConnection) error {
+		return vConn.Connect(ctx)
+	})
+	for i, vConn := range c.vSphereConnections {
+		if err := errs[i]; err != nil {
+			klog.Errorf("error connecting to vCenter %s: %v", vConn.Hostname, err)
+			loginFailed[vConn.Hostname] = err
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		connected = append(connected, vConn)
+	}
+	// vCenter accepts logins also when its CNS service is down, volumes can't be provisioned or attached there
+	cnsFailed := map[string]error{}
+	for server, err := range c.checkCNSHealth(ctx, connected) {
+		cnsFailed[server] = fmt.Errorf("CNS service is not available: %v", err)
+	}
+	failed := map[string]error{}
+	for server, err := range loginFailed {
+		failed[server] = err
+	}
+	for server, err := range cnsFailed {
+		failed[server] = err
+	}
+	if c.updateVCenterOutages(failed) && c.checkStateRestored {
+		// Don't restart the grace period when the operator restarts
+		if err := c.saveCheckState(ctx); err != nil {
+			klog.Errorf("error saving check state, a restarted operator will restart the vCenter outage grace period: %v", err)
+		}
+	}
+	c.unavailableZones = nil
+
+	if len(failed) == 0 {
+		return checks.MakeClusterCheckResultPass()
+	}
+	if len(connected) == 0 {
+		return checks.ClusterCheckResult{
+			CheckError:  firstErr,
+			Action:      checks.CheckActionBlockUpgradeOrDegrade,
+			CheckStatus: checks.CheckStatusVSphereConnectionFailed,
+			Reason:      fmt.Sprintf("Failed to connect to vSphere: %v", firstErr),
+		}
+	}
+	allFailed := len(failed) == total
+	if !allFailed {
+		c.vSphereConnections = connected
+	}
+	// Report login and CNS failures separately, a CNS outage is never a connection failure
+	var result checks.ClusterCheckResult
+	for _, group := range []struct {
+		status checks.CheckStatusType
+		failed map[string]error
+	}{
+		{checks.CheckStatusVSphereConnectionFailed, loginFailed},
+		{checks.CheckStatusCNSUnavailable, cnsFailed},
+	} {
+		if len(group.failed) == 0 {
+			continue
+		}
+		var groupResult checks.ClusterCheckResult
+		if allFailed {
+			// No vCenter can serve volumes, there's nothing to wait for
+			groupResult = makeVCenterFailureResult(group.status, group.failed, total)
+		} else {
+			groupResult = c.makePartialOutageResult(ctx, infra, group.status, group.failed, total)
+		}
+		if groupResult.Action > result.Action {
+			result = groupResult
+		}
+	}
+	return result
+}
+
+// makeVCenterFailureResult blocks upgrades or degrades the cluster when all vCenters failed.
+func makeVCenterFailureResult(status checks.CheckStatusType, failed map[string]error, total int) checks.ClusterCheckResult {
+	servers := sets.List(sets.KeySet(failed))
+	descriptions := make([]string, 0, len(servers))
+	for _, server := range servers {
+		descriptions = append(descriptions, fmt.Sprintf("%s: %v", server, failed[server]))
+	}
+	reason := fmt.Errorf("%d of %d vCenters are not available: %s", len(failed), total, strings.Join(descriptions, "; "))
+	result := checks.ClusterCheckResult{
+		CheckError:  reason,
+		Action:      checks.CheckActionBlockUpgradeOrDegrade,
+		CheckStatus: status,
+		Reason:      reason.Error(),
+	}
+	if len(servers) == 1 {
+		result = result.WithObject(checks.ObjectKindVCenter, servers[0])
+	}
+	return result
+}
+
+// updateVCenterOutages records when each of the failed vCenters became unreachable or its CNS service stopped
+// responding. It returns true when an outage started or ended.
+func (c *VSphereController) updateVCenterOutages(failed map[string]error) bool {
+	if c.vCenterOutages == nil {
+		c.vCenterOutages = map[string]time.Time{}
+	}
+	changed := false
+	for server := range c.vCenterOutages {
+		if _, found := failed[server]; !found {
+			klog.Infof("vCenter %s is reachable again", server)
+			delete(c.vCenterOutages, server)
+			changed = true
+		}
+	}
+	for server := range failed {
+		if _, found := c.vCenterOutages[server]; !found {
+			c.vCenterOutages[server] = time.Now()
+			changed = true
+		}
+	}
+	return changed
+}
+
+// makePartialOutageResult blocks upgrades while some vCenters are not available. It degrades the cluster when
+// a vCenter with volumes of the CSI driver has not been available for longer than the grace period.
+func (c *VSphereController) makePartialOutageResult(ctx context.Context, infra *ocpv1.Infrastructure, status checks.CheckStatusType, failed map[string]error, total int) checks.ClusterCheckResult {
+	gracePeriod := c.operatorConfig.GetVCenterOutageGracePeriod(defaultVCenterOutageGracePeriod)
+	servers := make([]string, 0, len(failed))
+	for server := range failed {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	degrade := false
+	var descriptions []string
+	for _, server := range servers {
+		domains := getVCenterFailureDomains(infra, server)
+		var domainNames []string
+		for _, domain := range domains {
+			domainNames = append(domainNames, domain.Name)
+			if domain.Zone != "" {
+				c.unavailableZones = append(c.unavailableZones, domain.Zone)
+			}
+		}
+		if len(domainNames) == 0 {
+			domainNames = []string{"none"}
+		}
+		since := c.vCenterOutages[server]
+		descriptions = append(descriptions, fmt.Sprintf("%s (failure domains %s, not available since %s): %v",
+			server, strings.Join(domainNames, ", "), since.UTC().Format(time.RFC3339), failed[server]))
+
+		if time.Since(since) < gracePeriod {
+			continue
+		}
+		hasVolumes, err := c.vCenterHasVolumes(ctx, domains)
+		if err != nil {
+			// Assume the worst, the vCenter is not available for too long anyway
+			klog.Errorf("error checking volumes in vCenter %s: %v", server, err)
+			hasVolumes = true
+		}
+		if hasVolumes {
+			degrade = true
+		}
+	}
+
+	reason := fmt.Errorf("%d of %d vCenters are not available: %s", len(failed), total, strings.Join(descriptions, "; "))
+	var result checks.ClusterCheckResult
+	if degrade {
+		reason = fmt.Errorf("%v; a vCenter with volumes of the CSI driver has not been available for more than %s", reason, gracePeriod)
+		result = checks.MakeClusterDegradedError(status, reason)
+	} else {
+		result = checks.MakeClusterUnupgradeableError(status, reason)
+	}
+	if len(servers) == 1 {
+		result = result.WithObject(checks.ObjectKindVCenter, servers[0])
+	}
+	return result
+}
+
+// getVCenterFailureDomains returns failure domains of the given vCenter.
+func getVCenterFailureDomains(infra *ocpv1.Infrastructure, server string) []ocpv1.VSpherePlatformFailureDomainSpec {
+	if infra.Spec.PlatformSpec.VSphere == nil {
+		return nil
+	}
+	var domains []ocpv1.VSpherePlatformFailureDomainSpec
+	for _, domain := range infra.Spec.PlatformSpec.VSphere.FailureDomains {
+		if domain.Server == server {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// vCenterHasVolumes returns true when there is a volume of the CSI driver in the given failure domains. Volumes
+// without topology can be anywhere, they are counted in all failure domains.
+func (c *VSphereController) vCenterHasVolumes(ctx context.Context, domains []ocpv1.VSpherePlatformFailureDomainSpec) (bool, error) {
+	if c.kubeClient == nil {
+		// The offline controller of the diagnose command has no cluster to list volumes from, assume the worst
+		return true, nil
+	}
+	pvs, err := c.kubeClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+	var zonalDomains []ocpv1.VSpherePlatformFailureDomainSpec
+	for _, domain := range domains {
+		if domain.Zone != "" {
+			zonalDomains = append(zonalDomains, domain)
+		}
+	}
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != utils.VSphereDriverName {
+			continue
+		}
+		if len(zonalDomains) == 0 || pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+			return true, nil
+		}
+		for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+			if termMatchesFailureDomains(term, zonalDomains) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// termMatchesFailureDomains returns true when a node in one of the failure domains can match the node selector term
+// of a volume. Only zone and region keys of the term are evaluated, a term without them matches any failure domain.
+func termMatchesFailureDomains(term v1.NodeSelectorTerm, domains []ocpv1.VSpherePlatformFailureDomainSpec) bool {
+	for _, domain := range domains {
+		matches := true
+		for _, expr := range term.MatchExpressions {
+			if expr.Operator != v1.NodeSelectorOpIn {
+				continue
+			}
+			switch {
+			case topologyZoneKeys.Has(expr.Key):
+				matches = matches && sets.New[string](expr.Values...).Has(domain.Zone)
+			case topologyRegionKeys.Has(expr.Key):
+				matches = matches && sets.New[string](expr.Values...).Has(domain.Region)
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
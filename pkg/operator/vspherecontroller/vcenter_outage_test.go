@@ -0,0 +1,144 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func makeZonalPV(name, zone string) *v1.PersistentVolume {
+	return makePVWithAffinity(name, "topology.csi.vmware.com/openshift-zone", zone)
+}
+
+func makePVWithAffinity(name, key, value string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: utils.VSphereDriverName, VolumeHandle: name},
+			},
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{
+						MatchExpressions: []v1.NodeSelectorRequirement{{
+							Key:      key,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{value},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func withAffinity(pv *v1.PersistentVolume, key, value string) *v1.PersistentVolume {
+	term := &pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0]
+	term.MatchExpressions = append(term.MatchExpressions, v1.NodeSelectorRequirement{
+		Key:      key,
+		Operator: v1.NodeSelectorOpIn,
+		Values:   []string{value},
+	})
+	return pv
+}
+
+func TestPartialVCenterOutage(t *testing.T) {
+	tests := []struct {
+		name             string
+		pvs              []runtime.Object
+		unreachableSince time.Duration
+		expectedAction   checks.CheckAction
+	}{
+		{
+			name:             "outage within the grace period blocks upgrades",
+			pvs:              []runtime.Object{makeZonalPV("pv1", "us-west-1a")},
+			unreachableSince: 10 * time.Minute,
+			expectedAction:   checks.CheckActionBlockUpgrade,
+		},
+		{
+			name:             "long outage of a vCenter with volumes degrades the cluster",
+			pvs:              []runtime.Object{makeZonalPV("pv1", "us-west-1a")},
+			unreachableSince: 2 * time.Hour,
+			expectedAction:   checks.CheckActionDegrade,
+		},
+		{
+			name:             "long outage of a vCenter without volumes blocks upgrades",
+			pvs:              []runtime.Object{makeZonalPV("pv1", "us-east-1a")},
+			unreachableSince: 2 * time.Hour,
+			expectedAction:   checks.CheckActionBlockUpgrade,
+		},
+		{
+			name:             "long outage of a vCenter with volumes in the well-known zone degrades the cluster",
+			pvs:              []runtime.Object{makePVWithAffinity("pv1", v1.LabelTopologyZone, "us-west-1a")},
+			unreachableSince: 2 * time.Hour,
+			expectedAction:   checks.CheckActionDegrade,
+		},
+		{
+			name:             "non-topology affinity of a volume matching the zone name is ignored",
+			pvs:              []runtime.Object{withAffinity(makeZonalPV("pv1", "us-east-1a"), "example.com/rack", "us-west-1a")},
+			unreachableSince: 2 * time.Hour,
+			expectedAction:   checks.CheckActionBlockUpgrade,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := testlib.GetZonalMultiVCenterInfra()
+			commonApiClient := testlib.NewFakeClients(test.pvs, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+			ctrl := newVsphereController(commonApiClient)
+			ctrl.vCenterOutages = map[string]time.Time{"vcenter2.lan": time.Now().Add(-test.unreachableSince)}
+
+			failed := map[string]error{"vcenter2.lan": fmt.Errorf("connection refused")}
+			result := ctrl.makePartialOutageResult(context.TODO(), infra, checks.CheckStatusVSphereConnectionFailed, failed, 2)
+			if result.Action != test.expectedAction {
+				t.Errorf("expected action %s, got %s: %s", checks.ActionToString(test.expectedAction), checks.ActionToString(result.Action), result.Reason)
+			}
+			if result.CheckStatus != checks.CheckStatusVSphereConnectionFailed {
+				t.Errorf("expected status %s, got %s", checks.CheckStatusVSphereConnectionFailed, result.CheckStatus)
+			}
+			if !strings.Contains(result.Reason, "us-west-1") || strings.Contains(result.Reason, "us-east-1") {
+				t.Errorf("expected only failure domain us-west-1 to be reported, got: %s", result.Reason)
+			}
+			if result.Object.Name != "vcenter2.lan" {
+				t.Errorf("expected vCenter vcenter2.lan to be reported, got %q", result.Object.Name)
+			}
+			if !reflect.DeepEqual(ctrl.unavailableZones, []string{"us-west-1a"}) {
+				t.Errorf("expected nodes in zone us-west-1a not to be checked, got %v", ctrl.unavailableZones)
+			}
+		})
+	}
+}
+
+func TestUpdateVCenterOutages(t *testing.T) {
+	ctrl := &VSphereController{}
+	if !ctrl.updateVCenterOutages(map[string]error{"vcenter2.lan": fmt.Errorf("connection refused")}) {
+		t.Errorf("expected the started outage to be reported as a change")
+	}
+	since, found := ctrl.vCenterOutages["vcenter2.lan"]
+	if !found {
+		t.Fatalf("expected outage of vcenter2.lan to be recorded")
+	}
+
+	// The outage start is kept while the vCenter is unreachable
+	if ctrl.updateVCenterOutages(map[string]error{"vcenter2.lan": fmt.Errorf("connection refused")}) {
+		t.Errorf("expected the ongoing outage not to be reported as a change")
+	}
+	if !ctrl.vCenterOutages["vcenter2.lan"].Equal(since) {
+		t.Errorf("expected outage start %s to be kept, got %s", since, ctrl.vCenterOutages["vcenter2.lan"])
+	}
+
+	ctrl.updateVCenterOutages(map[string]error{})
+	if len(ctrl.vCenterOutages) != 0 {
+		t.Errorf("expected no outages after vCenter is reachable again, got %v", ctrl.vCenterOutages)
+	}
+}
@@ -0,0 +1,46 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+const (
+	vCenterProblemEvent         = "VCenterProblem"
+	vCenterProblemResolvedEvent = "VCenterProblemsResolved"
+	vCenterProblemReason        = "VCenterProblems"
+)
+
+func (c *VSphereController) getVCenterWarningConditionName() string {
+	return c.name + "VCenterWarning"
+}
+
+// syncVCenterWarnings reports problems of vCenters found by the last cluster check that do not block upgrades yet,
+// like a TLS certificate that expires soon, in a condition and events, so they can be fixed before they block
+// upgrades.
+func (c *VSphereController) syncVCenterWarnings(ctx context.Context) error {
+	if c.vCenterWarnings == nil {
+		return nil
+	}
+	warnings := c.vCenterWarnings.Warnings()
+	vCenters := make([]string, 0, len(warnings))
+	for vCenter := range warnings {
+		vCenters = append(vCenters, vCenter)
+	}
+	sort.Strings(vCenters)
+	messages := make([]string, 0, len(vCenters))
+	for _, vCenter := range vCenters {
+		messages = append(messages, fmt.Sprintf("vCenter %s: %s", vCenter, warnings[vCenter]))
+	}
+
+	return c.syncWarningCondition(
+		ctx,
+		c.getVCenterWarningConditionName(),
+		vCenterProblemReason,
+		vCenterProblemEvent,
+		vCenterProblemResolvedEvent,
+		"Problems of vCenters are resolved",
+		messages,
+	)
+}
@@ -0,0 +1,97 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func countEvents(recorder events.Recorder, reason string) int {
+	count := 0
+	for _, event := range recorder.(events.InMemoryRecorder).Events() {
+		if event.Reason == reason {
+			count++
+		}
+	}
+	return count
+}
+
+func TestVCenterCertificateWarning(t *testing.T) {
+	infra := testlib.GetInfraObject()
+	connections, cleanUpFunc, err := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	if err != nil {
+		t.Fatalf("unexpected error while connecting to simulator: %v", err)
+	}
+	defer cleanUpFunc()
+	// The certificate of vCenter is recorded when the connection logs in
+	connection := &vclib.VSphereConnection{
+		Username: "vsphere-user",
+		Password: "vsphere-password",
+		Hostname: connections[0].Client.URL().Host,
+		Insecure: true,
+	}
+	if err := connection.Connect(context.TODO()); err != nil {
+		t.Fatalf("error connecting to simulator: %v", err)
+	}
+	defer connection.Logout(context.TODO())
+	remaining := time.Until(connection.ServerCertificate().NotAfter)
+
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{}, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+	ctrl := newVsphereController(commonApiClient)
+	checkVCenterWarnings := func(horizon time.Duration) *opv1.OperatorCondition {
+		ctrl.operatorConfig = &utils.OperatorConfig{CertificateExpiryHorizon: &metav1.Duration{Duration: horizon}}
+		checkOpts := checks.NewCheckArgs([]*vclib.VSphereConnection{connection}, ctrl.getCheckAPIDependency(infra), ctrl.featureGates).
+			WithVCenterWarnings(ctrl.vCenterWarnings)
+		for _, result := range (&checks.VCenterTimeChecker{}).Check(context.TODO(), checkOpts) {
+			t.Errorf("expected the certificate not to block upgrades yet, got %s: %s", result.CheckStatus, result.Reason)
+		}
+		if err := ctrl.syncVCenterWarnings(context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, status, _, err := ctrl.operatorClient.GetOperatorState()
+		if err != nil {
+			t.Fatalf("failed to get operator state: %v", err)
+		}
+		return v1helpers.FindOperatorCondition(status.Conditions, ctrl.getVCenterWarningConditionName())
+	}
+
+	// The certificate expires before twice the horizon
+	horizon := remaining * 3 / 4
+	cond := checkVCenterWarnings(horizon)
+	if cond == nil || cond.Status != opv1.ConditionTrue {
+		t.Fatalf("expected vCenter warning condition to be true, got %+v", cond)
+	}
+	if expected := "vCenter " + connection.Hostname + ": TLS certificate expires at"; !strings.HasPrefix(cond.Message, expected) {
+		t.Errorf("expected message starting with %q, got %q", expected, cond.Message)
+	}
+	if count := countEvents(ctrl.eventRecorder, vCenterProblemEvent); count != 1 {
+		t.Errorf("expected 1 event %s, got %d", vCenterProblemEvent, count)
+	}
+
+	// The same warning is not reported again
+	if cond := checkVCenterWarnings(horizon); cond == nil {
+		t.Errorf("expected vCenter warning condition to be kept")
+	}
+	if count := countEvents(ctrl.eventRecorder, vCenterProblemEvent); count != 1 {
+		t.Errorf("expected 1 event %s, got %d", vCenterProblemEvent, count)
+	}
+
+	// The certificate was renewed, it expires after twice the horizon
+	if cond := checkVCenterWarnings(remaining / 4); cond != nil {
+		t.Errorf("expected vCenter warning condition to be removed, got: %s", cond.Message)
+	}
+	if !hasEvent(ctrl.eventRecorder, vCenterProblemResolvedEvent) {
+		t.Errorf("expected event %s", vCenterProblemResolvedEvent)
+	}
+}
@@ -0,0 +1,106 @@
+package vspherecontroller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	"github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	maxVolumesPerNodeEnv    = "MAX_VOLUMES_PER_NODE"
+	driverContainerName     = "csi-driver"
+	limitedAttachmentsEvent = "NodeVolumeAttachmentsLimited"
+	noPVSCSIControllerEvent = "NodeWithoutPVSCSIController"
+)
+
+// WithVolumeLimitDeploymentHook sets MAX_VOLUMES_PER_NODE of the CSI driver to the attachable volume limit
+// computed from node VMs.
+func WithVolumeLimitDeploymentHook(nodeStatuses *checks.NodeStatuses) deploymentcontroller.DeploymentHookFunc {
+	return func(opSpec *operatorapi.OperatorSpec, deployment *appsv1.Deployment) error {
+		deployment.Spec.Template.Spec.Containers = setVolumeLimit(deployment.Spec.Template.Spec.Containers, nodeStatuses)
+		return nil
+	}
+}
+
+// WithVolumeLimitDaemonSetHook sets MAX_VOLUMES_PER_NODE of the CSI driver to the attachable volume limit
+// computed from node VMs, so CSINode objects report correct allocatable count.
+func WithVolumeLimitDaemonSetHook(nodeStatuses *checks.NodeStatuses) csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(opSpec *operatorapi.OperatorSpec, ds *appsv1.DaemonSet) error {
+		ds.Spec.Template.Spec.Containers = setVolumeLimit(ds.Spec.Template.Spec.Containers, nodeStatuses)
+		return nil
+	}
+}
+
+func setVolumeLimit(containers []v1.Container, nodeStatuses *checks.NodeStatuses) []v1.Container {
+	if nodeStatuses == nil {
+		return containers
+	}
+	limit, found := nodeStatuses.ClusterVolumeLimit()
+	if !found {
+		// Node VMs were not checked yet, keep the default from the manifest
+		return containers
+	}
+	for i := range containers {
+		if containers[i].Name != driverContainerName {
+			continue
+		}
+		for j := range containers[i].Env {
+			if containers[i].Env[j].Name == maxVolumesPerNodeEnv {
+				containers[i].Env[j].Value = strconv.Itoa(limit)
+			}
+		}
+	}
+	return containers
+}
+
+// reportNodeVolumeLimits warns about nodes whose controller layout limits volume attachments. The limit of the CSI
+// driver is not lowered for them, see ClusterVolumeLimit. Every node check finds the same limits, so each node is
+// reported only when its limit changes.
+func (c *VSphereController) reportNodeVolumeLimits() {
+	if c.nodeStatuses == nil {
+		return
+	}
+	statuses := c.nodeStatuses.Get()
+	nodeNames := make([]string, 0, len(statuses))
+	for nodeName := range statuses {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	reported := make(map[string]string, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		status := statuses[nodeName]
+		if status.IneligibleReason != "" {
+			continue
+		}
+		limit := status.VolumeLimit
+		var reason, message string
+		switch {
+		case limit.PVSCSIControllers == 0:
+			reason = noPVSCSIControllerEvent
+			message = fmt.Sprintf("Node %s has no paravirtual SCSI controller, the CSI driver can't attach volumes to it", nodeName)
+		case limit.Limit < checks.DefaultMaxVolumesPerNode:
+			reason = limitedAttachmentsEvent
+			message = fmt.Sprintf("Only %d volumes can be attached to node %s with %d paravirtual SCSI controller(s) and hardware version vmx-%d, attaching more volumes to it fails, add paravirtual SCSI controllers to attach up to %d volumes",
+				limit.Limit, nodeName, limit.PVSCSIControllers, limit.HardwareVersion, checks.DefaultMaxVolumesPerNode)
+		default:
+			continue
+		}
+		reported[nodeName] = message
+		if c.reportedVolumeLimits[nodeName] != message {
+			c.eventRecorder.Warning(reason, message)
+		}
+	}
+	c.reportedVolumeLimits = reported
+	if clusterLimit, found := c.nodeStatuses.ClusterVolumeLimit(); found {
+		klog.V(2).Infof("Attachable volume limit of nodes is %d", clusterLimit)
+	}
+}
@@ -0,0 +1,197 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	v1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	"github.com/vmware/govmomi/vim25/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/clock"
+)
+
+func TestNodeVolumeLimits(t *testing.T) {
+	infra := testlib.GetInfraObject()
+	nodes := testlib.DefaultNodes()
+	var initialObjects []runtime.Object
+	for _, node := range nodes {
+		initialObjects = append(initialObjects, runtime.Object(node))
+	}
+	commonApiClient := testlib.NewFakeClients(initialObjects, testlib.MakeFakeDriverInstance(), runtime.Object(infra))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go testlib.StartFakeInformer(commonApiClient, stopCh)
+	if err := testlib.AddInitialObjects(initialObjects, commonApiClient); err != nil {
+		t.Fatalf("error adding initial objects: %v", err)
+	}
+	testlib.WaitForSync(commonApiClient, stopCh)
+
+	connections, cleanUpFunc, connError := testlib.SetupSimulator(testlib.DefaultModel, infra)
+	if connError != nil {
+		t.Fatalf("unexpected error while connecting to simulator: %v", connError)
+	}
+	defer func() {
+		if cleanUpFunc != nil {
+			cleanUpFunc()
+		}
+	}()
+
+	conn := connections[0]
+	if err := testlib.CustomizeHostVersion(testlib.DefaultHostId, "7.0.2"); err != nil {
+		t.Fatalf("error setting host version: %v", err)
+	}
+	if err := setHardwareVersionsFunc(nodes, conn, []string{"vmx-15", "vmx-15"})(); err != nil {
+		t.Fatalf("error setting hardware version: %v", err)
+	}
+	// Add one PVSCSI controller to the first node VM
+	err := testlib.CustomizeVM(conn, nodes[0], &types.VirtualMachineConfigSpec{
+		DeviceChange: []types.BaseVirtualDeviceConfigSpec{
+			&types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationAdd,
+				Device: &types.ParaVirtualSCSIController{
+					VirtualSCSIController: types.VirtualSCSIController{
+						SharedBus: types.VirtualSCSISharingNoSharing,
+						VirtualController: types.VirtualController{
+							BusNumber: 3,
+							VirtualDevice: types.VirtualDevice{
+								Key: -100,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error adding PVSCSI controller: %v", err)
+	}
+
+	checkerApiClient := &checks.KubeAPIInterfaceImpl{
+		Infrastructure: infra,
+		NodeLister:     commonApiClient.NodeInformer.Lister(),
+	}
+	nodeStatuses := checks.NewNodeStatuses()
+	fg := featuregates.NewFeatureGate([]v1.FeatureGateName{}, []v1.FeatureGateName{})
+	checkOpts := checks.NewCheckArgs(connections, checkerApiClient, fg).WithNodeStatuses(nodeStatuses)
+
+	checker := &checks.NodeChecker{}
+	results := checker.Check(context.TODO(), checkOpts)
+	for _, result := range results {
+		if result.CheckError != nil {
+			t.Fatalf("unexpected node check error: %v", result.CheckError)
+		}
+	}
+
+	statuses := nodeStatuses.Get()
+	if len(statuses) != len(nodes) {
+		t.Fatalf("expected statuses of %d nodes, got %+v", len(nodes), statuses)
+	}
+	first, second := statuses[nodes[0].Name].VolumeLimit, statuses[nodes[1].Name].VolumeLimit
+	if first.PVSCSIControllers != second.PVSCSIControllers+1 {
+		t.Errorf("expected node %s to have one more PVSCSI controller than node %s, got %+v and %+v", nodes[0].Name, nodes[1].Name, first, second)
+	}
+	// Each PVSCSI controller of vmx-15 VM provides 63 targets
+	if first.Limit != second.Limit+63 {
+		t.Errorf("expected node %s to attach 63 more volumes than node %s, got %+v and %+v", nodes[0].Name, nodes[1].Name, first, second)
+	}
+
+	clusterLimit, found := nodeStatuses.ClusterVolumeLimit()
+	expectedLimit := second.Limit
+	if second.Limit == 0 {
+		expectedLimit = first.Limit
+	}
+	if !found || clusterLimit != expectedLimit {
+		t.Errorf("expected cluster limit %d, got %d", expectedLimit, clusterLimit)
+	}
+
+	ds := &appsv1.DaemonSet{}
+	ds.Spec.Template.Spec.Containers = []corev1.Container{
+		{
+			Name: driverContainerName,
+			Env:  []corev1.EnvVar{{Name: maxVolumesPerNodeEnv, Value: "59"}},
+		},
+	}
+	if err := WithVolumeLimitDaemonSetHook(nodeStatuses)(&opv1.OperatorSpec{}, ds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value := ds.Spec.Template.Spec.Containers[0].Env[0].Value
+	if value != strconv.Itoa(expectedLimit) {
+		t.Errorf("expected %s to be %d, got %s", maxVolumesPerNodeEnv, expectedLimit, value)
+	}
+}
+
+func TestReportNodeVolumeLimits(t *testing.T) {
+	recorder := events.NewInMemoryRecorder("test", clock.RealClock{})
+	ctrl := &VSphereController{
+		eventRecorder: recorder,
+		nodeStatuses:  checks.NewNodeStatuses(),
+	}
+	countEvents := func(reason string) int {
+		count := 0
+		for _, event := range recorder.Events() {
+			if event.Reason == reason {
+				count++
+			}
+		}
+		return count
+	}
+	setLimits := func(limits map[string]checks.NodeVolumeLimit) {
+		saved := map[string]checks.SavedNodeStatus{}
+		for node, limit := range limits {
+			saved[node] = checks.SavedNodeStatus{VolumeLimit: limit}
+		}
+		ctrl.nodeStatuses.Restore(saved)
+	}
+
+	setLimits(map[string]checks.NodeVolumeLimit{
+		"node1": {Limit: 14, PVSCSIControllers: 1, HardwareVersion: 13},
+		"node2": {Limit: 0, PVSCSIControllers: 0, HardwareVersion: 15},
+		"node3": {Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15},
+	})
+	ctrl.reportNodeVolumeLimits()
+	// The same limits are found by every check
+	ctrl.reportNodeVolumeLimits()
+	// node1 does not lower the limit of the other nodes
+	if limit, found := ctrl.nodeStatuses.ClusterVolumeLimit(); !found || limit != 62 {
+		t.Errorf("expected cluster limit 62, got %d", limit)
+	}
+	if count := countEvents(limitedAttachmentsEvent); count != 1 {
+		t.Errorf("expected 1 %s event, got %d", limitedAttachmentsEvent, count)
+	}
+	if count := countEvents(noPVSCSIControllerEvent); count != 1 {
+		t.Errorf("expected 1 %s event, got %d", noPVSCSIControllerEvent, count)
+	}
+
+	// node1 got another controller, node2 is fixed
+	setLimits(map[string]checks.NodeVolumeLimit{
+		"node1": {Limit: 29, PVSCSIControllers: 2, HardwareVersion: 13},
+		"node2": {Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15},
+		"node3": {Limit: 62, PVSCSIControllers: 1, HardwareVersion: 15},
+	})
+	ctrl.reportNodeVolumeLimits()
+	if count := countEvents(limitedAttachmentsEvent); count != 2 {
+		t.Errorf("expected 2 %s events after the limit changed, got %d", limitedAttachmentsEvent, count)
+	}
+
+	// The problem of node2 is reported again when it comes back
+	setLimits(map[string]checks.NodeVolumeLimit{
+		"node1": {Limit: 29, PVSCSIControllers: 2, HardwareVersion: 13},
+		"node2": {Limit: 0, PVSCSIControllers: 0, HardwareVersion: 15},
+	})
+	ctrl.reportNodeVolumeLimits()
+	if limit, found := ctrl.nodeStatuses.ClusterVolumeLimit(); !found || limit != checks.DefaultMaxVolumesPerNode {
+		t.Errorf("expected the default cluster limit %d when all nodes are limited, got %d", checks.DefaultMaxVolumesPerNode, limit)
+	}
+	if count := countEvents(noPVSCSIControllerEvent); count != 2 {
+		t.Errorf("expected 2 %s events, got %d", noPVSCSIControllerEvent, count)
+	}
+}
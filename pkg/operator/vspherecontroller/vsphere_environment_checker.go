@@ -5,7 +5,9 @@ import (
 	"math"
 	"time"
 
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 )
@@ -24,14 +26,46 @@ var (
 
 type vSphereEnvironmentCheckInterface interface {
 	Check(ctx context.Context, connection checks.CheckArgs) (time.Duration, checks.ClusterCheckResult, bool)
+	// CheckNodes checks only the given nodes and merges their results with results of the last full check.
+	// It does not run when the full check is due.
+	CheckNodes(ctx context.Context, connection checks.CheckArgs, nodeNames []string) (time.Duration, checks.ClusterCheckResult, bool)
 	ResetExpBackoff()
+	// SetBackoff changes timing of the checks. The number of failed checks in a row is kept.
+	SetBackoff(backoff wait.Backoff)
+	// GetState returns the schedule and results of the last full check. It returns false when no check ran yet.
+	GetState() (checkerState, bool)
+	// RestoreState resumes the schedule saved by a previous instance of the operator.
+	RestoreState(state checkerState)
+}
+
+// checkerState is the schedule and results of the last full check, it's saved so a restarted operator does not
+// run all checks immediately.
+type checkerState struct {
+	LastCheck metav1.Time `json:"lastCheck"`
+	NextCheck metav1.Time `json:"nextCheck"`
+	// BackoffSteps is the number of failed checks in a row
+	BackoffSteps int                       `json:"backoffSteps,omitempty"`
+	Result       checks.SavedCheckResult   `json:"result"`
+	Results      []checks.SavedCheckResult `json:"results,omitempty"`
+	// LastSuccess is the time each checker last passed, indexed by the checker name
+	LastSuccess map[string]metav1.Time `json:"lastSuccess,omitempty"`
 }
 
 type vSphereEnvironmentCheckerComposite struct {
-	backoff   wait.Backoff
-	nextCheck time.Time
-	lastCheck time.Time
-	checkers  []checks.CheckInterface
+	// baseBackoff is the configured backoff, backoff is its state after backoffSteps failed checks
+	baseBackoff  wait.Backoff
+	backoff      wait.Backoff
+	backoffSteps int
+	nextCheck    time.Time
+	lastCheck    time.Time
+	lastResult   checks.ClusterCheckResult
+	checkers     []checks.CheckInterface
+	// nodeChecker is run after all other checkers, it can check individual nodes too
+	nodeChecker *checks.NodeChecker
+	// results of checkers other than nodeChecker from the last full check
+	lastResults []checks.ClusterCheckResult
+	// time each checker last passed, indexed by the checker name
+	lastSuccess map[string]time.Time
 }
 
 // make sure that vSphereEnvironmentCheckerComposite implements the vSphereEnvironmentCheckInterface
@@ -39,15 +73,22 @@ var _ vSphereEnvironmentCheckInterface = &vSphereEnvironmentCheckerComposite{}
 
 func newVSphereEnvironmentChecker() *vSphereEnvironmentCheckerComposite {
 	checker := &vSphereEnvironmentCheckerComposite{
-		backoff:   defaultBackoff,
-		nextCheck: time.Now(),
+		baseBackoff: defaultBackoff,
+		backoff:     defaultBackoff,
+		nextCheck:   time.Now(),
 	}
-	checker.checkers = []checks.CheckInterface{
+	checker.checkers = newCheckers()
+	checker.nodeChecker = &checks.NodeChecker{}
+	return checker
+}
+
+// newCheckers returns all checkers except the node checker, which is run separately.
+func newCheckers() []checks.CheckInterface {
+	return []checks.CheckInterface{
 		&checks.CheckExistingDriver{},
 		&checks.VCenterChecker{},
-		&checks.NodeChecker{},
+		&checks.VCenterTimeChecker{},
 	}
-	return checker
 }
 
 func (v *vSphereEnvironmentCheckerComposite) Check(
@@ -59,15 +100,82 @@ func (v *vSphereEnvironmentCheckerComposite) Check(
 	}
 
 	nextErrorDelay := v.backoff.Step()
+	v.backoffSteps++
 	v.lastCheck = time.Now()
 
 	var allChecks []checks.ClusterCheckResult
 
 	for _, checker := range v.checkers {
-		result := checker.Check(ctx, checkOpts)
+		result := v.runCheck(ctx, checker, checkOpts)
 		allChecks = append(allChecks, result...)
 	}
+	v.lastResults = allChecks
+	allChecks = append(allChecks[:len(allChecks):len(allChecks)], v.runCheck(ctx, v.nodeChecker, checkOpts)...)
+
+	overallResult := getOverallResult(allChecks)
+
+	if overallResult.Action > checks.CheckActionPass {
+		v.lastResult = overallResult
+		// Everything else than pass needs a quicker re-check
+		klog.Warningf("Overall check result: %s: %s", checks.ActionToString(overallResult.Action), overallResult.Reason)
+		v.nextCheck = v.lastCheck.Add(nextErrorDelay)
+		return nextErrorDelay, overallResult, true
+	}
+
+	// Slow re-check when everything looks OK
+	klog.V(2).Infof("Overall check result: %s: %s", checks.ActionToString(overallResult.Action), overallResult.Reason)
+	v.lastResult = checks.MakeClusterCheckResultPass()
+	v.backoff = v.baseBackoff
+	v.backoffSteps = 0
+	v.nextCheck = v.lastCheck.Add(v.baseBackoff.Cap)
+	return v.baseBackoff.Cap, checks.MakeClusterCheckResultPass(), true
+}
+
+func (v *vSphereEnvironmentCheckerComposite) CheckNodes(
+	ctx context.Context, checkOpts checks.CheckArgs, nodeNames []string) (time.Duration, checks.ClusterCheckResult, bool) {
+	var delay time.Duration
+	var checkResult checks.ClusterCheckResult
+	// The full check must run first, results of the other checkers are not known without it.
+	if v.lastCheck.IsZero() || time.Now().After(v.nextCheck) {
+		return delay, checkResult, false
+	}
+
+	allChecks := append(v.lastResults[:len(v.lastResults):len(v.lastResults)], v.nodeChecker.CheckNodes(ctx, checkOpts, nodeNames)...)
+	overallResult := getOverallResult(allChecks)
+
+	if overallResult.Action > checks.CheckActionPass {
+		klog.Warningf("Overall check result after checking nodes %v: %s: %s", nodeNames, checks.ActionToString(overallResult.Action), overallResult.Reason)
+		// Re-check the whole cluster soon, the scheduled full check may be up to an hour away
+		if retry := time.Now().Add(v.baseBackoff.Duration); retry.Before(v.nextCheck) {
+			v.nextCheck = retry
+		}
+		return time.Until(v.nextCheck), overallResult, true
+	}
+
+	klog.V(2).Infof("Overall check result after checking nodes %v: %s: %s", nodeNames, checks.ActionToString(overallResult.Action), overallResult.Reason)
+	return time.Until(v.nextCheck), checks.MakeClusterCheckResultPass(), true
+}
 
+// runCheck runs a single checker and records its duration and result in metrics.
+func (v *vSphereEnvironmentCheckerComposite) runCheck(ctx context.Context, checker checks.CheckInterface, checkOpts checks.CheckArgs) []checks.ClusterCheckResult {
+	start := time.Now()
+	results := checker.Check(ctx, checkOpts)
+	utils.CheckDurationMetric.WithLabelValues(checker.Name()).Observe(time.Since(start).Seconds())
+
+	result := getOverallResult(results)
+	utils.CheckResultMetric.WithLabelValues(checker.Name()).Set(float64(result.Action))
+	if result.Action == checks.CheckActionPass {
+		if v.lastSuccess == nil {
+			v.lastSuccess = map[string]time.Time{}
+		}
+		v.lastSuccess[checker.Name()] = time.Now()
+		utils.CheckLastSuccessMetric.WithLabelValues(checker.Name()).Set(float64(time.Now().Unix()))
+	}
+	return results
+}
+
+// getOverallResult returns the most severe result.
+func getOverallResult(allChecks []checks.ClusterCheckResult) checks.ClusterCheckResult {
 	overallResult := checks.ClusterCheckResult{
 		Action: checks.CheckActionPass,
 	}
@@ -80,22 +188,68 @@ func (v *vSphereEnvironmentCheckerComposite) Check(
 			overallResult = result
 		}
 	}
+	return overallResult
+}
 
-	if overallResult.Action > checks.CheckActionPass {
-		// Everything else than pass needs a quicker re-check
-		klog.Warningf("Overall check result: %s: %s", checks.ActionToString(overallResult.Action), overallResult.Reason)
-		v.nextCheck = v.lastCheck.Add(nextErrorDelay)
-		return nextErrorDelay, overallResult, true
+func (v *vSphereEnvironmentCheckerComposite) ResetExpBackoff() {
+	v.nextCheck = time.Now()
+	v.backoff = v.baseBackoff
+	v.backoffSteps = 0
+}
+
+func (v *vSphereEnvironmentCheckerComposite) SetBackoff(backoff wait.Backoff) {
+	if backoff == v.baseBackoff {
+		return
 	}
+	klog.V(2).Infof("Cluster check backoff changed to %s doubling by %v up to %s", backoff.Duration, backoff.Factor, backoff.Cap)
+	v.baseBackoff = backoff
+	v.rebuildBackoff()
+}
 
-	// Slow re-check when everything looks OK
-	klog.V(2).Infof("Overall check result: %s: %s", checks.ActionToString(overallResult.Action), overallResult.Reason)
-	v.backoff = defaultBackoff
-	v.nextCheck = v.lastCheck.Add(defaultBackoff.Cap)
-	return defaultBackoff.Cap, checks.MakeClusterCheckResultPass(), true
+func (v *vSphereEnvironmentCheckerComposite) GetState() (checkerState, bool) {
+	if v.lastCheck.IsZero() {
+		return checkerState{}, false
+	}
+	state := checkerState{
+		LastCheck:    metav1.NewTime(v.lastCheck),
+		NextCheck:    metav1.NewTime(v.nextCheck),
+		BackoffSteps: v.backoffSteps,
+		Result:       checks.SaveCheckResult(v.lastResult),
+	}
+	for _, result := range v.lastResults {
+		state.Results = append(state.Results, checks.SaveCheckResult(result))
+	}
+	if len(v.lastSuccess) > 0 {
+		state.LastSuccess = make(map[string]metav1.Time, len(v.lastSuccess))
+		for name, lastSuccess := range v.lastSuccess {
+			state.LastSuccess[name] = metav1.NewTime(lastSuccess)
+		}
+	}
+	return state, true
 }
 
-func (v *vSphereEnvironmentCheckerComposite) ResetExpBackoff() {
-	v.nextCheck = time.Now()
-	v.backoff = defaultBackoff
+func (v *vSphereEnvironmentCheckerComposite) RestoreState(state checkerState) {
+	v.lastCheck = state.LastCheck.Time
+	v.nextCheck = state.NextCheck.Time
+	v.backoffSteps = state.BackoffSteps
+	v.lastResult = state.Result.Restore()
+	v.lastResults = nil
+	for _, result := range state.Results {
+		v.lastResults = append(v.lastResults, result.Restore())
+	}
+	// The metric of a restarted operator must not look like the checks never passed
+	v.lastSuccess = make(map[string]time.Time, len(state.LastSuccess))
+	for name, lastSuccess := range state.LastSuccess {
+		v.lastSuccess[name] = lastSuccess.Time
+		utils.CheckLastSuccessMetric.WithLabelValues(name).Set(float64(lastSuccess.Unix()))
+	}
+	v.rebuildBackoff()
+}
+
+// rebuildBackoff steps baseBackoff as many times as there were failed checks in a row.
+func (v *vSphereEnvironmentCheckerComposite) rebuildBackoff() {
+	v.backoff = v.baseBackoff
+	for i := 0; i < v.backoffSteps && v.backoff.Duration < v.backoff.Cap; i++ {
+		v.backoff.Step()
+	}
 }
@@ -2,16 +2,21 @@ package vspherecontroller
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	v1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	"k8s.io/component-base/metrics/testutil"
 )
 
 func TestEnvironmentCheck(t *testing.T) {
@@ -25,7 +30,9 @@ func TestEnvironmentCheck(t *testing.T) {
 		clusterCSIDriverObject *testlib.FakeDriverInstance
 		expectedBackOffSteps   int
 		expectedNextCheck      time.Time
-		runCount               int
+		// expected values of vsphere_csi_driver_check_result for each check
+		expectedCheckResults map[string]checks.CheckAction
+		runCount             int
 	}{
 		{
 			name:                   "when tests are ran successfully, delay should be set to maximum delay",
@@ -38,7 +45,12 @@ func TestEnvironmentCheck(t *testing.T) {
 			// should reset the steps back to maximum in defaultBackoff
 			expectedBackOffSteps: defaultBackoff.Steps,
 			expectedNextCheck:    time.Now().Add(defaultBackoff.Cap),
-			runCount:             1,
+			expectedCheckResults: map[string]checks.CheckAction{
+				"existing_driver": checks.CheckActionPass,
+				"vcenter":         checks.CheckActionPass,
+				"nodes":           checks.CheckActionPass,
+			},
+			runCount: 1,
 		},
 		{
 			name:                   "when tests are ran successfully, delay should be set to maximum delay YAML",
@@ -51,7 +63,12 @@ func TestEnvironmentCheck(t *testing.T) {
 			// should reset the steps back to maximum in defaultBackoff
 			expectedBackOffSteps: defaultBackoff.Steps,
 			expectedNextCheck:    time.Now().Add(defaultBackoff.Cap),
-			runCount:             1,
+			expectedCheckResults: map[string]checks.CheckAction{
+				"existing_driver": checks.CheckActionPass,
+				"vcenter":         checks.CheckActionPass,
+				"nodes":           checks.CheckActionPass,
+			},
+			runCount: 1,
 		},
 		{
 			name:                   "when tests fail, delay should backoff exponentially",
@@ -63,7 +80,11 @@ func TestEnvironmentCheck(t *testing.T) {
 			checksRan:              true,
 			expectedBackOffSteps:   defaultBackoff.Steps - 1,
 			expectedNextCheck:      time.Now().Add(1 * time.Minute),
-			runCount:               1,
+			expectedCheckResults: map[string]checks.CheckAction{
+				"existing_driver": checks.CheckActionPass,
+				"vcenter":         checks.CheckActionBlockUpgradeOrDegrade,
+			},
+			runCount: 1,
 		},
 		{
 			name:                   "when tests fail, delay should backoff exponentially YAML",
@@ -75,13 +96,18 @@ func TestEnvironmentCheck(t *testing.T) {
 			checksRan:              true,
 			expectedBackOffSteps:   defaultBackoff.Steps - 1,
 			expectedNextCheck:      time.Now().Add(1 * time.Minute),
-			runCount:               1,
+			expectedCheckResults: map[string]checks.CheckAction{
+				"existing_driver": checks.CheckActionPass,
+				"vcenter":         checks.CheckActionBlockUpgradeOrDegrade,
+			},
+			runCount: 1,
 		},
 	}
 
 	for i := range tests {
 		test := tests[i]
 		t.Run(test.name, func(t *testing.T) {
+			utils.CheckResultMetric.Reset()
 			commonApiClient := testlib.NewFakeClients(test.initialObjects, test.clusterCSIDriverObject, runtime.Object(test.infra))
 			stopCh := make(chan struct{})
 			defer close(stopCh)
@@ -145,6 +171,15 @@ func TestEnvironmentCheck(t *testing.T) {
 			if !checker.nextCheck.After(test.expectedNextCheck) {
 				t.Fatalf("expected nextCheck %v to be after expectedNextCheck %v", checker.nextCheck, test.expectedNextCheck)
 			}
+			for check, expectedAction := range test.expectedCheckResults {
+				value, err := testutil.GetGaugeMetricValue(utils.CheckResultMetric.WithLabelValues(check))
+				if err != nil {
+					t.Fatalf("error getting result metric of check %s: %v", check, err)
+				}
+				if checks.CheckAction(value) != expectedAction {
+					t.Errorf("expected result metric of check %s to be %d, got %v", check, expectedAction, value)
+				}
+			}
 		})
 	}
 }
@@ -160,3 +195,50 @@ func compareTimeDiffWithinTimeFactor(t1, t2 time.Duration) bool {
 		return (t1 < maxTime)
 	}
 }
+
+func TestEnvironmentCheckerState(t *testing.T) {
+	failed := checks.MakeClusterUnupgradeableError(checks.CheckStatusDeprecatedVCenter, fmt.Errorf("old vCenter"))
+	lastCheck := time.Now().Add(-time.Minute).Truncate(time.Second)
+	nextCheck := lastCheck.Add(8 * time.Minute)
+	state := checkerState{
+		LastCheck:    metav1.NewTime(lastCheck),
+		NextCheck:    metav1.NewTime(nextCheck),
+		BackoffSteps: 3,
+		Result:       checks.SaveCheckResult(failed),
+		Results:      []checks.SavedCheckResult{checks.SaveCheckResult(failed)},
+	}
+
+	checker := newVSphereEnvironmentChecker()
+	checker.RestoreState(state)
+
+	// The restored schedule is used instead of checking immediately
+	if _, _, checkRan := checker.Check(context.TODO(), checks.CheckArgs{}); checkRan {
+		t.Errorf("expected check not to run before the restored next check")
+	}
+	if checker.backoff.Duration != 8*time.Minute {
+		t.Errorf("expected backoff after 3 failed checks to be 8m, got %s", checker.backoff.Duration)
+	}
+	savedState, found := checker.GetState()
+	if !found {
+		t.Fatalf("expected restored state to be found")
+	}
+	if !reflect.DeepEqual(savedState, state) {
+		t.Errorf("expected state %+v, got %+v", state, savedState)
+	}
+	if len(checker.lastResults) != 1 || checker.lastResults[0].CheckError == nil || checker.lastResults[0].Action != failed.Action {
+		t.Errorf("expected restored failed result, got %+v", checker.lastResults)
+	}
+
+	// Changed backoff keeps the number of failed checks
+	backoff := defaultBackoff
+	backoff.Duration = 30 * time.Second
+	checker.SetBackoff(backoff)
+	if checker.backoff.Duration != 4*time.Minute {
+		t.Errorf("expected backoff after 3 failed checks to be 4m, got %s", checker.backoff.Duration)
+	}
+
+	checker.ResetExpBackoff()
+	if checker.backoffSteps != 0 || checker.backoff != backoff {
+		t.Errorf("expected backoff to be reset to %+v, got %+v after %d steps", backoff, checker.backoff, checker.backoffSteps)
+	}
+}
@@ -3,7 +3,6 @@ package vspherecontroller
 import (
 	"context"
 	"fmt"
-	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -32,43 +31,84 @@ import (
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
-	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	appslister "k8s.io/client-go/listers/apps/v1"
 	corelister "k8s.io/client-go/listers/core/v1"
 	storagelister "k8s.io/client-go/listers/storage/v1"
 	"k8s.io/klog/v2"
 )
 
 type VSphereController struct {
-	name                     string
-	targetNamespace          string
-	secretManifest           []byte
-	eventRecorder            events.Recorder
-	kubeClient               kubernetes.Interface
-	operatorClient           v1helpers.OperatorClientWithFinalizers
-	configMapLister          corelister.ConfigMapLister
-	secretLister             corelister.SecretLister
-	scLister                 storagelister.StorageClassLister
-	clusterCSIDriverLister   clustercsidriverlister.ClusterCSIDriverLister
-	infraLister              infralister.InfrastructureLister
-	nodeLister               corelister.NodeLister
-	csiDriverLister          storagelister.CSIDriverLister
-	csiNodeLister            storagelister.CSINodeLister
-	apiClients               utils.APIClient
-	controllers              []conditionalController
-	storageClassController   storageclasscontroller.StorageClassSyncInterface
-	operandControllerStarted bool
-	vSphereConnections       []*vclib.VSphereConnection
-	csiConfigManifest        []byte
-	vSphereChecker           vSphereEnvironmentCheckInterface
-	vCenterConnectionStatus  bool
-	featureGates             featuregates.FeatureGate
-	cloudConfig              *vclib.VSphereConfig
+	name                   string
+	targetNamespace        string
+	secretManifest         []byte
+	eventRecorder          events.Recorder
+	kubeClient             kubernetes.Interface
+	operatorClient         v1helpers.OperatorClientWithFinalizers
+	configMapLister        corelister.ConfigMapLister
+	secretLister           corelister.SecretLister
+	scLister               storagelister.StorageClassLister
+	clusterCSIDriverLister clustercsidriverlister.ClusterCSIDriverLister
+	infraLister            infralister.InfrastructureLister
+	proxyLister            infralister.ProxyLister
+	nodeLister             corelister.NodeLister
+	csiDriverLister        storagelister.CSIDriverLister
+	csiNodeLister          storagelister.CSINodeLister
+	deploymentLister       appslister.DeploymentLister
+	daemonSetLister        appslister.DaemonSetLister
+	apiClients             utils.APIClient
+	controllers            []conditionalController
+	// operandOperatorClient gates operands of all operand controllers, it's open while the CSI driver is installed
+	operandOperatorClient *gatedOperatorClient
+	// operandControllersRunning is true when the operand controllers were started, they run until the operator exits
+	operandControllersRunning bool
+	// windowsNodeController runs only when there are Windows nodes, windowsOperatorClient gates its operand
+	windowsNodeController        *conditionalController
+	windowsOperatorClient        *gatedOperatorClient
+	windowsNodeControllerStarted bool
+	storageClassController       storageclasscontroller.StorageClassSyncInterface
+	operandControllerStarted     bool
+	vSphereConnections           []*vclib.VSphereConnection
+	csiConfigManifest            []byte
+	vSphereChecker               vSphereEnvironmentCheckInterface
+	vCenterConnectionStatus      bool
+	featureGates                 featuregates.FeatureGate
+	cloudConfig                  *vclib.VSphereConfig
+	operatorConfig               *utils.OperatorConfig
+	// results of cluster checks for individual nodes
+	nodeStatuses *checks.NodeStatuses
+	// volume limit warnings reported in events, indexed by node name
+	reportedVolumeLimits map[string]string
+	// nodes that are not initialized yet reported in events
+	reportedNotInitializedNodes map[string]bool
+	// problems of vCenters found by cluster checks that do not block upgrades yet
+	vCenterWarnings *checks.VCenterWarnings
+	// nodes that were added or whose providerID changed since the last check
+	pendingNodes *pendingNodes
+	// cache of vCenter objects shared by all cluster checks
+	inventory *checks.Inventory
 
 	currentManagmentState operatorapi.ManagementState
 
+	// inMaintenance is true when the maintenance window from the operator config is in effect
+	inMaintenance bool
+	// time of the maintenance window start or end when the next sync is scheduled
+	nextMaintenanceSync time.Time
+	// checkStateRestored is true when the check state saved by a previous instance of the operator was loaded
+	checkStateRestored bool
+	// last processed value of utils.RunChecksAnnotation
+	runChecksTrigger string
+	// content of checkResultsConfigMapName written by this instance of the operator
+	publishedCheckResults string
+	// vCenters that can't be reached and the time since when
+	vCenterOutages map[string]time.Time
+	// zones of the unreachable vCenters, nodes in them are not checked
+	unavailableZones []string
+
 	// creates a new vSphereConnection - mainly used for testing
 	vsphereConnectionFunc func() ([]*vclib.VSphereConnection, checks.ClusterCheckResult, bool)
+	// checks the CNS service of a connected vCenter - mainly used for testing
+	cnsHealthFunc func(ctx context.Context, vConn *vclib.VSphereConnection) error
 }
 
 const (
@@ -79,6 +119,8 @@ const (
 	metricsCertSecretName             = "vmware-vsphere-csi-driver-controller-metrics-serving-cert"
 	webhookSecretName                 = "vmware-vsphere-csi-driver-webhook-secret"
 	trustedCAConfigMap                = "vmware-vsphere-csi-driver-trusted-ca-bundle"
+	trustedCABundleKey                = "ca-bundle.crt"
+	proxyGlobalName                   = "cluster"
 	driverConfigSecretName            = "vsphere-csi-config-secret"
 	defaultNamespace                  = "openshift-cluster-csi-drivers"
 	driverOperandName                 = "vmware-vsphere-csi-driver"
@@ -104,6 +146,7 @@ func NewVSphereController(
 	apiClients utils.APIClient,
 	csiConfigManifest []byte,
 	secretManifest []byte,
+	nodeStatuses *checks.NodeStatuses,
 	recorder events.Recorder,
 	gates featuregates.FeatureGate,
 ) factory.Controller {
@@ -111,10 +154,12 @@ func NewVSphereController(
 	ocpConfigInformer := apiClients.ConfigInformers
 	configMapInformer := kubeInformers.InformersFor(cloudConfigNamespace).Core().V1().ConfigMaps()
 	infraInformer := ocpConfigInformer.Config().V1().Infrastructures()
+	proxyInformer := ocpConfigInformer.Config().V1().Proxies()
 	scInformer := kubeInformers.InformersFor("").Storage().V1().StorageClasses()
 	csiDriverLister := kubeInformers.InformersFor("").Storage().V1().CSIDrivers().Lister()
 	csiNodeLister := kubeInformers.InformersFor("").Storage().V1().CSINodes().Lister()
 	nodeLister := apiClients.NodeInformer.Lister()
+	operandInformers := kubeInformers.InformersFor(defaultNamespace).Apps().V1()
 
 	rc := recorder.WithComponentSuffix("vmware-" + strings.ToLower(name))
 
@@ -129,29 +174,52 @@ func NewVSphereController(
 		scLister:                scInformer.Lister(),
 		csiDriverLister:         csiDriverLister,
 		nodeLister:              nodeLister,
+		deploymentLister:        operandInformers.Deployments().Lister(),
+		daemonSetLister:         operandInformers.DaemonSets().Lister(),
 		apiClients:              apiClients,
 		eventRecorder:           rc,
 		vSphereChecker:          newVSphereEnvironmentChecker(),
+		nodeStatuses:            nodeStatuses,
+		vCenterWarnings:         checks.NewVCenterWarnings(),
+		pendingNodes:            newPendingNodes(),
+		inventory:               checks.NewInventory(),
 		secretManifest:          secretManifest,
 		csiConfigManifest:       csiConfigManifest,
 		clusterCSIDriverLister:  apiClients.ClusterCSIDriverInformer.Lister(),
 		infraLister:             infraInformer.Lister(),
+		proxyLister:             proxyInformer.Lister(),
 		vCenterConnectionStatus: false,
 		featureGates:            gates,
 	}
-	c.controllers = []conditionalController{}
-	c.createCSIDriver()
-	c.createWebHookController()
+	c.createOperandControllers()
 	c.storageClassController = c.createStorageClassController()
 
+	// New nodes are checked as soon as they appear and deleted nodes are forgotten, without waiting for the next
+	// full cluster check
+	syncContext := factory.NewSyncContext(c.name, rc)
+	_, err := apiClients.NodeInformer.Informer().AddEventHandler(c.pendingNodes.eventHandler(func() {
+		syncContext.Queue().Add(syncContext.QueueKey())
+	}, c.nodeStatuses.Remove))
+	if err != nil {
+		klog.Errorf("error adding node event handler, new nodes will be checked by periodic cluster checks: %v", err)
+	}
+
 	return factory.New().WithInformers(
 		apiClients.OperatorClient.Informer(),
 		configMapInformer.Informer(),
 		apiClients.SecretInformer.Informer(),
 		infraInformer.Informer(),
+		proxyInformer.Informer(),
 		scInformer.Informer(),
 		apiClients.ClusterCSIDriverInformer.Informer(),
-	).WithSync(c.sync).
+	).WithFilteredEventsInformers(
+		// The operator publishes other ConfigMaps in its namespace, which must not trigger a sync
+		factory.NamesFilter(utils.OperatorConfigMapName, trustedCAConfigMap),
+		apiClients.ConfigMapInformer.Informer(),
+	).WithBareInformers(
+		apiClients.NodeInformer.Informer(),
+	).WithSyncContext(syncContext).
+		WithSync(c.sync).
 		ResyncEvery(resyncDuration).
 		WithSyncDegradedOnError(apiClients.OperatorClient).ToController(c.name, rc)
 }
@@ -182,18 +250,31 @@ func (c *VSphereController) sync(ctx context.Context, syncContext factory.SyncCo
 	if opSpec.ManagementState != operatorapi.Managed {
 		klog.Warningf("%s: ManagementState is %s, skipping", c.name, opSpec.ManagementState)
 		if opSpec.ManagementState == operatorapi.Removed {
-			// if previously we were managing the operator and now we are not, then we should restart the operator
+			// if previously we were managing the operator and now we are not, then the operand controllers must
+			// remove their operands instead of re-creating them
 			if c.currentManagmentState == operatorapi.Managed {
-				klog.Errorf("Operator is being removed, restarting the operator")
-				os.Exit(0)
+				klog.Infof("Operator is being removed, closing the gate of the operand controllers to remove their operands")
+				c.disableOperandControllers()
+				c.currentManagmentState = opSpec.ManagementState
 			}
 			// if we are in removed state, we should remove all conditions
 			return c.removeOperands(ctx, opStatus)
 		}
+		if opSpec.ManagementState == operatorapi.Unmanaged {
+			// The operands carry the volume limit computed by the checks of a previous instance of the operator
+			if err := c.restoreCheckState(ctx, syncContext); err != nil {
+				return err
+			}
+			// report operands changed by hand, without touching them
+			return c.syncDrift(ctx, opSpec, opStatus, infra)
+		}
 		return nil
 	}
 
 	c.currentManagmentState = opSpec.ManagementState
+	if err := c.clearDrift(ctx, opStatus); err != nil {
+		return err
+	}
 
 	clusterCSIDriver, err := c.clusterCSIDriverLister.Get(utils.VSphereDriverName)
 	if err != nil {
@@ -209,8 +290,12 @@ func (c *VSphereController) sync(ctx context.Context, syncContext factory.SyncCo
 
 	// if driver was previously started, then start it even if checks are failing
 	if driverCheckFlag && !c.operandControllerStarted {
-		go c.runConditionalController(ctx)
-		c.operandControllerStarted = true
+		c.enableOperandControllers(ctx)
+	}
+	if c.operandControllerStarted {
+		if err := c.syncWindowsNodeController(ctx); err != nil {
+			klog.Errorf("error syncing %s: %v", windowsNodeControllerName, err)
+		}
 	}
 
 	var connectionResult checks.ClusterCheckResult
@@ -223,6 +308,22 @@ func (c *VSphereController) sync(ctx context.Context, syncContext factory.SyncCo
 		return err
 	}
 
+	c.operatorConfig, err = c.loadOperatorConfig()
+	if err != nil {
+		return err
+	}
+
+	c.inMaintenance, err = c.syncMaintenance(ctx, syncContext, opStatus)
+	if err != nil {
+		return err
+	}
+
+	c.vSphereChecker.SetBackoff(c.operatorConfig.GetClusterCheckBackoff(defaultBackoff))
+	if err := c.restoreCheckState(ctx, syncContext); err != nil {
+		return err
+	}
+	c.processRunChecksTrigger(clusterCSIDriver)
+
 	// Update infra so we have failure domains in the case of an older cluster with out-dated infra definition.
 	// The following logic is borrowed from VPD.  We should make util project contain this so its shared and kept in sync
 	infra = infra.DeepCopy() // ConvertToPlatformSpec modifies the object in place
@@ -248,6 +349,12 @@ func (c *VSphereController) sync(ctx context.Context, syncContext factory.SyncCo
 		}
 		c.vSphereConnections = nil
 	}()
+	// Publish the results also when vCenter can't be reached or storage policies fail to sync
+	defer func() {
+		if err := c.publishCheckResults(ctx, connectionResult); err != nil {
+			klog.Errorf("error publishing check results: %v", err)
+		}
+	}()
 
 	// if we successfully connected to vCenter and previously we couldn't and operator has one or more
 	// error conditions, then lets reset exp. backoff so as we can run the full cluster checks
@@ -269,14 +376,21 @@ func (c *VSphereController) sync(ctx context.Context, syncContext factory.SyncCo
 	}
 
 	// only install CSI storageclass if blockCSIDriverInstall is false and CSI driver has been installed.
-	if !blockCSIDriverInstall && c.operandControllerStarted {
+	// The storage policy can't be synced while vCenter is not reachable during its maintenance.
+	if !blockCSIDriverInstall && c.operandControllerStarted && len(c.vSphereConnections) > 0 {
 		storageClassAPIDeps := c.getCheckAPIDependency(infra)
+		policyState, _ := c.storageClassController.GetState()
 		err = c.storageClassController.Sync(ctx, c.vSphereConnections, storageClassAPIDeps)
 		// storageclass sync will only return error if somehow updating conditions fails, in which case
 		// we can return error here and degrade the cluster
 		if err != nil {
 			return err
 		}
+		if newPolicyState, _ := c.storageClassController.GetState(); !newPolicyState.LastSync.Equal(&policyState.LastSync) {
+			if err := c.saveCheckState(ctx); err != nil {
+				klog.Errorf("error saving check state, a restarted operator will sync storage policies immediately: %v", err)
+			}
+		}
 	}
 
 	return nil
@@ -308,6 +422,11 @@ func (c *VSphereController) installCSIDriver(
 		return blockCSIDriverInstall, err
 	}
 
+	// vCenter can't be reached during its maintenance, the checks need it
+	if len(c.vSphereConnections) == 0 {
+		return blockCSIDriverInstall, nil
+	}
+
 	delay, result, checkRan := c.runClusterCheck(ctx, infra)
 	// if checks did not run
 	if !checkRan {
@@ -333,21 +452,26 @@ func (c *VSphereController) installCSIDriver(
 	}
 
 	blockUpgrade := connectionBlockUpgrade || clusterCheckBlockUpgrade
-	// All checks succeeded, reset any error metrics
-	if !blockUpgrade {
+	// All checks succeeded, reset any error metrics. Failures ignored during maintenance are kept until it ends.
+	if !blockUpgrade && !c.inMaintenance {
 		utils.InstallErrorMetric.Reset()
 	}
 
 	// if operand was not started previously and block upgrade is false and clusterdegrade is also false
 	// then and only then we should start CSI driver operator
 	if !c.operandControllerStarted && !blockCSIDriverInstall {
-		go c.runConditionalController(ctx)
-		c.operandControllerStarted = true
+		c.enableOperandControllers(ctx)
+	}
+	// Degraded and Upgradeable do not change during maintenance
+	if c.inMaintenance {
+		return blockCSIDriverInstall, nil
 	}
 	upgradeableStatus := operatorapi.ConditionTrue
 	if blockUpgrade {
 		upgradeableStatus = operatorapi.ConditionFalse
 	}
+	// Report unreachable vCenters even when checks of the reachable ones passed
+	result = getOverallResult([]checks.ClusterCheckResult{connectionResult, result})
 	return blockCSIDriverInstall, c.updateConditions(ctx, c.name, result, opStatus, upgradeableStatus, blockCSIDriverInstall)
 }
 
@@ -376,6 +500,13 @@ func (c *VSphereController) blockUpgradeOrDegradeCluster(
 
 	var clusterCondition string
 	clusterStatus, result := checks.CheckClusterStatus(result, c.getCheckAPIDependency(infra))
+	// Failures during maintenance are expected, record them without touching the conditions. An installed driver
+	// is still managed, a new one is installed only after the maintenance.
+	if c.inMaintenance && clusterStatus != checks.ClusterCheckAllGood {
+		klog.Warningf("Ignoring failed check during vCenter maintenance window: %s %s", result.CheckStatus, result.Reason)
+		utils.SetInstallError(string(result.CheckStatus), maintenanceMetricCondition)
+		return nil, !c.operandControllerStarted, false
+	}
 	switch clusterStatus {
 	case checks.ClusterCheckDegrade:
 		clusterCondition = "degraded"
@@ -404,6 +535,38 @@ func (c *VSphereController) blockUpgradeOrDegradeCluster(
 	return nil, false, false
 }
 
+// createOperandControllers creates the controllers that manage the CSI driver operands. They are created only once,
+// operandOperatorClient gates their operands.
+func (c *VSphereController) createOperandControllers() {
+	c.operandOperatorClient = newGatedOperatorClient(c.operatorClient)
+	c.controllers = []conditionalController{}
+	c.createCSIDriver()
+	c.createWebHookController()
+	c.createWindowsNodeController()
+}
+
+// enableOperandControllers opens the gate of the operand controllers, so they install the CSI driver operands.
+// The controllers are started on the first call and run until the operator exits, because library-go controllers
+// can't be started again once they were stopped. Later calls only open operandOperatorClient again.
+func (c *VSphereController) enableOperandControllers(ctx context.Context) {
+	c.operandOperatorClient.setOpen(true)
+	if !c.operandControllersRunning {
+		go c.runConditionalController(ctx)
+		c.operandControllersRunning = true
+	}
+	c.operandControllerStarted = true
+}
+
+// disableOperandControllers closes the gate of the operand controllers. The controllers keep running, but they see
+// the Removed management state and remove their operands until enableOperandControllers is called again.
+func (c *VSphereController) disableOperandControllers() {
+	if !c.operandControllerStarted {
+		return
+	}
+	c.operandOperatorClient.setOpen(false)
+	c.operandControllerStarted = false
+}
+
 func (c *VSphereController) runConditionalController(ctx context.Context) {
 	var wg sync.WaitGroup
 	wg.Add(len(c.controllers))
@@ -426,7 +589,48 @@ func (c *VSphereController) runClusterCheck(ctx context.Context, infra *ocpv1.In
 	checkerApiClient := c.getCheckAPIDependency(infra)
 
 	checkOpts := checks.NewCheckArgs(c.vSphereConnections, checkerApiClient, c.featureGates)
-	return c.vSphereChecker.Check(ctx, checkOpts)
+	if c.nodeStatuses != nil {
+		checkOpts = checkOpts.WithNodeStatuses(c.nodeStatuses)
+	}
+	if c.inventory != nil {
+		checkOpts = checkOpts.WithInventory(c.inventory)
+	}
+	if c.vCenterWarnings != nil {
+		checkOpts = checkOpts.WithVCenterWarnings(c.vCenterWarnings)
+	}
+	if len(c.unavailableZones) > 0 {
+		checkOpts = checkOpts.WithUnavailableZones(c.unavailableZones)
+	}
+	// The full check lists all nodes after this, so it checks the pending nodes too
+	var pending []string
+	if c.pendingNodes != nil {
+		pending = c.pendingNodes.take()
+	}
+	delay, result, checkRan := c.vSphereChecker.Check(ctx, checkOpts)
+	if !checkRan && len(pending) > 0 {
+		klog.V(2).Infof("Checking new nodes %v", pending)
+		delay, result, checkRan = c.vSphereChecker.CheckNodes(ctx, checkOpts, pending)
+		if !checkRan {
+			c.pendingNodes.add(pending...)
+		}
+	}
+	if checkRan {
+		if err := c.saveCheckState(ctx); err != nil {
+			klog.Errorf("error saving check state, a restarted operator will run all checks immediately: %v", err)
+		}
+		if err := c.syncVCenterWarnings(ctx); err != nil {
+			klog.Errorf("error reporting problems of vCenters: %v", err)
+		}
+		c.reportNodeVolumeLimits()
+		c.reportNotInitializedNodes()
+		if err := c.syncNodeEligibilityLabels(ctx); err != nil {
+			klog.Errorf("error labeling nodes ineligible for the CSI driver: %v", err)
+		}
+		if err := c.syncHostWarnings(ctx); err != nil {
+			klog.Errorf("error reporting problems of ESXi hosts: %v", err)
+		}
+	}
+	return delay, result, checkRan
 }
 
 func (c *VSphereController) getCheckAPIDependency(infra *ocpv1.Infrastructure) checks.KubeAPIInterface {
@@ -435,6 +639,7 @@ func (c *VSphereController) getCheckAPIDependency(infra *ocpv1.Infrastructure) c
 		CSINodeLister:   c.csiNodeLister,
 		CSIDriverLister: c.csiDriverLister,
 		NodeLister:      c.nodeLister,
+		OperatorConfig:  c.operatorConfig,
 	}
 	return checkerApiClient
 }
@@ -445,19 +650,7 @@ func (c *VSphereController) loginToVCenter(ctx context.Context, infra *ocpv1.Inf
 		return checks.MakeClusterDegradedError(checks.CheckStatusOpenshiftAPIError, immediateError)
 	}
 
-	for _, vConn := range c.vSphereConnections {
-		err := vConn.Connect(ctx)
-		if err != nil {
-			result := checks.ClusterCheckResult{
-				CheckError:  err,
-				Action:      checks.CheckActionBlockUpgradeOrDegrade,
-				CheckStatus: checks.CheckStatusVSphereConnectionFailed,
-				Reason:      fmt.Sprintf("Failed to connect to vSphere: %v", err),
-			}
-			return result
-		}
-	}
-	return checks.MakeClusterCheckResultPass()
+	return c.connectVCenters(ctx, infra)
 }
 
 func hasErrorConditions(opStats operatorapi.OperatorStatus) bool {
@@ -485,6 +678,10 @@ func hasErrorConditions(opStats operatorapi.OperatorStatus) bool {
 
 func (c *VSphereController) createVCenterConnection(ctx context.Context, infra *ocpv1.Infrastructure) error {
 	klog.V(3).Infof("Creating vSphere connection")
+	proxyConfig, err := c.getProxyConfig()
+	if err != nil {
+		return err
+	}
 	for _, vcenter := range infra.Spec.PlatformSpec.VSphere.VCenters {
 		secret, err := c.secretLister.Secrets(c.targetNamespace).Get(cloudCredSecretName)
 		if err != nil {
@@ -505,11 +702,60 @@ func (c *VSphereController) createVCenterConnection(ctx context.Context, infra *
 		if err != nil {
 			return fmt.Errorf("error creating new vsphere connection: %v", err)
 		}
+		vs.Proxy = proxyConfig
 		c.vSphereConnections = append(c.vSphereConnections, vs)
 	}
 	return nil
 }
 
+// loadOperatorConfig returns the operator configuration from utils.OperatorConfigMapName.
+func (c *VSphereController) loadOperatorConfig() (*utils.OperatorConfig, error) {
+	// ConfigMap informer for the operator namespace is not available in some unit tests
+	if c.apiClients.ConfigMapInformer == nil {
+		return &utils.OperatorConfig{}, nil
+	}
+	return utils.GetOperatorConfig(c.apiClients.ConfigMapInformer.Lister())
+}
+
+// getProxyConfig returns the cluster-wide proxy configuration together with the trusted CA bundle,
+// or nil when no proxy is configured.
+func (c *VSphereController) getProxyConfig() (*vclib.ProxyConfig, error) {
+	// Listers are not available in some unit tests
+	if c.proxyLister == nil {
+		return nil, nil
+	}
+	proxy, err := c.proxyLister.Get(proxyGlobalName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cluster proxy configuration: %v", err)
+	}
+
+	if proxy.Status.HTTPProxy == "" && proxy.Status.HTTPSProxy == "" {
+		return nil, nil
+	}
+
+	proxyConfig := &vclib.ProxyConfig{
+		HTTPProxy:  proxy.Status.HTTPProxy,
+		HTTPSProxy: proxy.Status.HTTPSProxy,
+		NoProxy:    proxy.Status.NoProxy,
+	}
+
+	// The trusted CA bundle is injected by the cluster network operator into the ConfigMap
+	// created from ca_configmap.yaml. It contains the CAs from proxy.spec.trustedCA.
+	caConfigMap, err := c.apiClients.ConfigMapInformer.Lister().ConfigMaps(c.targetNamespace).Get(trustedCAConfigMap)
+	switch {
+	case apierrors.IsNotFound(err):
+		klog.V(2).Infof("ConfigMap %s/%s not found, using system trusted CAs for vCenter connections", c.targetNamespace, trustedCAConfigMap)
+	case err != nil:
+		return nil, fmt.Errorf("failed to get trusted CA bundle: %v", err)
+	default:
+		proxyConfig.TrustedCABundle = []byte(caConfigMap.Data[trustedCABundleKey])
+	}
+	return proxyConfig, nil
+}
+
 func (c *VSphereController) loadCloudConfig(infra *ocpv1.Infrastructure) (*vclib.VSphereConfig, error) {
 	cloudConfig := infra.Spec.CloudConfig
 	cloudConfigMap, err := c.configMapLister.ConfigMaps(cloudConfigNamespace).Get(cloudConfig.Name)
@@ -667,7 +913,7 @@ func (c *VSphereController) createCSISecret(
 	if len(infra.Spec.PlatformSpec.VSphere.VCenters) == 1 {
 		datastoreURLs := make(map[string]string)
 		for _, connection := range c.vSphereConnections {
-			storageApiClient := storageclasscontroller.NewStoragePolicyAPI(ctx, connection, infra)
+			storageApiClient := storageclasscontroller.NewStoragePolicyAPI(ctx, connection, infra, c.operatorConfig.GetVCenterConfig(connection.Hostname))
 
 			defaultDatastore, err := storageApiClient.GetDefaultDatastore(ctx, infra)
 
@@ -731,7 +977,7 @@ func (c *VSphereController) applyClusterCSIDriverChange(
 		vcenterStr := string(csiVCenterConfigBytes)
 		vcenter := config.VirtualCenter[vcenterKey]
 
-		user, password, err := getUserAndPassword(defaultNamespace, cloudCredSecretName, vcenter.VCenterIP, infra, c.configMapLister, c.apiClients.SecretInformer, c.featureGates)
+		user, password, err := getUserAndPassword(defaultNamespace, cloudCredSecretName, vcenter.VCenterIP, infra, c.configMapLister, c.secretLister, c.featureGates)
 		if err != nil {
 			return nil, err
 		}
@@ -799,9 +1045,9 @@ func (c *VSphereController) createStorageClassController() storageclasscontrolle
 	return storageClassController
 }
 
-func getUserAndPassword(namespace string, secretName string, vcenter string, infra *ocpv1.Infrastructure, configMapLister corelister.ConfigMapLister, secretInformer corev1informers.SecretInformer, featureGates featuregates.FeatureGate,
+func getUserAndPassword(namespace string, secretName string, vcenter string, infra *ocpv1.Infrastructure, configMapLister corelister.ConfigMapLister, secretLister corelister.SecretLister, featureGates featuregates.FeatureGate,
 ) (string, string, error) {
-	secret, err := secretInformer.Lister().Secrets(namespace).Get(secretName)
+	secret, err := secretLister.Secrets(namespace).Get(secretName)
 	if err != nil {
 		return "", "", err
 	}
@@ -3,6 +3,7 @@ package vspherecontroller
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -14,14 +15,17 @@ import (
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/assets"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/storageclasscontroller"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
 	iniv1 "gopkg.in/ini.v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/component-base/metrics/testutil"
 	"k8s.io/utils/clock"
 )
@@ -62,22 +66,32 @@ func newVsphereControllerWithGates(apiClients *utils.APIClient, gates featuregat
 		scLister:               scInformer.Lister(),
 		csiDriverLister:        csiDriverLister,
 		nodeLister:             nodeLister,
+		deploymentLister:       kubeInformers.InformersFor(defaultNamespace).Apps().V1().Deployments().Lister(),
+		daemonSetLister:        kubeInformers.InformersFor(defaultNamespace).Apps().V1().DaemonSets().Lister(),
 		secretManifest:         secretBytes,
 		csiConfigManifest:      csiConfigBytes,
 		apiClients:             *apiClients,
 		clusterCSIDriverLister: apiClients.ClusterCSIDriverInformer.Lister(),
 		eventRecorder:          rc,
 		vSphereChecker:         newVSphereEnvironmentChecker(),
+		nodeStatuses:           checks.NewNodeStatuses(),
+		vCenterWarnings:        checks.NewVCenterWarnings(),
+		pendingNodes:           newPendingNodes(),
+		inventory:              checks.NewInventory(),
 		infraLister:            infraInformer.Lister(),
 		featureGates:           gates,
 	}
+	c.operandOperatorClient = newGatedOperatorClient(c.operatorClient)
 	c.controllers = []conditionalController{}
 	c.storageClassController = &dummyStorageClassController{syncCalled: 0}
 	return c
 }
 
 type dummyStorageClassController struct {
-	syncCalled int
+	syncCalled   int
+	state        *storageclasscontroller.StoragePolicySyncState
+	backoffReset bool
+	lastResult   checks.ClusterCheckResult
 }
 
 func (c *dummyStorageClassController) Sync(ctx context.Context, connection []*vclib.VSphereConnection, apiDeps checks.KubeAPIInterface) error {
@@ -89,6 +103,25 @@ func (c *dummyStorageClassController) SyncRemove(ctx context.Context) error {
 	return nil
 }
 
+func (c *dummyStorageClassController) GetState() (storageclasscontroller.StoragePolicySyncState, bool) {
+	if c.state == nil {
+		return storageclasscontroller.StoragePolicySyncState{}, false
+	}
+	return *c.state, true
+}
+
+func (c *dummyStorageClassController) RestoreState(state storageclasscontroller.StoragePolicySyncState) {
+	c.state = &state
+}
+
+func (c *dummyStorageClassController) ResetBackoff() {
+	c.backoffReset = true
+}
+
+func (c *dummyStorageClassController) LastResult() checks.ClusterCheckResult {
+	return c.lastResult
+}
+
 func TestSync(t *testing.T) {
 	metricsHeader := `
         # HELP vsphere_csi_driver_error [ALPHA] vSphere driver installation error
@@ -113,6 +146,8 @@ func TestSync(t *testing.T) {
 		failVCenterConnection        bool
 		operandStarted               bool
 		storageClassCreated          bool
+		expectedIneligibleNodes      []string
+		operatorConfig               string
 	}{
 		{
 			name:                         "when all configuration is right",
@@ -204,6 +239,46 @@ func TestSync(t *testing.T) {
 			operandStarted:      false,
 			storageClassCreated: false,
 		},
+		{
+			name:                         "when we can't connect to vcenter during maintenance",
+			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
+			vcenterVersion:               "7.0.2",
+			hostVersion:                  "7.0.2",
+			startingNodeHardwareVersions: []string{"vmx-15", "vmx-15"},
+			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()},
+			infra:                        testlib.GetInfraObject(),
+			failVCenterConnection:        true,
+			operatorConfig: fmt.Sprintf("maintenance:\n  start: %s\n  end: %s\n",
+				time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), time.Now().Add(time.Hour).UTC().Format(time.RFC3339)),
+			expectedConditions: []opv1.OperatorCondition{
+				{
+					Type:   testControllerName + "Maintenance",
+					Status: opv1.ConditionTrue,
+				},
+			},
+			expectedMetrics:     `vsphere_csi_driver_error{condition="maintenance",failure_reason="vsphere_connection_failed"} 1`,
+			operandStarted:      false,
+			storageClassCreated: false,
+		},
+		{
+			name:                         "when vcenter version is older and csi driver exists during maintenance, keep managing the driver",
+			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
+			hostVersion:                  "7.0.2",
+			startingNodeHardwareVersions: []string{"vmx-15", "vmx-15"},
+			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret(), testlib.GetCSIDriver(true)},
+			infra:                        testlib.GetInfraObject(),
+			operatorConfig: fmt.Sprintf("maintenance:\n  start: %s\n  end: %s\n",
+				time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), time.Now().Add(time.Hour).UTC().Format(time.RFC3339)),
+			expectedConditions: []opv1.OperatorCondition{
+				{
+					Type:   testControllerName + "Maintenance",
+					Status: opv1.ConditionTrue,
+				},
+			},
+			expectedMetrics:     `vsphere_csi_driver_error{condition="maintenance",failure_reason="check_deprecated_vcenter"} 1`,
+			operandStarted:      true,
+			storageClassCreated: true,
+		},
 		{
 			name:                         "when we can't connect to vcenter YAML",
 			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
@@ -507,6 +582,57 @@ vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="existing_dr
 			operandStarted:      false,
 			storageClassCreated: false,
 		},
+		{
+			name:                         "when one node hw-version is old, driver runs on the other nodes",
+			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
+			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()},
+			vcenterVersion:               "7.0.2",
+			hostVersion:                  "7.0.2",
+			startingNodeHardwareVersions: []string{"vmx-13", "vmx-15"},
+			infra:                        testlib.GetInfraObject(),
+			expectedConditions: []opv1.OperatorCondition{
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeUpgradeable,
+					Status: opv1.ConditionFalse,
+				},
+				{
+					Type:   "VMwareVSphereOperatorCheck" + opv1.OperatorStatusTypeDegraded,
+					Status: opv1.ConditionFalse,
+				},
+			},
+			expectedMetrics:         `vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="check_deprecated_hw_version"} 1`,
+			operandStarted:          true,
+			storageClassCreated:     true,
+			expectedIneligibleNodes: []string{testlib.DefaultNodes()[0].Name},
+		},
+		{
+			name:                         "when hw-version of all nodes is old, driver is not installed",
+			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
+			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()},
+			vcenterVersion:               "7.0.2",
+			hostVersion:                  "7.0.2",
+			startingNodeHardwareVersions: []string{"vmx-13", "vmx-13"},
+			infra:                        testlib.GetInfraObject(),
+			expectedConditions: []opv1.OperatorCondition{
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeUpgradeable,
+					Status: opv1.ConditionFalse,
+				},
+				{
+					Type:   testControllerName + "Disabled",
+					Status: opv1.ConditionTrue,
+				},
+				{
+					Type:   "VMwareVSphereOperatorCheck" + opv1.OperatorStatusTypeDegraded,
+					Status: opv1.ConditionFalse,
+				},
+			},
+			expectedMetrics: `vsphere_csi_driver_error{condition="install_blocked",failure_reason="check_deprecated_hw_version"} 1
+vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="check_deprecated_hw_version"} 1`,
+			operandStarted:          false,
+			storageClassCreated:     false,
+			expectedIneligibleNodes: []string{testlib.DefaultNodes()[0].Name, testlib.DefaultNodes()[1].Name},
+		},
 		{
 			name:                         "when node hw-version was old first and got upgraded",
 			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
@@ -679,7 +805,19 @@ vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="existing_dr
 			for _, node := range nodes {
 				test.initialObjects = append(test.initialObjects, runtime.Object(node))
 			}
+			var operatorConfigMap *v1.ConfigMap
+			if test.operatorConfig != "" {
+				operatorConfigMap = &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: utils.OperatorConfigMapName, Namespace: defaultNamespace},
+					Data:       map[string]string{utils.OperatorConfigKey: test.operatorConfig},
+				}
+				test.initialObjects = append(test.initialObjects, operatorConfigMap)
+			}
 			commonApiClient := testlib.NewFakeClients(test.initialObjects, test.clusterCSIDriverObject, runtime.Object(test.infra))
+			if operatorConfigMap != nil {
+				commonApiClient.ConfigMapInformer = commonApiClient.KubeInformers.InformersFor(defaultNamespace).Core().V1().ConfigMaps()
+				commonApiClient.ConfigMapInformer.Informer().GetStore().Add(operatorConfigMap)
+			}
 			clusterCSIDriver := testlib.GetClusterCSIDriver(false)
 			testlib.AddClusterCSIDriverClient(commonApiClient, clusterCSIDriver)
 
@@ -780,6 +918,20 @@ vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="existing_dr
 					t.Fatalf("expected operandStarted to be %v, got %v", test.operandStarted, ctrl.operandControllerStarted)
 				}
 
+				var ineligibleNodes []string
+				nodeList, err := ctrl.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("failed to list nodes: %v", err)
+				}
+				for _, node := range nodeList.Items {
+					if _, found := node.Labels[utils.NodeIneligibleLabel]; found {
+						ineligibleNodes = append(ineligibleNodes, node.Name)
+					}
+				}
+				if !reflect.DeepEqual(ineligibleNodes, test.expectedIneligibleNodes) {
+					t.Errorf("expected nodes %v to be labeled ineligible, got %v", test.expectedIneligibleNodes, ineligibleNodes)
+				}
+
 				if test.expectedMetrics != "" {
 					if err := testutil.CollectAndCompare(utils.InstallErrorMetric, strings.NewReader(metricsHeader+test.expectedMetrics+"\n"), utils.InstallErrorMetric.Name); err != nil {
 						t.Errorf("wrong metrics: %s", err)
@@ -1171,9 +1323,23 @@ func (*skippingChecker) Check(ctx context.Context, connection checks.CheckArgs)
 	return 0, checks.ClusterCheckResult{}, false
 }
 
+func (*skippingChecker) CheckNodes(ctx context.Context, connection checks.CheckArgs, nodeNames []string) (time.Duration, checks.ClusterCheckResult, bool) {
+	return 0, checks.ClusterCheckResult{}, false
+}
+
 func (*skippingChecker) ResetExpBackoff() {
 }
 
+func (*skippingChecker) SetBackoff(backoff wait.Backoff) {
+}
+
+func (*skippingChecker) GetState() (checkerState, bool) {
+	return checkerState{}, false
+}
+
+func (*skippingChecker) RestoreState(state checkerState) {
+}
+
 func newSkippingChecker() *skippingChecker {
 	return &skippingChecker{}
 }
@@ -1258,3 +1424,34 @@ func TestEscapeBackslashInUsername(t *testing.T) {
 		})
 	}
 }
+
+func TestMaintenanceEndClearsErrorMetric(t *testing.T) {
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+	ctrl := newVsphereController(commonApiClient)
+	utils.InstallErrorMetric.Reset()
+	utils.InstallErrorMetric.WithLabelValues(string(checks.CheckStatusDeprecatedVCenter), "upgrade_blocked").Set(1)
+	utils.SetInstallError(string(checks.CheckStatusVSphereConnectionFailed), maintenanceMetricCondition)
+
+	// The window was in effect during the previous sync and it's gone from the operator config
+	status := &opv1.OperatorStatus{
+		Conditions: []opv1.OperatorCondition{
+			{Type: ctrl.getMaintenanceConditionName(), Status: opv1.ConditionTrue},
+		},
+	}
+	active, err := ctrl.syncMaintenance(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder), status)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if active {
+		t.Errorf("expected maintenance window not to be in effect")
+	}
+
+	expectedMetrics := `
+        # HELP vsphere_csi_driver_error [ALPHA] vSphere driver installation error
+        # TYPE vsphere_csi_driver_error gauge
+        vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="check_deprecated_vcenter"} 1
+        `
+	if err := testutil.CollectAndCompare(utils.InstallErrorMetric, strings.NewReader(expectedMetrics), utils.InstallErrorMetric.Name); err != nil {
+		t.Errorf("expected only failures recorded during maintenance to be removed: %s", err)
+	}
+}
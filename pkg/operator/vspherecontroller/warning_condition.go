@@ -0,0 +1,59 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strings"
+
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// syncWarningCondition reports problems that do not block upgrades in the condition conditionType, one message per
+// line. It emits event only for messages that are not in the condition yet, because checks find the same problems
+// again and again. When there are no messages, it removes the condition and emits resolvedEvent with
+// resolvedMessage.
+func (c *VSphereController) syncWarningCondition(
+	ctx context.Context,
+	conditionType, reason, event, resolvedEvent, resolvedMessage string,
+	messages []string) error {
+
+	_, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	existing := v1helpers.FindOperatorCondition(opStatus.Conditions, conditionType)
+	if len(messages) == 0 {
+		if existing == nil {
+			return nil
+		}
+		klog.Info(resolvedMessage)
+		c.eventRecorder.Eventf(resolvedEvent, "%s", resolvedMessage)
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *operatorapi.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+			return nil
+		})
+		return err
+	}
+
+	reported := sets.New[string]()
+	if existing != nil {
+		reported.Insert(strings.Split(existing.Message, "\n")...)
+	}
+	for _, message := range messages {
+		if !reported.Has(message) {
+			klog.Warningf("%s: %s", event, message)
+			c.eventRecorder.Warningf(event, "%s", message)
+		}
+	}
+
+	cond := operatorapi.OperatorCondition{
+		Type:    conditionType,
+		Status:  operatorapi.ConditionTrue,
+		Reason:  reason,
+		Message: strings.Join(messages, "\n"),
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
@@ -16,7 +16,7 @@ func (c *VSphereController) createWebHookController() {
 		"VMwareVSphereDriverWebhookController",
 		webhookBytes,
 		c.eventRecorder,
-		c.operatorClient,
+		c.operandOperatorClient,
 		c.apiClients.KubeClient,
 		c.apiClients.KubeInformers.InformersFor(defaultNamespace).Apps().V1().Deployments(),
 		[]factory.Informer{
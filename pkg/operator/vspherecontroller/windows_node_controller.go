@@ -0,0 +1,177 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	operatorapi "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/assets"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+const (
+	windowsNodeControllerName = "VMwareVSphereDriverWindowsNodeServiceController"
+
+	// Windows nodes need Windows container images, they replace the images set by the node service controller
+	// when they are set.
+	envDriverWindowsImage              = "DRIVER_WINDOWS_IMAGE"
+	envNodeDriverRegistrarWindowsImage = "NODE_DRIVER_REGISTRAR_WINDOWS_IMAGE"
+	envLivenessProbeWindowsImage       = "LIVENESS_PROBE_WINDOWS_IMAGE"
+	nodeDriverRegistrarContainerName   = "csi-node-driver-registrar"
+	livenessProbeContainerName         = "csi-liveness-probe"
+
+	windowsImagesMissingReason = "WindowsImagesMissing"
+)
+
+var windowsNodeSelector = labels.SelectorFromSet(labels.Set{"kubernetes.io/os": "windows"})
+
+// createWindowsNodeController creates a controller for the node DaemonSet that runs on Windows nodes. The DaemonSet
+// uses HostProcess containers and csi-proxy for storage operations on the host. The controller is not started
+// together with the other operand controllers, see syncWindowsNodeController.
+func (c *VSphereController) createWindowsNodeController() {
+	dsBytes, err := assets.ReadFile("node_windows.yaml")
+	if err != nil {
+		panic("can not read node_windows.yaml file")
+	}
+	// The Windows operand is removed also when operands of all operand controllers are removed
+	c.windowsOperatorClient = newGatedOperatorClient(c.operandOperatorClient)
+	windowsNodeController := csidrivernodeservicecontroller.NewCSIDriverNodeServiceController(
+		windowsNodeControllerName,
+		dsBytes,
+		c.eventRecorder,
+		c.windowsOperatorClient,
+		c.apiClients.KubeClient,
+		c.apiClients.KubeInformers.InformersFor(defaultNamespace).Apps().V1().DaemonSets(),
+		[]factory.Informer{c.apiClients.SecretInformer.Informer()},
+		WithLogLevelDaemonSetHook(),
+		WithWindowsImagesDaemonSetHook(),
+		WithSecretDaemonSetAnnotationHook(driverConfigSecretName, defaultNamespace, c.apiClients.SecretInformer),
+		WithVolumeLimitDaemonSetHook(c.nodeStatuses),
+	)
+	c.windowsNodeController = &conditionalController{
+		name:       windowsNodeControllerName,
+		controller: windowsNodeController,
+	}
+}
+
+func (c *VSphereController) getWindowsImagesMissingConditionName() string {
+	return c.name + "WindowsImagesMissing"
+}
+
+// missingWindowsImages returns names of the operator environment variables with Windows container images that
+// are not set.
+func missingWindowsImages() []string {
+	var missing []string
+	for _, env := range []string{envDriverWindowsImage, envNodeDriverRegistrarWindowsImage, envLivenessProbeWindowsImage} {
+		if os.Getenv(env) == "" {
+			missing = append(missing, env)
+		}
+	}
+	return missing
+}
+
+// syncWindowsNodeController runs the Windows node controller while there are Windows nodes in the cluster and
+// the operator knows their container images. The controller reports the DaemonSet as not available when it has
+// no pods, so the DaemonSet is removed when the last Windows node leaves the cluster. Windows nodes that can't
+// run the driver because the images are not set are reported in a condition.
+func (c *VSphereController) syncWindowsNodeController(ctx context.Context) error {
+	if c.windowsNodeController == nil {
+		return nil
+	}
+	nodes, err := c.nodeLister.List(windowsNodeSelector)
+	if err != nil {
+		return err
+	}
+	var missingImages []string
+	if len(nodes) > 0 {
+		missingImages = missingWindowsImages()
+	}
+	if err := c.syncWindowsImagesCondition(ctx, len(nodes), missingImages); err != nil {
+		return err
+	}
+
+	enabled := len(nodes) > 0 && len(missingImages) == 0
+	if !c.windowsOperatorClient.setOpen(enabled) {
+		return nil
+	}
+	if !enabled {
+		klog.Infof("No Windows node can run the CSI driver, removing %s operand", windowsNodeControllerName)
+		// The controller does not update its conditions while its operand is removed
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *operatorapi.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, windowsNodeControllerName+operatorapi.OperatorStatusTypeAvailable)
+			v1helpers.RemoveOperatorCondition(&status.Conditions, windowsNodeControllerName+operatorapi.OperatorStatusTypeProgressing)
+			return nil
+		})
+		return err
+	}
+	if !c.windowsNodeControllerStarted {
+		klog.Infof("Found %d Windows node(s), starting %s", len(nodes), c.windowsNodeController.name)
+		go func() {
+			defer klog.Infof("%s controller terminated", c.windowsNodeController.name)
+			c.windowsNodeController.controller.Run(ctx, 1)
+		}()
+		c.windowsNodeControllerStarted = true
+	}
+	return nil
+}
+
+// syncWindowsImagesCondition reports Windows nodes that can't run the CSI driver because Windows container images
+// are not set in the operator environment. It does not block upgrades, Linux nodes are not affected.
+func (c *VSphereController) syncWindowsImagesCondition(ctx context.Context, windowsNodes int, missingImages []string) error {
+	_, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	existing := v1helpers.FindOperatorCondition(opStatus.Conditions, c.getWindowsImagesMissingConditionName())
+	if len(missingImages) == 0 {
+		if existing == nil {
+			return nil
+		}
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *operatorapi.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.getWindowsImagesMissingConditionName())
+			return nil
+		})
+		return err
+	}
+
+	message := fmt.Sprintf("The CSI driver does not run on %d Windows node(s), environment variables %s with Windows container images are not set in the operator",
+		windowsNodes, strings.Join(missingImages, ", "))
+	if existing == nil || existing.Message != message {
+		klog.Warning(message)
+		c.eventRecorder.Warning(windowsImagesMissingReason, message)
+	}
+	cond := operatorapi.OperatorCondition{
+		Type:    c.getWindowsImagesMissingConditionName(),
+		Status:  operatorapi.ConditionTrue,
+		Reason:  windowsImagesMissingReason,
+		Message: message,
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// WithWindowsImagesDaemonSetHook sets Windows container images from environment variables of the operator.
+func WithWindowsImagesDaemonSetHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(opSpec *operatorapi.OperatorSpec, ds *appsv1.DaemonSet) error {
+		images := map[string]string{
+			driverContainerName:              os.Getenv(envDriverWindowsImage),
+			nodeDriverRegistrarContainerName: os.Getenv(envNodeDriverRegistrarWindowsImage),
+			livenessProbeContainerName:       os.Getenv(envLivenessProbeWindowsImage),
+		}
+		containers := ds.Spec.Template.Spec.Containers
+		for i := range containers {
+			if image := images[containers[i].Name]; image != "" {
+				containers[i].Image = image
+			}
+		}
+		ds.Spec.Template.Spec.Containers = containers
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+package vspherecontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWindowsImagesDaemonSetHook(t *testing.T) {
+	t.Setenv(envDriverWindowsImage, "driver-windows")
+	t.Setenv(envNodeDriverRegistrarWindowsImage, "registrar-windows")
+	t.Setenv(envLivenessProbeWindowsImage, "")
+
+	ds := &appsv1.DaemonSet{}
+	ds.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: driverContainerName, Image: "driver"},
+		{Name: nodeDriverRegistrarContainerName, Image: "registrar"},
+		{Name: livenessProbeContainerName, Image: "liveness"},
+	}
+	if err := WithWindowsImagesDaemonSetHook()(&opv1.OperatorSpec{}, ds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedImages := []string{"driver-windows", "registrar-windows", "liveness"}
+	for i, container := range ds.Spec.Template.Spec.Containers {
+		if container.Image != expectedImages[i] {
+			t.Errorf("expected container %s to use image %s, got %s", container.Name, expectedImages[i], container.Image)
+		}
+	}
+}
+
+func TestSyncWindowsNodeController(t *testing.T) {
+	windowsNode := testlib.Node("windows-1", func(node *corev1.Node) {
+		node.Labels = map[string]string{"kubernetes.io/os": "windows"}
+	})
+	commonApiClient := testlib.NewFakeClients([]runtime.Object{windowsNode}, testlib.MakeFakeDriverInstance(), runtime.Object(testlib.GetInfraObject()))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go testlib.StartFakeInformer(commonApiClient, stopCh)
+	if err := testlib.AddInitialObjects([]runtime.Object{windowsNode}, commonApiClient); err != nil {
+		t.Fatalf("error adding initial objects: %v", err)
+	}
+	testlib.WaitForSync(commonApiClient, stopCh)
+
+	ctrl := newVsphereController(commonApiClient)
+	operand := &blockingController{started: make(chan struct{}), stopped: make(chan struct{})}
+	ctrl.windowsNodeController = &conditionalController{name: windowsNodeControllerName, controller: operand}
+	ctrl.windowsOperatorClient = newGatedOperatorClient(ctrl.operatorClient)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	syncWindows := func() *opv1.OperatorCondition {
+		t.Helper()
+		if err := ctrl.syncWindowsNodeController(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, status, _, err := ctrl.operatorClient.GetOperatorState()
+		if err != nil {
+			t.Fatalf("failed to get operator state: %v", err)
+		}
+		return v1helpers.FindOperatorCondition(status.Conditions, ctrl.getWindowsImagesMissingConditionName())
+	}
+	operandState := func() opv1.ManagementState {
+		t.Helper()
+		spec, _, _, err := ctrl.windowsOperatorClient.GetOperatorState()
+		if err != nil {
+			t.Fatalf("failed to get operator state: %v", err)
+		}
+		return spec.ManagementState
+	}
+
+	// Windows images are not set
+	t.Setenv(envDriverWindowsImage, "")
+	t.Setenv(envNodeDriverRegistrarWindowsImage, "")
+	t.Setenv(envLivenessProbeWindowsImage, "")
+	if cond := syncWindows(); cond == nil || cond.Status != opv1.ConditionTrue {
+		t.Errorf("expected condition about missing Windows images, got %+v", cond)
+	}
+	if !hasEvent(ctrl.eventRecorder, windowsImagesMissingReason) {
+		t.Errorf("expected event %s", windowsImagesMissingReason)
+	}
+	if ctrl.windowsNodeControllerStarted {
+		t.Errorf("expected Windows node controller not to start without Windows images")
+	}
+
+	t.Setenv(envDriverWindowsImage, "driver-windows")
+	t.Setenv(envNodeDriverRegistrarWindowsImage, "registrar-windows")
+	t.Setenv(envLivenessProbeWindowsImage, "liveness-windows")
+	if cond := syncWindows(); cond != nil {
+		t.Errorf("expected condition about missing Windows images to be removed, got %+v", cond)
+	}
+	select {
+	case <-operand.started:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Windows node controller was not started")
+	}
+	if state := operandState(); state != opv1.Managed {
+		t.Errorf("expected Windows operand to be %s, got %s", opv1.Managed, state)
+	}
+
+	// The last Windows node leaves the cluster
+	if err := commonApiClient.NodeInformer.Informer().GetIndexer().Delete(windowsNode); err != nil {
+		t.Fatalf("error deleting node: %v", err)
+	}
+	syncWindows()
+	if state := operandState(); state != opv1.Removed {
+		t.Errorf("expected Windows operand to be %s, got %s", opv1.Removed, state)
+	}
+	select {
+	case <-operand.stopped:
+		t.Errorf("expected Windows node controller to keep running, it can't be started again")
+	default:
+	}
+}